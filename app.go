@@ -1,6 +1,12 @@
 package main
 
-import "context"
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
 
 // App is the bridge bound to the frontend.
 type App struct {
@@ -18,39 +24,429 @@ func NewApp() *App {
 // startup stores Wails context.
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.svc.setContext(ctx)
+	a.svc.startBackground()
+	if !hasFlag(flagNoAutoRun) {
+		go a.svc.autoRun()
+	}
 }
 
-// startup stores Wails context.
+// onSecondInstanceLaunch brings the existing window forward when the exe is started again.
+func (a *App) onSecondInstanceLaunch(_ options.SecondInstanceData) {
+	if a.ctx == nil {
+		return
+	}
+	runtime.WindowShow(a.ctx)
+	runtime.WindowUnminimise(a.ctx)
+}
+
+// quitRequested is set when the app is quit on purpose (tray Exit, self-update), so beforeClose
+// lets it through even with close-to-tray enabled.
+var quitRequested atomic.Bool
+
+// quitApp quits the app regardless of the close-to-tray setting.
+func quitApp(ctx context.Context) {
+	quitRequested.Store(true)
+	runtime.Quit(ctx)
+}
+
+// beforeClose hides the window instead of quitting when close-to-tray is enabled.
+func (a *App) beforeClose(ctx context.Context) bool {
+	if quitRequested.Load() || !a.svc.closeToTray() {
+		return false
+	}
+	runtime.WindowHide(ctx)
+	return true
+}
+
+// shutdown stops background work and the running strategy, unless Settings.KeepStrategyOnExit
+// is set.
 func (a *App) shutdown(ctx context.Context) {
-	a.StopAll()
+	a.svc.stopBackground()
+	a.svc.CancelUpdate()
+	if !a.svc.keepStrategyOnExit() {
+		a.StopAll()
+	}
 }
 
-// GetState returns current config, strategies and latest tag info.
+// GetState returns current config, strategies and cached latest tag info.
+// A stale latest tag is refreshed in the background and reported via a "state:latest-tag" event.
 func (a *App) GetState() (*State, error) {
 	return a.svc.State()
 }
 
+// RefreshLatest re-checks GitHub for the latest release and returns refreshed state.
+func (a *App) RefreshLatest() (*State, error) {
+	return a.svc.RefreshLatest()
+}
+
 // CheckAndUpdate downloads latest release if newer and returns refreshed state.
+// Progress is reported through "downloadProgress" events.
 func (a *App) CheckAndUpdate() (*State, error) {
 	return a.svc.CheckAndUpdate()
 }
 
+// DiffRelease compares the general strategies of two installed releases.
+func (a *App) DiffRelease(tagA, tagB string) ([]StrategyDiff, error) {
+	return a.svc.DiffRelease(tagA, tagB)
+}
+
+// CancelUpdate aborts an in-progress CheckAndUpdate; it then fails with an "update canceled" error.
+func (a *App) CancelUpdate() {
+	a.svc.CancelUpdate()
+}
+
+// ListInstalledReleases returns the releases unpacked on disk.
+func (a *App) ListInstalledReleases() ([]ReleaseInfo, error) {
+	return a.svc.ListInstalledReleases()
+}
+
+// SwitchRelease makes an installed release current and returns refreshed state.
+func (a *App) SwitchRelease(tag string) (*State, error) {
+	return a.svc.SwitchRelease(tag)
+}
+
+// PruneReleases deletes old releases, keeping the keep most recent ones.
+func (a *App) PruneReleases(keep int) (*PruneSummary, error) {
+	return a.svc.PruneReleases(keep)
+}
+
+// SetKeepReleases sets how many releases are kept after an update (0 disables pruning).
+func (a *App) SetKeepReleases(keep int) (*State, error) {
+	return a.svc.SetKeepReleases(keep)
+}
+
+// SetProxy sets the proxy used for GitHub requests; an empty URL reverts to the system proxy.
+func (a *App) SetProxy(url string) error {
+	return a.svc.SetProxy(url)
+}
+
+// SetMirrors sets fallback hosts used when GitHub downloads or API lookups fail.
+func (a *App) SetMirrors(mirrors, apiMirrors []string) (*State, error) {
+	return a.svc.SetMirrors(mirrors, apiMirrors)
+}
+
+// SetUpdateChannel selects "stable" or "prerelease" updates and returns refreshed state.
+func (a *App) SetUpdateChannel(channel string) (*State, error) {
+	return a.svc.SetUpdateChannel(channel)
+}
+
+// SkipVersion dismisses a release so it no longer shows as an update.
+func (a *App) SkipVersion(tag string) (*State, error) {
+	return a.svc.SkipVersion(tag)
+}
+
+// RepairCurrentRelease re-downloads the active release, keeping user-edited lists.
+func (a *App) RepairCurrentRelease() (*State, error) {
+	return a.svc.RepairCurrentRelease()
+}
+
+// DownloadRelease fetches a specific tag without switching to it.
+func (a *App) DownloadRelease(tag string) error {
+	return a.svc.DownloadRelease(tag)
+}
+
+// GetStorageUsage reports disk space used by releases and logs.
+func (a *App) GetStorageUsage() (*StorageUsage, error) {
+	return a.svc.GetStorageUsage()
+}
+
+// SetBaseDir moves the app data folder to path and returns refreshed state.
+func (a *App) SetBaseDir(path string) (*State, error) {
+	return a.svc.SetBaseDir(path)
+}
+
+// UpdateSelf installs the latest zapret-ui release; the app quits so the binary can be swapped and relaunched.
+func (a *App) UpdateSelf() error {
+	if err := a.svc.UpdateSelf(); err != nil {
+		return err
+	}
+	if a.ctx != nil {
+		quitApp(a.ctx)
+	}
+	return nil
+}
+
+// ApplyPendingUpdate stops the running strategy to apply a deferred update, optionally restarting it.
+func (a *App) ApplyPendingUpdate(restart bool) (*State, error) {
+	return a.svc.ApplyPendingUpdate(restart)
+}
+
+// ExportData saves settings, test results and lists to a JSON file at path.
+func (a *App) ExportData(path string) error {
+	return a.svc.ExportData(path)
+}
+
+// ImportData loads a file written by ExportData; policy is "replace" or "merge".
+func (a *App) ImportData(path, policy string) (*State, error) {
+	return a.svc.ImportData(path, policy)
+}
+
+// ResetApp clears test results and notes, optionally deleting downloaded releases and logs.
+func (a *App) ResetApp(keepReleases bool) (*State, error) {
+	return a.svc.ResetApp(keepReleases)
+}
+
+// GetSettings returns the user preferences.
+func (a *App) GetSettings() (*Settings, error) {
+	return a.svc.GetSettings()
+}
+
+// UpdateSettings validates and stores the user preferences and returns refreshed state.
+func (a *App) UpdateSettings(settings Settings) (*State, error) {
+	return a.svc.UpdateSettings(settings)
+}
+
+// SetStrategyMeta sets a strategy's display alias and note; empty values delete them.
+func (a *App) SetStrategyMeta(name, alias, note string) (*State, error) {
+	return a.svc.SetStrategyMeta(name, alias, note)
+}
+
+// GetStrategies lists the current release's strategies filtered and ordered by q.
+func (a *App) GetStrategies(q StrategyQuery) ([]Strategy, error) {
+	return a.svc.GetStrategies(q)
+}
+
+// SetStrategyTags replaces the tags of a strategy and returns refreshed state.
+func (a *App) SetStrategyTags(name string, tags []string) (*State, error) {
+	return a.svc.SetStrategyTags(name, tags)
+}
+
+// ToggleFavorite pins or unpins a strategy at the top of the list.
+func (a *App) ToggleFavorite(name string) (*State, error) {
+	return a.svc.ToggleFavorite(name)
+}
+
+// ToggleHidden hides or shows a strategy in the list.
+func (a *App) ToggleHidden(name string) (*State, error) {
+	return a.svc.ToggleHidden(name)
+}
+
+// GetStrategyContent returns the script of a strategy for viewing.
+func (a *App) GetStrategyContent(name string) (*StrategyContent, error) {
+	return a.svc.GetStrategyContent(name)
+}
+
+// GetLists returns the hostlists of the current release.
+func (a *App) GetLists() ([]ListFile, error) {
+	return a.svc.GetLists()
+}
+
+// GetListEntries returns the domains of a hostlist.
+func (a *App) GetListEntries(name string) ([]string, error) {
+	return a.svc.GetListEntries(name)
+}
+
+// AddListEntry adds a domain to a hostlist; autoRestart restarts the running strategy after it.
+func (a *App) AddListEntry(name, domain string, autoRestart bool) (*ListEditResult, error) {
+	return a.svc.AddListEntry(name, domain, autoRestart)
+}
+
+// RemoveListEntry removes a domain from a hostlist; autoRestart restarts the running strategy after it.
+func (a *App) RemoveListEntry(name, domain string, autoRestart bool) (*ListEditResult, error) {
+	return a.svc.RemoveListEntry(name, domain, autoRestart)
+}
+
+// RestoreListBackup restores the latest backup of a hostlist; autoRestart restarts the running
+// strategy after it.
+func (a *App) RestoreListBackup(name string, autoRestart bool) (*ListEditResult, error) {
+	return a.svc.RestoreListBackup(name, autoRestart)
+}
+
+// ValidateStrategy returns the files a strategy references that are missing.
+func (a *App) ValidateStrategy(name string) ([]string, error) {
+	return a.svc.ValidateStrategy(name)
+}
+
+// CreateStrategy builds a custom strategy from an existing one and returns refreshed state.
+func (a *App) CreateStrategy(name string, opts StrategyOptions) (*State, error) {
+	return a.svc.CreateStrategy(name, opts)
+}
+
+// CloneStrategy copies a strategy into the custom folder with winws flag overrides.
+func (a *App) CloneStrategy(source, newName string, overrides map[string]string) (*State, error) {
+	return a.svc.CloneStrategy(source, newName, overrides)
+}
+
+// UpdateStrategy re-renders a custom strategy with new options.
+func (a *App) UpdateStrategy(name string, opts StrategyOptions) (*State, error) {
+	return a.svc.UpdateStrategy(name, opts)
+}
+
+// DeleteStrategy removes a custom strategy.
+func (a *App) DeleteStrategy(name string) (*State, error) {
+	return a.svc.DeleteStrategy(name)
+}
+
 // RunTests executes the official test script (standard mode, all configs) and updates state.
 func (a *App) RunTests() (*State, error) {
 	return a.svc.RunTests()
 }
 
+// RunTestFor tests a single general strategy and updates only its result.
+func (a *App) RunTestFor(name string) (*State, error) {
+	return a.svc.RunTestFor(name)
+}
+
+// QuickCheck probes a few Discord and YouTube endpoints through the running strategy, or without
+// one for a baseline.
+func (a *App) QuickCheck() (QuickCheckResult, error) {
+	return a.svc.QuickCheck()
+}
+
+// SetQuickCheckTargets sets the endpoints QuickCheck requests; an empty list restores the defaults.
+func (a *App) SetQuickCheckTargets(targets []string) (*State, error) {
+	return a.svc.SetQuickCheckTargets(targets)
+}
+
+// RetestFailed tests again only the strategies whose last result failed or is missing.
+func (a *App) RetestFailed() (*State, error) {
+	return a.svc.RetestFailed()
+}
+
+// GetStrategyDomainResults returns the per-domain outcomes of the strategy's last test.
+func (a *App) GetStrategyDomainResults(name string) (map[string]string, error) {
+	return a.svc.GetStrategyDomainResults(name)
+}
+
+// ExportResults saves the last completed test results to path as "csv", "json" or "markdown-table".
+func (a *App) ExportResults(format, path string) error {
+	return a.svc.ExportResults(format, path)
+}
+
+// CopyResultsToClipboard copies the last completed test results in the given format.
+func (a *App) CopyResultsToClipboard(format string) error {
+	return a.svc.CopyResultsToClipboard(format)
+}
+
+// CompareRuns compares two test runs from the history; empty IDs mean the latest and the one before.
+func (a *App) CompareRuns(from, to string) (RunComparison, error) {
+	return a.svc.CompareRuns(from, to)
+}
+
+// RunDiagnostics checks internet access, DNS, PowerShell and other DPI tools the way a test run
+// does before starting the script.
+func (a *App) RunDiagnostics() (DiagnosticsReport, error) {
+	return a.svc.RunDiagnostics()
+}
+
+// StartTests starts the test script in the background and returns the job; the outcome arrives
+// as a "test:job" event.
+func (a *App) StartTests(opts TestOptions) (*TestJob, error) {
+	return a.svc.StartTests(opts)
+}
+
+// GetTestJob returns the running or most recent test job.
+func (a *App) GetTestJob() (*TestJob, error) {
+	return a.svc.GetTestJob()
+}
+
+// RunTestsWith runs the test script in the given mode for all or some configs.
+func (a *App) RunTestsWith(opts TestOptions) (*State, error) {
+	return a.svc.RunTestsWith(opts)
+}
+
+// SetTestDomains replaces the custom domains probed with each strategy after a test run.
+func (a *App) SetTestDomains(domains []string) (*State, error) {
+	return a.svc.SetTestDomains(domains)
+}
+
+// RunNativeTests tests every general strategy with the built-in tester instead of the PowerShell script.
+func (a *App) RunNativeTests() (*State, error) {
+	return a.svc.RunNativeTests()
+}
+
+// GetTestHistory returns the recorded test runs, oldest first.
+func (a *App) GetTestHistory() ([]TestRun, error) {
+	return a.svc.GetTestHistory()
+}
+
+// GetStrategyHistory returns a strategy's scores across the recorded test runs, for a trend line.
+func (a *App) GetStrategyHistory(name string) ([]StrategyHistoryPoint, error) {
+	return a.svc.GetStrategyHistory(name)
+}
+
+// GetTestLogTail returns the last lines of the latest test log, "" when there is none.
+func (a *App) GetTestLogTail(lines int) (string, error) {
+	return a.svc.GetTestLogTail(lines)
+}
+
+// GetRunningLogTail returns the last lines of the output of the running or last started strategy.
+func (a *App) GetRunningLogTail(lines int) (string, error) {
+	return a.svc.GetRunningLogTail(lines)
+}
+
+// CancelTests stops the test run in progress, keeping any results written so far.
+func (a *App) CancelTests() (*State, error) {
+	return a.svc.CancelTests()
+}
+
 // RunStrategy starts a selected BAT strategy (non-service, foreground process) and records last run.
-func (a *App) RunStrategy(file string) (*State, error) {
-	return a.svc.RunStrategy(file)
+// With force it starts even when other DPI bypass tools are running.
+func (a *App) RunStrategy(file string, force bool) (*State, error) {
+	return a.svc.RunStrategy(file, force)
 }
 
-// StopStrategy stops the tracked running strategy, if any.
-func (a *App) StopStrategy() (*State, error) {
-	if err := a.svc.StopRunning(); err != nil {
-		return nil, err
+// RelaunchAsAdmin restarts the app elevated through the UAC prompt. The current instance quits
+// once the new one has been started; declining the prompt returns an error and keeps it running.
+func (a *App) RelaunchAsAdmin() error {
+	if err := relaunchAsAdmin(); err != nil {
+		return err
 	}
-	return a.svc.State()
+	quitApp(a.ctx)
+	return nil
+}
+
+// RunStrategyGroup starts a strategy group with or without its game filter.
+func (a *App) RunStrategyGroup(group string, gameFilter, force bool) (*State, error) {
+	return a.svc.RunStrategyGroup(group, gameFilter, force)
+}
+
+// StopStrategy stops the tracked running strategy, if any, and winws instances left in the releases
+// folder.
+func (a *App) StopStrategy() (*State, error) {
+	return a.svc.StopStrategy()
+}
+
+// AdoptRunning tracks an external winws.exe matching a strategy as the running strategy.
+func (a *App) AdoptRunning(pid int) (*State, error) {
+	return a.svc.AdoptRunning(pid)
+}
+
+// ResetDriver stops all winws and removes the WinDivert driver service so the next launch
+// reinstalls it.
+func (a *App) ResetDriver() (*State, error) {
+	return a.svc.ResetDriver()
+}
+
+// InstallAsService installs strategy as the zapret Windows service and starts it.
+func (a *App) InstallAsService(strategy string) (*State, error) {
+	return a.svc.InstallAsService(strategy)
+}
+
+// RemoveService stops and deletes the zapret Windows service.
+func (a *App) RemoveService() (*State, error) {
+	return a.svc.RemoveService()
+}
+
+// RestartRunning restarts the running strategy so edits of its lists and files take effect.
+func (a *App) RestartRunning() (*State, error) {
+	return a.svc.RestartRunning()
+}
+
+// PauseRunning stops the running strategy and remembers it for ResumeRunning.
+func (a *App) PauseRunning() (*State, error) {
+	return a.svc.PauseRunning()
+}
+
+// ResumeRunning starts the paused strategy again.
+func (a *App) ResumeRunning() (*State, error) {
+	return a.svc.ResumeRunning()
+}
+
+// StopAllWinws stops every winws.exe on the system, including ones the app didn't start.
+func (a *App) StopAllWinws() (*State, error) {
+	return a.svc.StopAllWinws()
 }
 
 // StopAll is used on shutdown to ensure cleanup.
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// defaultAssetPattern matches the archive name upstream has used so far; "{tag}" is replaced by the release tag.
+const defaultAssetPattern = "zapret-discord-youtube-{tag}.zip"
+
+// archAliases are the spellings release archives use for each GOARCH.
+var archAliases = map[string][]string{
+	"amd64": {"amd64", "x64", "x86_64", "win64"},
+	"386":   {"386", "x86", "win32"},
+	"arm64": {"arm64", "aarch64"},
+}
+
+// resolveAsset finds the release archive for tag via the GitHub API.
+// It returns nil without error when the API can't be reached, so callers fall back to downloadTemplate.
+func (s *Service) resolveAsset(tag string) (*githubAsset, error) {
+	var rel *githubRelease
+	s.latestMu.Lock()
+	for i := range s.releases {
+		if s.releases[i].TagName == tag {
+			r := s.releases[i]
+			rel = &r
+			break
+		}
+	}
+	s.latestMu.Unlock()
+	if rel == nil {
+		var err error
+		rel, err = s.releaseByTag(tag)
+		if err != nil {
+			var nf *ReleaseNotFoundError
+			if errors.As(err, &nf) {
+				return nil, err
+			}
+			s.logUpdate("asset lookup for %s failed, using default name: %v", tag, err)
+			return nil, nil
+		}
+	}
+	pattern := defaultAssetPattern
+	if cfg, err := s.configSnapshot(); err == nil && strings.TrimSpace(cfg.AssetPattern) != "" {
+		pattern = strings.TrimSpace(cfg.AssetPattern)
+	}
+	return selectAsset(rel, pattern)
+}
+
+// selectAsset picks the asset of rel whose name matches the glob pattern, preferring one built for
+// the current architecture when several match.
+func selectAsset(rel *githubRelease, pattern string) (*githubAsset, error) {
+	pattern = strings.ToLower(strings.ReplaceAll(pattern, "{tag}", rel.TagName))
+	var candidates []*githubAsset
+	for i := range rel.Assets {
+		if ok, _ := path.Match(pattern, strings.ToLower(rel.Assets[i].Name)); ok {
+			candidates = append(candidates, &rel.Assets[i])
+		}
+	}
+	if len(candidates) == 0 {
+		names := make([]string, 0, len(rel.Assets))
+		for _, a := range rel.Assets {
+			names = append(names, a.Name)
+		}
+		return nil, fmt.Errorf("release %s has no asset matching %q; available: %s", rel.TagName, pattern, strings.Join(names, ", "))
+	}
+	for _, c := range candidates {
+		name := strings.ToLower(c.Name)
+		for _, alias := range archAliases[runtime.GOARCH] {
+			if strings.Contains(name, alias) {
+				return c, nil
+			}
+		}
+	}
+	return candidates[0], nil
+}
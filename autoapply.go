@@ -0,0 +1,41 @@
+package main
+
+// AutoApplied reports the strategy started after a test run because of AutoApplyBest.
+type AutoApplied struct {
+	Strategy string `json:"strategy"`
+	// Substituted is the best strategy of the run when it isn't in the current release and
+	// Strategy, the best-scoring one that is, was started instead.
+	Substituted string `json:"substituted,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// applyBestAfterTests starts the best strategy of the results just stored when requested is set or
+// Settings.AutoApplyBest is on, stopping any running one. Nothing is started unless the best
+// strategy passed its tests. It returns nil when nothing was attempted.
+func (s *Service) applyBestAfterTests(requested bool) *AutoApplied {
+	cfg, err := s.configSnapshot()
+	if err != nil || (!requested && !cfg.Settings.AutoApplyBest) {
+		return nil
+	}
+	if r, ok := cfg.TestResults[cfg.BestStrategy]; !ok || r.Status != "ok" {
+		return nil
+	}
+	// The best strategy goes first; the others only stand in for it when its file is gone.
+	names := append([]string{cfg.BestStrategy}, rankedPassing(cfg.TestResults, cfg.Settings.domainWeight())...)
+	for _, name := range names {
+		st, err := s.strategyByName(name)
+		if err != nil || st.Category != categoryGeneral {
+			continue
+		}
+		applied := &AutoApplied{Strategy: st.Name}
+		if name != cfg.BestStrategy {
+			applied.Substituted = cfg.BestStrategy
+			s.logUpdate("best strategy %s is not in the current release, starting %s instead", cfg.BestStrategy, st.Name)
+		}
+		if _, err := s.RunStrategy(st.Name, false); err != nil {
+			applied.Error = err.Error()
+		}
+		return applied
+	}
+	return &AutoApplied{Error: "none of the strategies that passed the tests is in the current release"}
+}
@@ -0,0 +1,80 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	// runKey is the per-user list of programs Windows starts at logon.
+	runKey = `Software\Microsoft\Windows\CurrentVersion\Run`
+	// runValue is the name of the app's entry under runKey.
+	runValue = "ZapretUI"
+)
+
+// autoStartCommand is the command line registered to start the app at logon.
+func autoStartCommand() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return `"` + exe + `" ` + flagMinimized, nil
+}
+
+// autoStartEnabled reports whether the Run entry exists and points at this executable.
+func autoStartEnabled() bool {
+	k, err := registry.OpenKey(registry.CURRENT_USER, runKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer k.Close()
+	v, _, err := k.GetStringValue(runValue)
+	if err != nil {
+		return false
+	}
+	want, err := autoStartCommand()
+	return err == nil && strings.EqualFold(v, want)
+}
+
+// setAutoStart adds or removes the Run entry.
+func setAutoStart(enable bool) error {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, runKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	if !enable {
+		if err := k.DeleteValue(runValue); err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	cmd, err := autoStartCommand()
+	if err != nil {
+		return err
+	}
+	return k.SetStringValue(runValue, cmd)
+}
+
+// autoStartWarning explains why the executable's location is a poor autostart target, if it is.
+func autoStartWarning() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	dir := strings.ToLower(filepath.Dir(exe)) + `\`
+	temp := strings.ToLower(filepath.Clean(os.TempDir())) + `\`
+	switch {
+	case strings.HasPrefix(dir, temp):
+		return "zapret-ui runs from a temporary folder; autostart stops working once it is cleaned up. Move the exe to a permanent folder first."
+	case strings.Contains(dir, `\downloads\`):
+		return "zapret-ui runs from the Downloads folder; autostart breaks if the file is moved or deleted. Consider a permanent folder."
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultUpdateCheckInterval is how often the background checker looks for a new release.
+	defaultUpdateCheckInterval = 6 * time.Hour
+	// eventUpdateAvailable is the Wails event emitted when the background checker finds a new release.
+	eventUpdateAvailable = "update:available"
+	// eventAutoRun is the Wails event carrying the AutoRunEvent of a launch-time auto-run.
+	eventAutoRun = "autorun"
+)
+
+// AutoRunEvent reports the outcome of starting a strategy on launch.
+type AutoRunEvent struct {
+	Strategy string `json:"strategy"`
+	Error    string `json:"error,omitempty"`
+}
+
+// startBackground re-attaches to a test run left by a previous session and launches the periodic
+// update checker, the config.json watcher, the running strategy heartbeat and the failover
+// watchdog; stopBackground cancels the latter four.
+func (s *Service) startBackground() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.bgCancel = cancel
+	s.migrateTestHistory()
+	s.cleanupStaleTest()
+	s.reattachTests()
+	s.failOrphanedTestJob()
+	s.watchReattachedRun()
+	go s.updateCheckLoop(ctx)
+	go s.watchConfig(ctx)
+	go s.runHeartbeat(ctx)
+	go s.failoverWatchdog(ctx)
+	go s.watchPowerResume(ctx)
+}
+
+// autoRun starts the best strategy (or the last one used) when Settings.AutoRunOnLaunch is set,
+// unless a strategy from a previous session is still running or tests are in progress.
+func (s *Service) autoRun() {
+	if _, err := s.State(); err != nil {
+		return
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil || !cfg.Settings.AutoRunOnLaunch || cfg.TestInProgress || s.testing.Load() {
+		return
+	}
+	if cfg.Running != nil && cfg.Running.alive() {
+		return
+	}
+	var st *Strategy
+	for _, name := range []string{cfg.BestStrategy, cfg.LastStrategy} {
+		if name == "" {
+			continue
+		}
+		if st, err = s.strategyByName(name); err == nil {
+			break
+		}
+	}
+	if st == nil {
+		s.emit(eventAutoRun, AutoRunEvent{Error: "no best or last used strategy in the current release"})
+		return
+	}
+	ev := AutoRunEvent{Strategy: st.Name}
+	state, err := s.RunStrategy(st.Name, false)
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	s.emit(eventAutoRun, ev)
+	if state != nil {
+		s.emit(eventState, state)
+	}
+}
+
+// stopBackground stops background goroutines started by startBackground.
+func (s *Service) stopBackground() {
+	if s.bgCancel != nil {
+		s.bgCancel()
+	}
+}
+
+// updateCheckLoop wakes up every minute and runs a latest-release check once the configured
+// interval has passed, so interval changes apply without a restart.
+func (s *Service) updateCheckLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	var lastCheck time.Time
+	notified := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cfg, err := s.configSnapshot()
+		if err != nil || cfg.UpdateCheckDisabled || cfg.TestInProgress {
+			continue
+		}
+		interval := defaultUpdateCheckInterval
+		if cfg.Settings.UpdateCheckHours > 0 {
+			interval = time.Duration(cfg.Settings.UpdateCheckHours) * time.Hour
+		}
+		if time.Since(lastCheck) < interval {
+			continue
+		}
+		lastCheck = time.Now()
+		// Failures (offline, rate limit) are silent; the next interval tries again.
+		ev, err := s.refreshLatest(false)
+		if err != nil || !ev.HasUpdate || ev.LatestTag == notified {
+			continue
+		}
+		notified = ev.LatestTag
+		s.emit(eventUpdateAvailable, ev)
+		trayNotifyUpdate(ev.LatestTag)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// legacyBaseDirPointer is the file in the default base directory that earlier versions used to
+// redirect the app to a custom one. It is still read, and removed once the registry value is set.
+const legacyBaseDirPointer = "basedir.txt"
+
+// baseDirItems are the folders SetBaseDir moves; baseDirFiles are moved with their writers held
+// off, together with the switch.
+var (
+	baseDirItems = []string{"releases", "logs", "custom"}
+	baseDirFiles = []string{"test-history.json", "config.json"}
+)
+
+// dataPaths are the base directory and the standard files and subfolders in it.
+type dataPaths struct {
+	baseDir     string
+	configPath  string
+	historyPath string
+	releasesDir string
+	logsDir     string
+	customDir   string
+}
+
+// resolveBaseDir returns the custom base directory recorded in the registry, or in the legacy
+// pointer file, or the default one.
+func resolveBaseDir() string {
+	def := defaultBaseDir()
+	dir := readBaseDirPointer()
+	if dir == "" {
+		data, err := os.ReadFile(filepath.Join(def, legacyBaseDirPointer))
+		if err != nil {
+			return def
+		}
+		dir = strings.TrimSpace(string(data))
+	}
+	if dir == "" || !filepath.IsAbs(dir) {
+		return def
+	}
+	return dir
+}
+
+// dirs returns the current data folder paths.
+func (s *Service) dirs() dataPaths {
+	s.pathsMu.RLock()
+	defer s.pathsMu.RUnlock()
+	return s.paths
+}
+
+// setPaths points the service at base and its standard subfolders.
+func (s *Service) setPaths(base string) {
+	s.pathsMu.Lock()
+	defer s.pathsMu.Unlock()
+	s.paths = dataPaths{
+		baseDir:     base,
+		configPath:  filepath.Join(base, "config.json"),
+		historyPath: filepath.Join(base, "test-history.json"),
+		releasesDir: filepath.Join(base, "releases"),
+		logsDir:     filepath.Join(base, "logs"),
+		customDir:   filepath.Join(base, "custom"),
+	}
+}
+
+// SetBaseDir moves config, releases, logs and custom strategies to dir and makes it the base directory for future launches.
+// Everything is copied first and the old folder is only cleaned up after the switch, so a failed
+// copy leaves the app on the old folder with nothing lost.
+func (s *Service) SetBaseDir(dir string) (*State, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Running != nil && cfg.Running.alive() {
+		return nil, errors.New("stop the running strategy before moving the data folder")
+	}
+	if cfg.TestInProgress {
+		return nil, errors.New("cannot move the data folder while tests are running")
+	}
+	_, done, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	dir = strings.TrimSpace(dir)
+	if dir == "" || !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("data folder must be an absolute path: %q", dir)
+	}
+	dir = filepath.Clean(dir)
+	old := s.dirs().baseDir
+	if strings.EqualFold(dir, filepath.Clean(old)) {
+		return s.State()
+	}
+	if rel, err := filepath.Rel(old, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return nil, errors.New("data folder cannot be moved inside itself")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); err == nil {
+		return nil, fmt.Errorf("%s already contains zapret-ui data", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return nil, fmt.Errorf("data folder is not writable: %w", err)
+	}
+	probe.Close()
+	_ = os.Remove(probe.Name())
+
+	var copied []string
+	undo := func() {
+		for _, p := range copied {
+			_ = os.RemoveAll(p)
+		}
+	}
+	copyItem := func(name string) error {
+		dst := filepath.Join(dir, name)
+		ok, err := copyPath(filepath.Join(old, name), dst)
+		if err != nil {
+			_ = os.RemoveAll(dst)
+			undo()
+			return fmt.Errorf("copying %s: %w", name, err)
+		}
+		if ok {
+			copied = append(copied, dst)
+		}
+		return nil
+	}
+	for _, name := range baseDirItems {
+		if err := copyItem(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// The history and the config are copied with their writers held off, so nothing written to
+	// the old folder after the copy is lost.
+	s.historyMu.Lock()
+	s.cfgMu.Lock()
+	err = s.switchBaseDir(dir, copyItem, undo)
+	s.cfgMu.Unlock()
+	s.historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range append(baseDirItems, baseDirFiles...) {
+		if err := os.RemoveAll(filepath.Join(old, name)); err != nil {
+			s.logUpdate("removing %s from the old data folder %s: %v", name, old, err)
+		}
+	}
+	return s.State()
+}
+
+// switchBaseDir is the part of SetBaseDir that runs with historyMu and cfgMu held: it saves the
+// config, copies the history and the config with copyItem, records dir for future launches and
+// points the service at it. On failure everything copied is removed with undo.
+func (s *Service) switchBaseDir(dir string, copyItem func(name string) error, undo func()) error {
+	if err := s.saveConfig(); err != nil {
+		undo()
+		return err
+	}
+	for _, name := range baseDirFiles {
+		if err := copyItem(name); err != nil {
+			return err
+		}
+	}
+	def := filepath.Clean(defaultBaseDir())
+	pointer := dir
+	if strings.EqualFold(dir, def) {
+		pointer = ""
+	}
+	if err := writeBaseDirPointer(pointer); err != nil {
+		undo()
+		return fmt.Errorf("recording the data folder: %w", err)
+	}
+	_ = os.Remove(filepath.Join(def, legacyBaseDirPointer))
+	s.setPaths(dir)
+	if info, err := os.Stat(s.dirs().configPath); err == nil {
+		s.configMod = info.ModTime()
+	}
+	return nil
+}
+
+// copyPath copies src to dst and reports whether there was anything to copy. A missing src is not
+// an error.
+func copyPath(src, dst string) (bool, error) {
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return true, copyDir(src, dst)
+	}
+	return true, copyFile(src, dst, info.Mode())
+}
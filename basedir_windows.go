@@ -0,0 +1,47 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	// appKey is the app's per-user settings key.
+	appKey = `Software\ZapretUI`
+	// baseDirValue is the value under appKey that redirects the app to a custom data folder.
+	baseDirValue = "BaseDir"
+)
+
+// readBaseDirPointer returns the data folder recorded by writeBaseDirPointer, or "" if none is.
+func readBaseDirPointer() string {
+	k, err := registry.OpenKey(registry.CURRENT_USER, appKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer k.Close()
+	v, _, err := k.GetStringValue(baseDirValue)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// writeBaseDirPointer records dir as the data folder for future launches; "" goes back to the
+// default one.
+func writeBaseDirPointer(dir string) error {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, appKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	if dir == "" {
+		if err := k.DeleteValue(baseDirValue); err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return k.SetStringValue(baseDirValue, dir)
+}
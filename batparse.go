@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// StrategyDetails summarises the winws.exe arguments of a strategy .bat.
+type StrategyDetails struct {
+	// Profiles is the number of filter profiles, separated by --new.
+	Profiles      int      `json:"profiles"`
+	Hostlists     []string `json:"hostlists,omitempty"`
+	IPSets        []string `json:"ipsets,omitempty"`
+	DesyncMethods []string `json:"desyncMethods,omitempty"`
+	TCPPorts      []string `json:"tcpPorts,omitempty"`
+	UDPPorts      []string `json:"udpPorts,omitempty"`
+	// FakeOptions are the --dpi-desync-fake* options as name=value, with file paths shortened to their base name.
+	FakeOptions []string `json:"fakeOptions,omitempty"`
+	// Raw is the argument string after variable expansion; it is all there is when Parsed is false.
+	Raw    string `json:"raw"`
+	Parsed bool   `json:"parsed"`
+	// Files are the paths of winws.exe and of the list and fake files the call references.
+	Files []string `json:"-"`
+}
+
+var (
+	// batSetRe matches `set NAME=value` and `set "NAME=value"`.
+	batSetRe = regexp.MustCompile(`(?i)^\s*set\s+"?([A-Za-z_][A-Za-z0-9_]*)=([^"]*)"?\s*$`)
+	batVarRe = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+	// batContinuationRe matches a trailing ^, which continues the command on the next line.
+	batContinuationRe = regexp.MustCompile(`\^[ \t]*\n`)
+)
+
+// parseStrategyFile reads a strategy .bat that runs from dir and summarises its winws.exe call.
+// It returns nil when the file doesn't launch winws.exe.
+func parseStrategyFile(path, dir string) *StrategyDetails {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseStrategyBat(string(data), dir+`\`)
+}
+
+// parseStrategyBat does the work of parseStrategyFile; dp0 is what %~dp0 expands to.
+func parseStrategyBat(content, dp0 string) *StrategyDetails {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = batContinuationRe.ReplaceAllString(content, " ")
+
+	vars := map[string]string{}
+	expand := func(s string) string {
+		s = strings.ReplaceAll(s, "%~dp0", dp0)
+		return batVarRe.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := vars[strings.ToUpper(m[1:len(m)-1])]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if m := batSetRe.FindStringSubmatch(line); m != nil {
+			vars[strings.ToUpper(m[1])] = expand(m[2])
+			continue
+		}
+		line = expand(line)
+		i := strings.Index(strings.ToLower(line), "winws.exe")
+		if i < 0 {
+			continue
+		}
+		args := strings.TrimSpace(strings.TrimPrefix(line[i+len("winws.exe"):], `"`))
+		d := summariseWinwsArgs(args)
+		exe := line[:i+len("winws.exe")]
+		if j := strings.LastIndexAny(exe, `" `); j >= 0 {
+			exe = exe[j+1:]
+		}
+		if strings.ContainsAny(exe, `\/`) {
+			d.Files = append([]string{exe}, d.Files...)
+		}
+		return d
+	}
+	return nil
+}
+
+// summariseWinwsArgs extracts the interesting options from a winws.exe argument string.
+func summariseWinwsArgs(args string) *StrategyDetails {
+	d := &StrategyDetails{Raw: args, Profiles: 1}
+	add := func(list *[]string, values ...string) {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" && !containsString(*list, v) {
+				*list = append(*list, v)
+			}
+		}
+	}
+	for _, tok := range splitBatArgs(args) {
+		if tok == "--new" {
+			d.Profiles++
+			d.Parsed = true
+			continue
+		}
+		key, value, ok := strings.Cut(tok, "=")
+		if !ok || !strings.HasPrefix(key, "--") {
+			continue
+		}
+		switch {
+		case key == "--hostlist" || key == "--hostlist-exclude":
+			add(&d.Hostlists, baseName(value))
+			add(&d.Files, value)
+		case key == "--ipset" || key == "--ipset-exclude":
+			add(&d.IPSets, baseName(value))
+			add(&d.Files, value)
+		case key == "--dpi-desync":
+			add(&d.DesyncMethods, strings.Split(value, ",")...)
+		case key == "--wf-tcp" || key == "--filter-tcp":
+			add(&d.TCPPorts, strings.Split(value, ",")...)
+		case key == "--wf-udp" || key == "--filter-udp":
+			add(&d.UDPPorts, strings.Split(value, ",")...)
+		case strings.HasPrefix(key, "--dpi-desync-fake"):
+			add(&d.FakeOptions, strings.TrimPrefix(key, "--")+"="+baseName(value))
+			// Fake options also take hex blobs and keywords; only paths are files.
+			if strings.ContainsAny(value, `\/`) {
+				add(&d.Files, value)
+			}
+		default:
+			continue
+		}
+		d.Parsed = true
+	}
+	return d
+}
+
+// fingerprint identifies the effective winws arguments: each profile's options are sorted and
+// file paths reduced to lowercase base names, so scripts that differ only in option order or in
+// where they point %~dp0 get the same fingerprint.
+func (d *StrategyDetails) fingerprint() string {
+	var profiles []string
+	var cur []string
+	flush := func() {
+		sort.Strings(cur)
+		profiles = append(profiles, strings.Join(cur, " "))
+		cur = nil
+	}
+	for _, tok := range splitBatArgs(d.Raw) {
+		if tok == "--new" {
+			flush()
+			continue
+		}
+		if key, value, ok := strings.Cut(tok, "="); ok && strings.ContainsAny(value, `\/`) {
+			tok = key + "=" + strings.ToLower(baseName(value))
+		}
+		cur = append(cur, tok)
+	}
+	flush()
+	sum := sha256.Sum256([]byte(strings.Join(profiles, " --new ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// splitBatArgs splits a command line on spaces outside double quotes and drops the quotes.
+func splitBatArgs(s string) []string {
+	var res []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			if cur.Len() > 0 {
+				res = append(res, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		res = append(res, cur.String())
+	}
+	return res
+}
+
+// baseName shortens a Windows or slash path to its last element; other values are returned as is.
+func baseName(v string) string {
+	if i := strings.LastIndexAny(v, `\/`); i >= 0 {
+		return v[i+1:]
+	}
+	return v
+}
+
+// cp866High maps the bytes 0x80-0xFF of code page 866, the OEM code page of Russian Windows.
+const cp866High = "АБВГДЕЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдежзийклмноп░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀рстуфхцчшщъыьэюяЁёЄєЇїЎў°∙·√№¤■ "
+
+// decodeBatText converts the contents of a .bat file to a Go string and names the encoding it was
+// in: "utf-8-bom", "utf-8" or "cp866" for anything that isn't valid UTF-8.
+func decodeBatText(data []byte) (string, string) {
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		return string(data[3:]), "utf-8-bom"
+	}
+	if utf8.Valid(data) {
+		return string(data), "utf-8"
+	}
+	high := []rune(cp866High)
+	var b strings.Builder
+	b.Grow(len(data) * 2)
+	for _, c := range data {
+		if c < 0x80 {
+			b.WriteByte(c)
+		} else {
+			b.WriteRune(high[c-0x80])
+		}
+	}
+	return b.String(), "cp866"
+}
+
+// decodeScriptOutput converts text written by PowerShell to a Go string with \n line endings.
+// Depending on the system it is UTF-16 (usually with a byte order mark), UTF-8 with or without
+// one, or the OEM code page; the last two are handled by decodeBatText.
+func decodeScriptOutput(data []byte) string {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		text = decodeUTF16(data[2:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		text = decodeUTF16(data[2:], binary.BigEndian)
+	default:
+		if order := unmarkedUTF16(data); order != nil {
+			text = decodeUTF16(data, order)
+		} else {
+			text, _ = decodeBatText(data)
+		}
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// unmarkedUTF16 guesses the byte order of UTF-16 text without a byte order mark, or returns nil
+// for text that doesn't look like UTF-16. Such text has a NUL next to every ASCII character, and
+// test output is mostly ASCII.
+func unmarkedUTF16(data []byte) binary.ByteOrder {
+	sample := data[:min(len(data), 512)&^1]
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	switch half := len(sample) / 2; {
+	case half == 0:
+		return nil
+	case odd*3 > half && even == 0:
+		return binary.LittleEndian
+	case even*3 > half && odd == 0:
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// decodeUTF16 decodes UTF-16 text in the given byte order; a trailing odd byte is dropped.
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
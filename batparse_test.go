@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resultFixtures are the same test script output saved in each encoding PowerShell may write.
+var resultFixtures = []string{
+	"results_utf8.txt",
+	"results_utf8_bom.txt",
+	"results_utf16le_bom.txt",
+	"results_utf16be_bom.txt",
+	"results_utf16le.txt",
+	"results_cp866.txt",
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeScriptOutput(t *testing.T) {
+	want := strings.ReplaceAll(string(readFixture(t, "results_utf8.txt")), "\r\n", "\n")
+	for _, name := range resultFixtures {
+		t.Run(name, func(t *testing.T) {
+			got := decodeScriptOutput(readFixture(t, name))
+			if got != want {
+				t.Errorf("decoded to\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// configWatchInterval is how often config.json is checked for edits made outside the app.
+const configWatchInterval = 2 * time.Second
+
+// watchConfig reloads config.json whenever it is modified outside the app.
+func (s *Service) watchConfig(ctx context.Context) {
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadConfigIfChanged()
+		}
+	}
+}
+
+// reloadConfigIfChanged replaces the in-memory config with an externally edited config.json.
+// Process tracking (running strategy, test run) is kept from memory since the file can't know
+// about it. The refreshed state is emitted as "state:changed".
+func (s *Service) reloadConfigIfChanged() {
+	info, err := os.Stat(s.dirs().configPath)
+	if err != nil {
+		return
+	}
+	s.cfgMu.Lock()
+	cur := s.config
+	if cur == nil || info.ModTime().Equal(s.configMod) {
+		s.cfgMu.Unlock()
+		return
+	}
+	s.configMod = info.ModTime()
+	data, err := os.ReadFile(s.dirs().configPath)
+	next := newConfig()
+	if err == nil {
+		err = json.Unmarshal(data, next)
+	}
+	if err != nil {
+		s.cfgMu.Unlock()
+		// Likely saved halfway by an editor; the next app save writes a valid file again.
+		s.logUpdate("ignoring unreadable config.json edit: %v", err)
+		return
+	}
+	if next.TestResults == nil {
+		next.TestResults = make(map[string]TestResult)
+	}
+	if next.Meta == nil {
+		next.Meta = make(map[string]interface{})
+	}
+	next.Running = cur.Running
+	next.TestInProgress, next.TestPID, next.TestStartedAt, next.TestLog = cur.TestInProgress, cur.TestPID, cur.TestStartedAt, cur.TestLog
+	next.TestTargets, next.TestJob = cur.TestTargets, cur.TestJob
+	proxyChanged := next.ProxyURL != cur.ProxyURL
+	// Keep the pointer: loadConfig hands it out.
+	*cur = *next
+	s.cfgMu.Unlock()
+
+	s.logUpdate("reloaded config.json after an edit outside the app")
+	if proxyChanged {
+		if err := s.applyProxy(next.ProxyURL); err != nil {
+			s.logUpdate("proxy from edited config.json ignored: %v", err)
+		}
+		s.invalidateLatest()
+	}
+	if state, err := s.State(); err == nil {
+		s.emit(eventState, state)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of a Conflict.
+const (
+	conflictZapretService = "zapret-service"
+	conflictGoodbyeDPI    = "goodbyedpi"
+	conflictByeDPI        = "byedpi"
+	conflictWinws         = "winws"
+	conflictVPN           = "vpn"
+)
+
+// byeDPIImages are the executables of ByeDPI and its Windows front-ends.
+var byeDPIImages = []string{"ciadpi.exe", "byedpi.exe"}
+
+// Conflict is another DPI bypass tool, or a VPN, active alongside the app's strategies. Two tools
+// rewriting the same traffic fail in ways that look like a bad strategy.
+type Conflict struct {
+	// Kind is "zapret-service", "goodbyedpi", "byedpi", "winws" or "vpn".
+	Kind string `json:"kind"`
+	// Name is the service, executable or network adapter found.
+	Name string `json:"name"`
+	PID  int    `json:"pid,omitempty"`
+	// Warning conflicts don't keep a strategy from starting: a VPN only matters when it carries
+	// the traffic the strategy targets.
+	Warning bool `json:"warning,omitempty"`
+}
+
+func (c Conflict) String() string {
+	if c.PID != 0 {
+		return fmt.Sprintf("%s (PID %d)", c.Name, c.PID)
+	}
+	return c.Name
+}
+
+// ConflictError is returned by RunStrategy without force when Conflicts are active.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	names := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		names[i] = c.String()
+	}
+	return "other DPI bypass tools are running: " + strings.Join(names, ", ") + "; stop them or start the strategy anyway"
+}
+
+// detectConflicts looks for the zapret Windows service, GoodbyeDPI, ByeDPI, connected VPN
+// adapters and, with winws set, winws.exe processes other than the tracked strategy's.
+func (s *Service) detectConflicts(cfg *Config, winws bool) []Conflict {
+	var res []Conflict
+	ws := queryWinService(nil)
+	if ws.Running {
+		name := "zapret service"
+		if ws.Strategy != "" {
+			name += " (" + ws.Strategy + ")"
+		}
+		res = append(res, Conflict{Kind: conflictZapretService, Name: name})
+	}
+	if serviceRunning("GoodbyeDPI") {
+		res = append(res, Conflict{Kind: conflictGoodbyeDPI, Name: "GoodbyeDPI service"})
+	}
+	for _, pid := range processesNamed("goodbyedpi.exe") {
+		res = append(res, Conflict{Kind: conflictGoodbyeDPI, Name: "goodbyedpi.exe", PID: pid})
+	}
+	for _, image := range byeDPIImages {
+		for _, pid := range processesNamed(image) {
+			res = append(res, Conflict{Kind: conflictByeDPI, Name: image, PID: pid})
+		}
+	}
+	if winws {
+		for _, pid := range processesNamed("winws.exe") {
+			if r := cfg.Running; r != nil && (pid == r.WinwsPID || pid == r.PID) {
+				continue
+			}
+			// Already reported as the zapret service.
+			if ws.Running && strings.EqualFold(processParentName(pid), "services.exe") {
+				continue
+			}
+			res = append(res, Conflict{Kind: conflictWinws, Name: "winws.exe not started by the app", PID: pid})
+		}
+	}
+	for _, a := range connectedVPNAdapters() {
+		res = append(res, Conflict{Kind: conflictVPN, Name: a, Warning: true})
+	}
+	return res
+}
+
+// blockingConflicts drops the warnings from conflicts.
+func blockingConflicts(conflicts []Conflict) []Conflict {
+	var res []Conflict
+	for _, c := range conflicts {
+		if !c.Warning {
+			res = append(res, c)
+		}
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package main
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// vpnAdapterMarkers identify the virtual adapters of common VPN clients by their description.
+var vpnAdapterMarkers = []string{"tap-windows", "tap-win32", "wintun", "wireguard", "openvpn"}
+
+// serviceRunning reports whether the service name exists and isn't stopped.
+func serviceRunning(name string) bool {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return false
+	}
+	defer windows.CloseServiceHandle(scm)
+	h, err := windows.OpenService(scm, windows.StringToUTF16Ptr(name), windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return false
+	}
+	defer windows.CloseServiceHandle(h)
+	var st windows.SERVICE_STATUS
+	return windows.QueryServiceStatus(h, &st) == nil && st.CurrentState != windows.SERVICE_STOPPED
+}
+
+// connectedVPNAdapters returns the names of the VPN adapters that are up.
+func connectedVPNAdapters() []string {
+	size := uint32(15000)
+	var buf []byte
+	for i := 0; i < 3; i++ {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, windows.GAA_FLAG_SKIP_ANYCAST|windows.GAA_FLAG_SKIP_MULTICAST|windows.GAA_FLAG_SKIP_DNS_SERVER, 0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return nil
+		}
+		buf = nil
+	}
+	if buf == nil {
+		return nil
+	}
+	var res []string
+	for a := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); a != nil; a = a.Next {
+		if a.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		desc := strings.ToLower(windows.UTF16PtrToString(a.Description))
+		for _, m := range vpnAdapterMarkers {
+			if strings.Contains(desc, m) {
+				res = append(res, windows.UTF16PtrToString(a.FriendlyName))
+				break
+			}
+		}
+	}
+	return res
+}
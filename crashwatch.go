@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// eventStrategyCrashed carries a CrashEvent when the running strategy's winws exits on its own.
+const eventStrategyCrashed = "strategy:crashed"
+
+const (
+	defaultRestartAttempts = 3
+	maxRestartAttempts     = 10
+	// restartBackoff is the wait before the first restart in a row; it doubles with each further one.
+	restartBackoff = 5 * time.Second
+	// restartStableAfter is how long a strategy must run before its next crash starts a new series.
+	restartStableAfter = 5 * time.Minute
+)
+
+// CrashEvent describes an unexpected exit of the running strategy and what was done about it.
+type CrashEvent struct {
+	Strategy string `json:"strategy"`
+	// Attempt counts the crashes in a row, the restart after this one included.
+	Attempt   int  `json:"attempt"`
+	Restarted bool `json:"restarted"`
+	// GaveUp is set when the strategy crashed too often in a row to be restarted again.
+	GaveUp bool   `json:"gaveUp,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// crashSeries counts the crashes of a strategy that kept crashing soon after being started.
+type crashSeries struct {
+	file  string
+	count int
+}
+
+// watchRun watches the winws.exe of run for an unexpected exit; stopRunning cancels the watch so a
+// deliberate stop isn't taken for a crash. Runs without a known winws PID aren't watched: their
+// launcher exits right after starting winws. The caller holds runMu.
+func (s *Service) watchRun(run RunningInfo) {
+	if run.WinwsPID <= 0 || !isProcessRunning(run.WinwsPID, "winws.exe") {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.runWatch = cancel
+	go s.awaitCrash(ctx, run)
+}
+
+// watchReattachedRun watches a strategy a previous session started and left running.
+func (s *Service) watchReattachedRun() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if cfg, err := s.configSnapshot(); err == nil && cfg.Running != nil && s.runWatch == nil {
+		s.watchRun(*cfg.Running)
+	}
+}
+
+// awaitCrash waits for the winws of run to exit and, with Settings.RestartOnCrash, restarts the
+// strategy after a growing delay until it crashes more than Settings.RestartAttempts times in a row.
+func (s *Service) awaitCrash(ctx context.Context, run RunningInfo) {
+	if waitProcessExit(ctx, run.WinwsPID) != nil {
+		return
+	}
+	s.runMu.Lock()
+	if ctx.Err() != nil {
+		// Stopped on purpose while the exit was being noticed.
+		s.runMu.Unlock()
+		return
+	}
+	now := time.Now()
+	_ = s.updateConfig(func(cfg *Config) error {
+		if cfg.Running != nil && cfg.Running.StartedAt.Equal(run.StartedAt) {
+			recordRunEnd(cfg, cfg.Running, now)
+			cfg.Running = nil
+		}
+		recordCrash(cfg, run.File)
+		return nil
+	})
+	if run.Staged != "" {
+		_ = os.Remove(run.Staged)
+	}
+	s.closeRunJob()
+	if s.crashes.file != run.File || now.Sub(run.StartedAt) >= restartStableAfter {
+		s.crashes = crashSeries{file: run.File}
+	}
+	s.crashes.count++
+	ev := CrashEvent{Strategy: run.File, Attempt: s.crashes.count}
+	cfg, err := s.configSnapshot()
+	// A restart in the middle of a test run would skew its results.
+	restart := err == nil && cfg.Settings.RestartOnCrash && !cfg.TestInProgress && !s.testing.Load()
+	if restart && ev.Attempt > orDefault(cfg.Settings.RestartAttempts, defaultRestartAttempts) {
+		restart, ev.GaveUp = false, true
+		s.crashes = crashSeries{}
+	}
+	if !restart {
+		s.runWatch = nil
+	}
+	s.runMu.Unlock()
+	s.logUpdate("%s exited unexpectedly (crash %d in a row)", run.File, ev.Attempt)
+
+	if !restart {
+		if ev.GaveUp {
+			s.notify("Strategy keeps crashing", fmt.Sprintf("%s exited %d times in a row and won't be restarted again", run.File, ev.Attempt))
+		}
+		s.emitCrash(ev, nil)
+		return
+	}
+	// A strategy started or stopped meanwhile cancels ctx and with it the restart.
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(restartBackoff << (ev.Attempt - 1)):
+	}
+	s.runMu.Lock()
+	if ctx.Err() != nil {
+		s.runMu.Unlock()
+		return
+	}
+	state, err := s.launchStrategy(run.File, false, &run)
+	if err == nil {
+		ev.Restarted = true
+	}
+	s.runMu.Unlock()
+	if err != nil {
+		ev.Error = err.Error()
+		s.logUpdate("restarting %s failed: %v", run.File, err)
+	} else {
+		s.logUpdate("restarted %s", run.File)
+	}
+	s.emitCrash(ev, state)
+}
+
+// emitCrash sends ev and the state that goes with it.
+func (s *Service) emitCrash(ev CrashEvent, state *State) {
+	s.emit(eventStrategyCrashed, ev)
+	if state == nil {
+		state, _ = s.State()
+	}
+	if state != nil {
+		s.emit(eventState, state)
+	}
+}
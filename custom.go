@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// customSuffix is appended to a custom strategy whose file name the release also uses.
+const customSuffix = " (custom)"
+
+// customFile is a .bat in the custom strategies folder.
+type customFile struct {
+	name string
+	path string
+}
+
+// customFiles lists the .bat files in the custom strategies folder by name.
+func (s *Service) customFiles() []customFile {
+	entries, err := os.ReadDir(s.dirs().customDir)
+	if err != nil {
+		return nil
+	}
+	var res []customFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".bat") {
+			continue
+		}
+		res = append(res, customFile{name: e.Name(), path: filepath.Join(s.dirs().customDir, e.Name())})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// customName is the strategy name shown for a custom file that collides with a release file.
+func customName(name string) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + customSuffix + ext
+}
+
+// isStagedCopy reports whether the release file is a copy of a custom strategy placed there by
+// stageCustom, rather than a strategy shipped with the release.
+func isStagedCopy(name, path string, custom []customFile) bool {
+	for _, c := range custom {
+		if strings.EqualFold(name, c.name) || strings.EqualFold(name, customName(c.name)) {
+			return sameFileContent(path, c.path)
+		}
+	}
+	return false
+}
+
+// stageCustom copies a custom strategy into the current release under its strategy name, so that
+// %~dp0 in the script resolves to the release and its bin and lists folders are found.
+func stageCustom(st *Strategy, current string) (string, error) {
+	dst := filepath.Join(current, st.Name)
+	if err := copyFile(st.File, dst, 0o644); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// stageCustomForTests stages every custom general strategy so the test script picks it up, and
+// returns a func that removes the copies again. Duplicates are left out when
+// Settings.SkipDuplicateTests is set.
+func (s *Service) stageCustomForTests(current string) func() {
+	strategies, _ := s.listStrategies()
+	skipDuplicates := false
+	if cfg, err := s.configSnapshot(); err == nil {
+		skipDuplicates = cfg.Settings.SkipDuplicateTests
+	}
+	var staged []string
+	for i := range strategies {
+		st := &strategies[i]
+		if !st.Custom || st.Category != categoryGeneral || (skipDuplicates && st.DuplicateOf != "") {
+			continue
+		}
+		if path, err := stageCustom(st, current); err == nil {
+			staged = append(staged, path)
+		}
+	}
+	return func() {
+		for _, p := range staged {
+			_ = os.Remove(p)
+		}
+	}
+}
+
+// StrategyOptions describe a custom strategy derived from an existing one. Empty fields keep what
+// the base strategy has.
+type StrategyOptions struct {
+	// Base is the name of the bundled or custom strategy whose script is copied.
+	Base string `json:"base"`
+	// Hostlist replaces every --hostlist file; it names a file in the release's lists folder.
+	Hostlist string `json:"hostlist,omitempty"`
+	// Desync replaces every --dpi-desync value, e.g. "fake,multisplit".
+	Desync string `json:"desync,omitempty"`
+	// Repeats replaces every --dpi-desync-repeats value when positive.
+	Repeats int `json:"repeats,omitempty"`
+	// FakeTLS and FakeQUIC replace the fake packet files; they name files in the release's bin folder.
+	FakeTLS  string `json:"fakeTls,omitempty"`
+	FakeQUIC string `json:"fakeQuic,omitempty"`
+}
+
+var (
+	hostlistArgRe = regexp.MustCompile(`(--hostlist=)("[^"]*"|[^\s^]+)`)
+	desyncArgRe   = regexp.MustCompile(`(--dpi-desync=)([^\s^"]+)`)
+	repeatsArgRe  = regexp.MustCompile(`(--dpi-desync-repeats=)(\d+)`)
+	fakeTLSArgRe  = regexp.MustCompile(`(--dpi-desync-fake-tls=)("[^"]*"|[^\s^]+)`)
+	fakeQUICArgRe = regexp.MustCompile(`(--dpi-desync-fake-quic=)("[^"]*"|[^\s^]+)`)
+	desyncValueRe = regexp.MustCompile(`^[a-z0-9,-]+$`)
+	winwsFlagRe   = regexp.MustCompile(`^--[a-z0-9][a-z0-9-]*$`)
+)
+
+// strategyHeader starts the comment line the app writes at the top of the strategies it generates.
+const strategyHeader = ":: zapret-ui:"
+
+// CreateStrategy renders a new custom strategy from opts. name must not be taken by a bundled
+// or custom strategy.
+func (s *Service) CreateStrategy(name string, opts StrategyOptions) (*State, error) {
+	name, err := customFileName(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.strategyByName(name); err == nil {
+		return nil, fmt.Errorf("a strategy named %s already exists", name)
+	}
+	if err := s.writeCustomStrategy(name, opts); err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// UpdateStrategy re-renders the custom strategy name from opts.
+func (s *Service) UpdateStrategy(name string, opts StrategyOptions) (*State, error) {
+	st, err := s.strategyByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !st.Custom {
+		return nil, fmt.Errorf("%s comes with the release and can't be edited", st.Name)
+	}
+	if err := s.writeCustomStrategy(filepath.Base(st.File), opts); err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// DeleteStrategy removes the custom strategy name. A running strategy must be stopped first.
+func (s *Service) DeleteStrategy(name string) (*State, error) {
+	st, err := s.strategyByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !st.Custom {
+		return nil, fmt.Errorf("%s comes with the release and can't be deleted", st.Name)
+	}
+	if cfg, err := s.configSnapshot(); err == nil && cfg.Running != nil && strings.EqualFold(cfg.Running.File, st.Name) && cfg.Running.alive() {
+		return nil, fmt.Errorf("stop %s before deleting it", st.Name)
+	}
+	if err := os.Remove(st.File); err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// customFileName validates a strategy name and adds the .bat extension if missing.
+func customFileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !strings.HasSuffix(strings.ToLower(name), ".bat") {
+		name += ".bat"
+	}
+	if len(name) <= len(".bat") || name != filepath.Base(name) || strings.ContainsAny(name, `<>:"/\|?*%^&`) {
+		return "", fmt.Errorf("invalid strategy name %q", name)
+	}
+	return name, nil
+}
+
+// writeCustomStrategy renders opts into customDir/file.
+func (s *Service) writeCustomStrategy(file string, opts StrategyOptions) error {
+	current := s.currentReleasePath()
+	if current == "" {
+		return errors.New("no current release")
+	}
+	base, err := s.strategyByName(opts.Base)
+	if err != nil {
+		return fmt.Errorf("base strategy: %w", err)
+	}
+	data, err := os.ReadFile(base.File)
+	if err != nil {
+		return err
+	}
+	text, err := renderStrategy(string(data), current, opts)
+	if err != nil {
+		return err
+	}
+	text = fmt.Sprintf("%s based on %s\r\n", strategyHeader, base.Name) + text
+	if err := os.MkdirAll(s.dirs().customDir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dirs().customDir, file), []byte(text), 0o644)
+}
+
+// renderStrategy applies opts to the script of a base strategy. Referenced files must exist in
+// the current release. The result uses CRLF line endings and no BOM, which cmd.exe expects.
+func renderStrategy(text, current string, opts StrategyOptions) (string, error) {
+	text = stripStrategyHeader(text)
+	if !strings.Contains(strings.ToLower(text), "winws.exe") {
+		return "", errors.New("base strategy doesn't start winws.exe")
+	}
+	releaseFile := func(dir, name string) (string, error) {
+		if name != filepath.Base(name) {
+			return "", fmt.Errorf("invalid file name %q", name)
+		}
+		if _, err := os.Stat(filepath.Join(current, dir, name)); err != nil {
+			return "", fmt.Errorf("%s/%s is not in the current release", dir, name)
+		}
+		return `${1}"%~dp0` + dir + `\` + name + `"`, nil
+	}
+	if opts.Hostlist != "" {
+		repl, err := releaseFile("lists", opts.Hostlist)
+		if err != nil {
+			return "", err
+		}
+		text = hostlistArgRe.ReplaceAllString(text, repl)
+	}
+	if opts.FakeTLS != "" {
+		repl, err := releaseFile("bin", opts.FakeTLS)
+		if err != nil {
+			return "", err
+		}
+		text = fakeTLSArgRe.ReplaceAllString(text, repl)
+	}
+	if opts.FakeQUIC != "" {
+		repl, err := releaseFile("bin", opts.FakeQUIC)
+		if err != nil {
+			return "", err
+		}
+		text = fakeQUICArgRe.ReplaceAllString(text, repl)
+	}
+	if opts.Desync != "" {
+		if !desyncValueRe.MatchString(opts.Desync) {
+			return "", fmt.Errorf("invalid desync methods %q", opts.Desync)
+		}
+		text = desyncArgRe.ReplaceAllString(text, "${1}"+opts.Desync)
+	}
+	if opts.Repeats < 0 {
+		return "", errors.New("repeats must not be negative")
+	}
+	if opts.Repeats > 0 {
+		text = repeatsArgRe.ReplaceAllString(text, fmt.Sprintf("${1}%d", opts.Repeats))
+	}
+	return strings.ReplaceAll(text, "\n", "\r\n"), nil
+}
+
+// stripStrategyHeader drops a BOM and a header written by the app, so deriving from a custom
+// strategy doesn't stack headers, and converts line endings to LF.
+func stripStrategyHeader(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.HasPrefix(text, strategyHeader) {
+		if i := strings.Index(text, "\n"); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return text
+}
+
+// CloneStrategy copies the bundled or custom strategy source into the custom folder as newName and
+// applies overrides to its winws.exe call. Overrides map a flag such as "--dpi-desync-ttl" to its
+// value ("" for a flag without one); flags the source doesn't use are appended to the call.
+func (s *Service) CloneStrategy(source, newName string, overrides map[string]string) (*State, error) {
+	name, err := customFileName(newName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.strategyByName(name); err == nil {
+		return nil, fmt.Errorf("a strategy named %s already exists", name)
+	}
+	src, err := s.strategyByName(source)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(src.File)
+	if err != nil {
+		return nil, err
+	}
+	text, err := overrideWinwsArgs(stripStrategyHeader(string(data)), overrides)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("%s cloned from %s on %s\n", strategyHeader, src.Name, time.Now().Format("2006-01-02 15:04"))
+	text = strings.ReplaceAll(header+text, "\n", "\r\n")
+	if err := os.MkdirAll(s.dirs().customDir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dirs().customDir, name), []byte(text), 0o644); err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// overrideWinwsArgs sets each flag in overrides on the winws.exe call in text, which uses LF line
+// endings, replacing every existing value or appending the flag to the end of the call.
+func overrideWinwsArgs(text string, overrides map[string]string) (string, error) {
+	lines := strings.Split(text, "\n")
+	// first and last are the lines of the winws.exe call, following ^ continuations.
+	first, last := -1, -1
+	for i, l := range lines {
+		if strings.Contains(strings.ToLower(l), "winws.exe") {
+			first, last = i, i
+			for last < len(lines)-1 && strings.HasSuffix(strings.TrimRight(lines[last], " \t"), "^") {
+				last++
+			}
+			break
+		}
+	}
+	if last < 0 {
+		return "", errors.New("source strategy doesn't start winws.exe")
+	}
+	flags := make([]string, 0, len(overrides))
+	for flag := range overrides {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		value := overrides[flag]
+		if !strings.HasPrefix(flag, "--") {
+			flag = "--" + flag
+		}
+		if !winwsFlagRe.MatchString(flag) {
+			return "", fmt.Errorf("invalid winws flag %q", flag)
+		}
+		if strings.ContainsAny(value, "\r\n&|<>^") {
+			return "", fmt.Errorf("invalid value for %s: %q", flag, value)
+		}
+		arg := flag
+		if value != "" {
+			arg += "=" + value
+		}
+		re := regexp.MustCompile(`(?m)(\s)` + regexp.QuoteMeta(flag) + `(?:=(?:"[^"]*"|[^\s^"]*))?(\s|\^|$)`)
+		found := false
+		for i := first; i <= last; i++ {
+			if re.MatchString(lines[i]) {
+				lines[i] = re.ReplaceAllString(lines[i], "${1}"+strings.ReplaceAll(arg, "$", "$$")+"${2}")
+				found = true
+			}
+		}
+		if !found {
+			lines[last] = strings.TrimRight(lines[last], " \t") + " " + arg
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// diagnosticsURL is a neutral endpoint that no DPI blocks, for telling whether the internet
+	// works at all.
+	diagnosticsURL = "https://www.cloudflare.com/cdn-cgi/trace"
+	// diagnosticsTimeout bounds each diagnostic check.
+	diagnosticsTimeout = 6 * time.Second
+)
+
+// DiagnosticsReport is the outcome of the checks run before a test run.
+type DiagnosticsReport struct {
+	At     time.Time         `json:"at"`
+	Checks []DiagnosticCheck `json:"checks"`
+	// Conflicts are the other DPI bypass tools and VPNs found, as RunStrategy sees them.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// OK is false when a check that makes test results meaningless failed.
+	OK bool `json:"ok"`
+}
+
+// DiagnosticCheck is one check of a DiagnosticsReport.
+type DiagnosticCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	// Blocking checks abort a test run when they fail.
+	Blocking bool `json:"blocking"`
+}
+
+// DiagnosticsFailedError is returned by a test run that didn't start because of Report.
+type DiagnosticsFailedError struct {
+	Report DiagnosticsReport
+}
+
+func (e *DiagnosticsFailedError) Error() string {
+	var failed []string
+	for _, c := range e.Report.Checks {
+		if c.Blocking && !c.OK {
+			failed = append(failed, c.Name+": "+c.Detail)
+		}
+	}
+	return "tests not started: " + strings.Join(failed, "; ")
+}
+
+// RunDiagnostics checks what a test run needs: internet access, DNS resolution of the test
+// domains, PowerShell and its execution policy, and no other DPI tool or VPN running alongside.
+func (s *Service) RunDiagnostics() (DiagnosticsReport, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return DiagnosticsReport{}, err
+	}
+	hosts := make([]string, 0, len(nativeTestTargets)+len(cfg.TestDomains))
+	for _, t := range nativeTestTargets {
+		hosts = append(hosts, t.Host)
+	}
+	for _, d := range cfg.TestDomains {
+		if u, err := url.Parse(testTargetURL(d)); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	conflicts := s.detectConflicts(cfg, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+	checks := []func() DiagnosticCheck{
+		func() DiagnosticCheck { return checkInternet(ctx) },
+		func() DiagnosticCheck { return checkDNS(ctx, hosts) },
+		checkPowerShell,
+		func() DiagnosticCheck { return checkOtherDPI(conflicts) },
+		func() DiagnosticCheck { return checkVPN(conflicts) },
+	}
+	report := DiagnosticsReport{At: time.Now(), Checks: make([]DiagnosticCheck, len(checks)), Conflicts: conflicts, OK: true}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check func() DiagnosticCheck) {
+			defer wg.Done()
+			report.Checks[i] = check()
+		}(i, check)
+	}
+	wg.Wait()
+	for _, c := range report.Checks {
+		if c.Blocking && !c.OK {
+			report.OK = false
+		}
+	}
+	return report, nil
+}
+
+// checkInternet requests diagnosticsURL directly.
+func checkInternet(ctx context.Context) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "internet", Blocking: true}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, diagnosticsURL, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = newProbeClient().Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	if err != nil {
+		c.Detail = "no connection to " + diagnosticsURL + ": " + err.Error()
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// checkDNS resolves hosts; it fails when none resolve, since then every test fails regardless of
+// the strategy.
+func checkDNS(ctx context.Context, hosts []string) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "dns", Blocking: true}
+	var mu sync.Mutex
+	var failed []string
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(h string) {
+			defer wg.Done()
+			if _, err := net.DefaultResolver.LookupHost(ctx, h); err != nil {
+				mu.Lock()
+				failed = append(failed, h)
+				mu.Unlock()
+			}
+		}(h)
+	}
+	wg.Wait()
+	switch {
+	case len(failed) == len(hosts) && len(hosts) > 0:
+		c.Detail = "none of the test domains resolve"
+	case len(failed) > 0:
+		c.OK = true
+		c.Detail = "not resolved: " + strings.Join(failed, ", ")
+	default:
+		c.OK = true
+	}
+	return c
+}
+
+// checkPowerShell looks for powershell.exe and for a group policy execution policy that overrides
+// the -ExecutionPolicy Bypass the test script is started with.
+func checkPowerShell() DiagnosticCheck {
+	c := DiagnosticCheck{Name: "powershell", Blocking: true}
+	if _, err := exec.LookPath("powershell"); err != nil {
+		c.Detail = "powershell.exe not found"
+		return c
+	}
+	for _, scope := range []string{"MachinePolicy", "UserPolicy"} {
+		out, err := hiddenOutput("powershell", "-NoProfile", "-Command", "Get-ExecutionPolicy -Scope "+scope)
+		if err != nil {
+			c.Detail = "powershell.exe doesn't run: " + err.Error()
+			return c
+		}
+		if policy := strings.TrimSpace(string(out)); policy == "Restricted" || policy == "AllSigned" {
+			c.Detail = fmt.Sprintf("the %s execution policy is %s and doesn't allow the test script", scope, policy)
+			return c
+		}
+	}
+	c.OK = true
+	return c
+}
+
+// checkOtherDPI fails on the blocking conflicts, which would interfere with the strategies under
+// test.
+func checkOtherDPI(conflicts []Conflict) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "other dpi tools", Blocking: true}
+	var found []string
+	for _, cf := range blockingConflicts(conflicts) {
+		found = append(found, cf.String())
+	}
+	if len(found) > 0 {
+		c.Detail = "running: " + strings.Join(found, ", ")
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// checkVPN warns about connected VPN adapters: traffic through a VPN skips the strategy, so it
+// may test better or worse than it is.
+func checkVPN(conflicts []Conflict) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "vpn"}
+	var found []string
+	for _, cf := range conflicts {
+		if cf.Kind == conflictVPN {
+			found = append(found, cf.Name)
+		}
+	}
+	if len(found) > 0 {
+		c.Detail = "connected: " + strings.Join(found, ", ")
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// hiddenOutput runs a console command without flashing its window and returns its output.
+func hiddenOutput(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	return cmd.Output()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTestDomains bounds Config.TestDomains; each one is probed once per tested strategy.
+	maxTestDomains = 50
+	// domainProbeTimeout bounds a single request to a custom test domain.
+	domainProbeTimeout = 8 * time.Second
+	// winwsStartWait is how long a strategy script gets to start winws.
+	winwsStartWait = 10 * time.Second
+	// winwsStopWait is how long to wait for winws to exit after it was killed.
+	winwsStopWait = 5 * time.Second
+	// probeConcurrency bounds the requests in flight while a strategy is probed.
+	probeConcurrency = 6
+)
+
+// Outcomes of probing a custom test domain, as stored in TestResult.Domains.
+const (
+	domainOK   = "ok"
+	domainFail = "fail"
+)
+
+// SetTestDomains replaces the custom test domains. Entries are host names or http(s) URLs; hosts
+// are probed over https.
+func (s *Service) SetTestDomains(domains []string) (*State, error) {
+	var res []string
+	for _, d := range domains {
+		if strings.TrimSpace(d) == "" {
+			continue
+		}
+		t, err := normalizeTestTarget(d)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(res, t) {
+			res = append(res, t)
+		}
+	}
+	if len(res) > maxTestDomains {
+		return nil, fmt.Errorf("at most %d test domains are allowed", maxTestDomains)
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.TestDomains = res
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// normalizeTestTarget checks a custom test domain: URLs keep their path, anything else must be a
+// host name.
+func normalizeTestTarget(raw string) (string, error) {
+	t := strings.TrimSpace(raw)
+	if !strings.Contains(t, "://") {
+		return normalizeDomain(t)
+	}
+	u, err := url.Parse(t)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	return u.String(), nil
+}
+
+// testTargetURL is the URL probed for a custom test domain.
+func testTargetURL(target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+	return "https://" + target
+}
+
+// probeTestDomains runs each strategy in parsed from the release at current in turn and records in
+// its result whether the custom test domains load with it. The official script only probes its own
+// fixed targets, so this is a separate pass after it. It is skipped while a strategy the user
+// started is running, since probing replaces winws.
+func (s *Service) probeTestDomains(ctx context.Context, current string, parsed *parsedResults) {
+	cfg, err := s.configSnapshot()
+	if err != nil || len(cfg.TestDomains) == 0 || parsed == nil {
+		return
+	}
+	if cfg.Running != nil && cfg.Running.alive() {
+		s.logUpdate("custom test domains skipped: %s is running", cfg.Running.File)
+		return
+	}
+	names, err := testConfigNames(current)
+	if err != nil {
+		return
+	}
+	client := newProbeClient()
+	for printed, r := range parsed.Results {
+		file := ""
+		for _, n := range names {
+			if matchesConfig(printed, n) {
+				file = n
+				break
+			}
+		}
+		if file == "" {
+			continue
+		}
+		stop, err := startProbeStrategy(ctx, current, file)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logUpdate("probing custom test domains with %s: %v", file, err)
+			continue
+		}
+		domains := probeURLs(ctx, client, cfg.TestDomains)
+		stop()
+		if ctx.Err() != nil {
+			return
+		}
+		r.Domains = domains
+		parsed.Results[printed] = r
+	}
+	parsed.Best = bestStrategy(parsed.Results, cfg.Settings.domainWeight())
+}
+
+// newProbeClient returns the HTTP client for probes. It connects directly, since a proxy would
+// hide what the strategy does to the traffic, and opens a new connection for every request.
+func newProbeClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DisableKeepAlives = true
+	return &http.Client{Timeout: domainProbeTimeout, Transport: transport}
+}
+
+// startProbeStrategy runs the strategy file from the release at current and waits for winws to
+// start. The returned func stops the strategy and waits until winws is gone, so the next
+// measurement doesn't go through it. Only the winws instances started from current by this call
+// are stopped; anything else running is left alone.
+func startProbeStrategy(ctx context.Context, current, file string) (func(), error) {
+	cmd := batCommand(filepath.Join(current, file))
+	cmd.SysProcAttr.CreationFlags = createNewConsole
+	cmd.SysProcAttr.HideWindow = true
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() { _ = cmd.Wait() }()
+	stop := func() {
+		killProcessTree(cmd.Process.Pid)
+		for _, pid := range winwsProcessesIn(current, started) {
+			_ = terminateProcess(pid)
+		}
+		for deadline := time.Now().Add(winwsStopWait); len(winwsProcessesIn(current, started)) > 0 && time.Now().Before(deadline); {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	deadline := time.Now().Add(winwsStartWait)
+	for len(winwsProcessesIn(current, started)) == 0 {
+		if time.Now().After(deadline) {
+			stop()
+			return nil, errors.New("winws did not start")
+		}
+		select {
+		case <-ctx.Done():
+			stop()
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return stop, nil
+}
+
+// probeURLs requests each target, at most probeConcurrency at a time, and reports which ones got
+// a response.
+func probeURLs(ctx context.Context, client *http.Client, targets []string) map[string]string {
+	res := make(map[string]string, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			outcome := domainFail
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, testTargetURL(t), nil)
+			if err == nil {
+				// Any response means the connection got through; blocking shows up as resets and timeouts.
+				if resp, err := client.Do(req); err == nil {
+					resp.Body.Close()
+					outcome = domainOK
+				}
+			}
+			mu.Lock()
+			res[t] = outcome
+			mu.Unlock()
+		}(t)
+	}
+	wg.Wait()
+	return res
+}
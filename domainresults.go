@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Outcomes of a domain in TestResult.DomainResults, from best to worst.
+const (
+	domainResultOK      = "ok"
+	domainResultErr     = "err"
+	domainResultBlocked = "blocked"
+)
+
+// domainTokenRe matches a URL or host name such as "https://discord.com/api" or "i.ytimg.com:443".
+var domainTokenRe = regexp.MustCompile(`(?i)(?:https?://)?((?:[a-z0-9-]+\.)+[a-z]{2,})(?::\d+)?(?:/[^\s]*)?`)
+
+// domainStatusWords maps the status keywords of the test script's per-domain lines to outcomes.
+var domainStatusWords = map[string]string{
+	"OK":      domainResultOK,
+	"SUCCESS": domainResultOK,
+	"ERR":     domainResultErr,
+	"ERROR":   domainResultErr,
+	"FAIL":    domainResultErr,
+	"FAILED":  domainResultErr,
+	"TIMEOUT": domainResultErr,
+	"BLOCKED": domainResultBlocked,
+	"DPI":     domainResultBlocked,
+}
+
+// GetStrategyDomainResults returns what the last test run reported for each domain it checked
+// with the strategy name.
+func (s *Service) GetStrategyDomainResults(name string) (map[string]string, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	for printed, r := range cfg.TestResults {
+		if matchesConfig(printed, name) || strings.EqualFold(printed, name) {
+			if r.DomainResults == nil {
+				return map[string]string{}, nil
+			}
+			return r.DomainResults, nil
+		}
+	}
+	return nil, errors.New("no test result for " + name)
+}
+
+// parseDomainResults fills in DomainResults of results from the body of the test output in lines,
+// where each config's section starts with a line naming it. The script's target list changes
+// between versions, so any line with a domain or URL followed by status keywords counts; a domain
+// checked several ways gets its worst outcome.
+func parseDomainResults(lines []string, results map[string]TestResult) {
+	current := ""
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "=== ANALYTICS ===" {
+			return
+		}
+		if line == "" {
+			continue
+		}
+		if _, _, ok := parseResultLine(line); ok {
+			continue
+		}
+		if name := sectionConfig(line, results); name != "" {
+			current = name
+			continue
+		}
+		if current == "" {
+			continue
+		}
+		domain, outcome := parseDomainLine(line)
+		if domain == "" {
+			continue
+		}
+		r := results[current]
+		if r.DomainResults == nil {
+			r.DomainResults = make(map[string]string)
+		}
+		if worseDomainResult(outcome, r.DomainResults[domain]) {
+			r.DomainResults[domain] = outcome
+		}
+		results[current] = r
+	}
+}
+
+// sectionConfig returns the name in results of the config whose .bat file line mentions, or "".
+// The longest match wins, as "my general.bat" contains "general.bat".
+func sectionConfig(line string, results map[string]TestResult) string {
+	lower := strings.ToLower(line)
+	if !strings.Contains(lower, ".bat") {
+		return ""
+	}
+	best := ""
+	for name := range results {
+		file := strings.ToLower(name)
+		if !strings.HasSuffix(file, ".bat") {
+			file += ".bat"
+		}
+		if strings.Contains(lower, file) && len(name) > len(best) {
+			best = name
+		}
+	}
+	return best
+}
+
+// parseDomainLine returns the host of the first domain or URL on line and the worst status keyword
+// after it, or "" when the line has no such pair.
+func parseDomainLine(line string) (string, string) {
+	for _, m := range domainTokenRe.FindAllStringSubmatchIndex(line, -1) {
+		host := strings.ToLower(line[m[2]:m[3]])
+		// File names aren't domains.
+		if ext := host[strings.LastIndex(host, ".")+1:]; ext == "bat" || ext == "txt" || ext == "ps1" || ext == "exe" {
+			continue
+		}
+		outcome := ""
+		words := strings.FieldsFunc(line[m[1]:], func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_')
+		})
+		for _, w := range words {
+			if o, ok := domainStatusWords[strings.ToUpper(w)]; ok && worseDomainResult(o, outcome) {
+				outcome = o
+			}
+		}
+		if outcome == "" {
+			return "", ""
+		}
+		return host, outcome
+	}
+	return "", ""
+}
+
+// worseDomainResult reports whether outcome a is worse than b; anything is worse than "".
+func worseDomainResult(a, b string) bool {
+	rank := map[string]int{"": 0, domainResultOK: 1, domainResultErr: 2, domainResultBlocked: 3}
+	return rank[a] > rank[b]
+}
@@ -0,0 +1,169 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// driverServices are the names WinDivert registers its driver service under: WinDivert 2.x and
+// WinDivert 1.4 left behind by older tools.
+var driverServices = []string{"WinDivert", "WinDivert14"}
+
+// States of a DriverStatus.
+const (
+	driverNotInstalled = "not-installed"
+	driverStopped      = "stopped"
+	driverRunning      = "running"
+	driverPending      = "pending"
+	driverError        = "error"
+)
+
+// driverStopWait bounds the wait for the driver service to stop in ResetDriver.
+const driverStopWait = 5 * time.Second
+
+// DriverStatus is the state of the WinDivert driver service winws depends on.
+type DriverStatus struct {
+	// Service is the name of the driver service found, "" when none is installed.
+	Service string `json:"service,omitempty"`
+	// State is "not-installed", "stopped", "running", "pending" (stuck starting or stopping) or
+	// "error".
+	State string `json:"state"`
+	// Code is the Windows error code behind an "error" state.
+	Code  uint32 `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// DriverError is a failed service control manager call about the WinDivert driver.
+type DriverError struct {
+	Op      string
+	Service string
+	Code    uint32
+	Err     error
+}
+
+func (e *DriverError) Error() string {
+	return fmt.Sprintf("%s %s: %v (error %d / 0x%08X)", e.Op, e.Service, e.Err, e.Code, e.Code)
+}
+
+func (e *DriverError) Unwrap() error { return e.Err }
+
+// newDriverError wraps err from op on the service name with its Windows error code.
+func newDriverError(op, name string, err error) *DriverError {
+	e := &DriverError{Op: op, Service: name, Err: err}
+	var errno windows.Errno
+	if errors.As(err, &errno) {
+		e.Code = uint32(errno)
+	}
+	return e
+}
+
+// driverStatus queries the WinDivert driver service. Querying needs no administrator rights.
+func driverStatus() DriverStatus {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		e := newDriverError("connecting to the service manager for", "WinDivert", err)
+		return DriverStatus{State: driverError, Code: e.Code, Error: e.Error()}
+	}
+	defer windows.CloseServiceHandle(scm)
+	for _, name := range driverServices {
+		h, err := windows.OpenService(scm, windows.StringToUTF16Ptr(name), windows.SERVICE_QUERY_STATUS)
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			continue
+		}
+		if err != nil {
+			e := newDriverError("opening", name, err)
+			return DriverStatus{Service: name, State: driverError, Code: e.Code, Error: e.Error()}
+		}
+		var st windows.SERVICE_STATUS
+		err = windows.QueryServiceStatus(h, &st)
+		windows.CloseServiceHandle(h)
+		if err != nil {
+			e := newDriverError("querying", name, err)
+			return DriverStatus{Service: name, State: driverError, Code: e.Code, Error: e.Error()}
+		}
+		return serviceDriverStatus(name, st)
+	}
+	return DriverStatus{State: driverNotInstalled}
+}
+
+// serviceDriverStatus describes the status st of the driver service name.
+func serviceDriverStatus(name string, st windows.SERVICE_STATUS) DriverStatus {
+	ds := DriverStatus{Service: name}
+	switch st.CurrentState {
+	case windows.SERVICE_RUNNING:
+		ds.State = driverRunning
+	case windows.SERVICE_STOPPED:
+		ds.State = driverStopped
+		// A driver that failed to load stops with the reason as its exit code.
+		if code := st.Win32ExitCode; code != 0 && code != uint32(windows.ERROR_SERVICE_NEVER_STARTED) {
+			ds.State, ds.Code = driverError, code
+			ds.Error = fmt.Sprintf("%s stopped with %v (error %d / 0x%08X)", name, windows.Errno(code), code, code)
+		}
+	default:
+		ds.State = driverPending
+	}
+	return ds
+}
+
+// ResetDriver stops every winws.exe and then stops and deletes the WinDivert driver service, so
+// the next strategy launch installs the driver afresh. It needs administrator rights.
+func (s *Service) ResetDriver() (*State, error) {
+	if !isElevated() {
+		return nil, &AdminRequiredError{Strategy: "Resetting the WinDivert driver"}
+	}
+	s.runMu.Lock()
+	_, err := s.stopRunning(true)
+	s.runMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return nil, newDriverError("connecting to the service manager for", "WinDivert", err)
+	}
+	defer windows.CloseServiceHandle(scm)
+	for _, name := range driverServices {
+		if err := resetDriverService(scm, name); err != nil {
+			return nil, err
+		}
+	}
+	s.logUpdate("reset the WinDivert driver")
+	return s.State()
+}
+
+// resetDriverService stops and deletes the driver service name, if it exists.
+func resetDriverService(scm windows.Handle, name string) error {
+	h, err := windows.OpenService(scm, windows.StringToUTF16Ptr(name), windows.SERVICE_STOP|windows.SERVICE_QUERY_STATUS|windows.DELETE)
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return nil
+	}
+	if err != nil {
+		return newDriverError("opening", name, err)
+	}
+	defer windows.CloseServiceHandle(h)
+	var st windows.SERVICE_STATUS
+	if err := windows.ControlService(h, windows.SERVICE_CONTROL_STOP, &st); err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return newDriverError("stopping", name, err)
+	}
+	for deadline := time.Now().Add(driverStopWait); ; time.Sleep(200 * time.Millisecond) {
+		if err := windows.QueryServiceStatus(h, &st); err != nil {
+			return newDriverError("querying", name, err)
+		}
+		if st.CurrentState == windows.SERVICE_STOPPED {
+			break
+		}
+		if time.Now().After(deadline) {
+			// Deleting still works: the service goes away once its last handle closes.
+			break
+		}
+	}
+	if err := windows.DeleteService(h); err != nil && !errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) {
+		return newDriverError("deleting", name, err)
+	}
+	return nil
+}
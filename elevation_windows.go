@@ -0,0 +1,59 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// isElevated reports whether the app runs with an elevated token, which loading the WinDivert
+// driver requires.
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// relaunchAsAdmin starts the app again through the UAC prompt. The new instance waits for this one
+// to exit so the single-instance lock doesn't hand it back to us.
+func relaunchAsAdmin() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{fmt.Sprintf("%s=%d", flagWaitPID, os.Getpid())}
+	for _, a := range os.Args[1:] {
+		if !strings.HasPrefix(strings.ToLower(a), flagWaitPID+"=") {
+			args = append(args, `"`+a+`"`)
+		}
+	}
+	cwd, _ := os.Getwd()
+	verb, _ := windows.UTF16PtrFromString("runas")
+	file, _ := windows.UTF16PtrFromString(exe)
+	params, _ := windows.UTF16PtrFromString(strings.Join(args, " "))
+	dir, _ := windows.UTF16PtrFromString(cwd)
+	return windows.ShellExecute(0, verb, file, params, dir, windows.SW_SHOWNORMAL)
+}
+
+// waitForExit blocks until the process pid exits or timeout passes.
+func waitForExit(pid int, timeout time.Duration) {
+	h, err := windows.OpenProcess(windows.SYNCHRONIZE, false, uint32(pid))
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(h)
+	_, _ = windows.WaitForSingleObject(h, uint32(timeout.Milliseconds()))
+}
+
+// waitForPreviousInstance honours the --wait-pid flag passed by relaunchAsAdmin.
+func waitForPreviousInstance() {
+	if v := flagValue(flagWaitPID); v != "" {
+		if pid, err := strconv.Atoi(v); err == nil && pid > 0 {
+			waitForExit(pid, 10*time.Second)
+		}
+	}
+}
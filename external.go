@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExternalProcess is a winws.exe the app didn't start, e.g. the one of the zapret Windows service
+// or of a .bat run by hand. It conflicts with any strategy the app starts.
+type ExternalProcess struct {
+	PID         int       `json:"pid"`
+	Path        string    `json:"path,omitempty"`
+	CommandLine string    `json:"commandLine,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	// Strategy is the strategy of the current release with the same winws arguments, "" if none
+	// matches; only a matched process can be adopted.
+	Strategy string `json:"strategy,omitempty"`
+	// Service is set when the process was started by the Windows service manager.
+	Service bool `json:"service,omitempty"`
+}
+
+// externalWinws lists the winws.exe processes other than the tracked strategy's, matched against
+// strategies. Nothing is reported during a test run, whose script starts winws instances itself.
+func (s *Service) externalWinws(cfg *Config, strategies []Strategy) []ExternalProcess {
+	if cfg.TestInProgress || s.testing.Load() {
+		return nil
+	}
+	var res []ExternalProcess
+	for _, pid := range processesNamed("winws.exe") {
+		p := ExternalProcess{PID: pid}
+		p.Path, _ = processImagePath(uint32(pid))
+		if r := cfg.Running; r != nil {
+			// Without a known winws PID, any winws from the releases folder may be the tracked one.
+			inReleases := p.Path != "" && strings.HasPrefix(strings.ToLower(p.Path), strings.ToLower(filepath.Clean(s.dirs().releasesDir))+string(filepath.Separator))
+			if pid == r.WinwsPID || (r.WinwsPID == 0 && inReleases) {
+				continue
+			}
+		}
+		p.StartedAt, _ = processStartTime(pid)
+		p.CommandLine, _ = processCommandLine(uint32(pid))
+		p.Service = strings.EqualFold(processParentName(pid), "services.exe")
+		if args := winwsArgs(p.CommandLine); args != "" {
+			fp := (&StrategyDetails{Raw: args}).fingerprint()
+			for _, st := range strategies {
+				if st.Fingerprint == fp && st.DuplicateOf == "" {
+					p.Strategy = st.Name
+					break
+				}
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// winwsArgs returns the arguments of a winws.exe command line, without the executable.
+func winwsArgs(cmdline string) string {
+	cmdline = strings.TrimSpace(cmdline)
+	if strings.HasPrefix(cmdline, `"`) {
+		if i := strings.Index(cmdline[1:], `"`); i >= 0 {
+			return strings.TrimSpace(cmdline[i+2:])
+		}
+		return ""
+	}
+	if _, args, ok := strings.Cut(cmdline, " "); ok {
+		return strings.TrimSpace(args)
+	}
+	return ""
+}
+
+// AdoptRunning makes the external winws.exe pid the tracked running strategy, so the app shows,
+// stops and watches it like one it started itself. The process must match a strategy of the
+// current release.
+func (s *Service) AdoptRunning(pid int) (*State, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Running != nil && cfg.Running.alive() {
+		return nil, fmt.Errorf("%s is running; stop it before adopting another winws", cfg.Running.File)
+	}
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return nil, err
+	}
+	var ext *ExternalProcess
+	for _, p := range s.externalWinws(cfg, strategies) {
+		if p.PID == pid {
+			ext = &p
+			break
+		}
+	}
+	switch {
+	case ext == nil:
+		return nil, fmt.Errorf("no external winws.exe with PID %d", pid)
+	case ext.Strategy == "":
+		return nil, errors.New("the process doesn't match any strategy of the current release")
+	}
+	var run RunningInfo
+	err = s.updateConfig(func(c *Config) error {
+		if c.Running != nil {
+			recordRunEnd(c, c.Running, c.Running.SeenAt)
+		}
+		now := time.Now()
+		// There is no launcher to track; the winws itself stands in for it.
+		c.Running = &RunningInfo{
+			File:             ext.Strategy,
+			Release:          c.Version,
+			PID:              pid,
+			WinwsPID:         pid,
+			StartedAt:        ext.StartedAt,
+			SessionStartedAt: ext.StartedAt,
+			SeenAt:           now,
+		}
+		c.LastStrategy = ext.Strategy
+		run = *c.Running
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logUpdate("adopted winws.exe (PID %d) as %s", pid, ext.Strategy)
+	s.watchRun(run)
+	return s.State()
+}
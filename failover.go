@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// eventFailover carries a FailoverEvent when the watchdog switches strategies or gives up.
+const eventFailover = "failover"
+
+const (
+	// failoverTick is how often the watchdog looks at its settings and the running strategy.
+	failoverTick                = 30 * time.Second
+	defaultFailoverCheckMinutes = 10
+	defaultFailoverFailures     = 3
+	defaultFailoverSwitches     = 3
+	defaultFailoverDwellMinutes = 30
+)
+
+// FailoverEvent describes what the failover watchdog did.
+type FailoverEvent struct {
+	From string `json:"from"`
+	// To is the strategy started instead of From, "" when the watchdog gave up.
+	To    string `json:"to,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// failoverState is what the watchdog remembers between checks.
+type failoverState struct {
+	// running is the strategy the counters below are about.
+	running   string
+	lastCheck time.Time
+	failures  int
+	// switches counts switches since the last passed check; tried holds the strategies they left.
+	switches int
+	tried    []string
+	gaveUp   bool
+}
+
+// failoverWatchdog quick-checks the running strategy while Settings.Failover is on, and after
+// Settings.FailoverFailures failed checks in a row starts the next strategy of the failover chain.
+func (s *Service) failoverWatchdog(ctx context.Context) {
+	ticker := time.NewTicker(failoverTick)
+	defer ticker.Stop()
+	var fs failoverState
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cfg, err := s.configSnapshot()
+		if err != nil || !cfg.Settings.Failover || cfg.Running == nil || cfg.TestInProgress || s.testing.Load() || !cfg.Running.alive() {
+			fs.failures = 0
+			continue
+		}
+		st := cfg.Settings
+		if cfg.Running.File != fs.running {
+			// A strategy the user started begins a new chain; one the watchdog started continues it.
+			if !containsString(fs.tried, fs.running) || fs.running == "" {
+				fs = failoverState{}
+			}
+			fs.running, fs.failures, fs.lastCheck = cfg.Running.File, 0, time.Time{}
+		}
+		if time.Since(fs.lastCheck) < time.Duration(orDefault(st.FailoverCheckMinutes, defaultFailoverCheckMinutes))*time.Minute {
+			continue
+		}
+		fs.lastCheck = time.Now()
+		res, err := s.QuickCheck()
+		if err != nil {
+			continue
+		}
+		if res.Failed*2 <= len(res.Endpoints) {
+			fs = failoverState{running: fs.running, lastCheck: fs.lastCheck}
+			continue
+		}
+		fs.failures++
+		s.logUpdate("quick check of %s failed %d time(s) in a row", fs.running, fs.failures)
+		// Flapping protection: a strategy gets its dwell time before it is given up on.
+		dwell := time.Duration(orDefault(st.FailoverDwellMinutes, defaultFailoverDwellMinutes)) * time.Minute
+		if fs.failures < orDefault(st.FailoverFailures, defaultFailoverFailures) || time.Since(cfg.Running.StartedAt) < dwell || fs.gaveUp {
+			continue
+		}
+		ev := FailoverEvent{From: fs.running}
+		next := ""
+		if fs.switches < orDefault(st.FailoverMaxSwitches, defaultFailoverSwitches) {
+			fs.tried = append(fs.tried, fs.running)
+			next = s.nextFailoverStrategy(cfg, fs.tried)
+		}
+		if next == "" {
+			fs.gaveUp = true
+			ev.Error = "no strategy left to switch to"
+			s.logUpdate("failover from %s: %s", ev.From, ev.Error)
+			s.emit(eventFailover, ev)
+			trayNotifyFailover(ev)
+			continue
+		}
+		state, switched, err := s.failoverSwitch(cfg.Running, next)
+		if !switched && err == nil {
+			// The user stopped or restarted it during the check, which starts over.
+			s.logUpdate("failover from %s dropped: it was stopped or restarted meanwhile", ev.From)
+			fs = failoverState{}
+			continue
+		}
+		fs.switches++
+		ev.To = next
+		s.logUpdate("failover: %s stopped working, switching to %s", ev.From, next)
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		s.emit(eventFailover, ev)
+		trayNotifyFailover(ev)
+		if state != nil {
+			s.emit(eventState, state)
+		}
+	}
+}
+
+// failoverSwitch starts next in place of the run from, unless that run was stopped or replaced
+// since it was checked; switched is false then and nothing is started.
+func (s *Service) failoverSwitch(from *RunningInfo, next string) (state *State, switched bool, err error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, false, err
+	}
+	if cfg.Running == nil || cfg.Running.File != from.File || !cfg.Running.StartedAt.Equal(from.StartedAt) {
+		return nil, false, nil
+	}
+	state, err = s.runStrategy(next, false)
+	return state, true, err
+}
+
+// nextFailoverStrategy returns the first strategy of the failover chain that isn't in tried and
+// exists in the current release, or "".
+func (s *Service) nextFailoverStrategy(cfg *Config, tried []string) string {
+	chain := cfg.Settings.FailoverChain
+	if len(chain) == 0 {
+		chain = rankedPassing(cfg.TestResults, cfg.Settings.domainWeight())
+	}
+	for _, name := range chain {
+		st, err := s.strategyByName(name)
+		if err != nil || st.Category != categoryGeneral {
+			continue
+		}
+		skip := false
+		for _, t := range tried {
+			skip = skip || strings.EqualFold(t, st.Name)
+		}
+		if !skip {
+			return st.Name
+		}
+	}
+	return ""
+}
+
+// orDefault returns v, or def when v is 0.
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileVersion reads the file version from the version resource of an executable, as
+// "major.minor.build". It returns "" when the file has no version resource.
+func fileVersion(path string) string {
+	size, err := windows.GetFileVersionInfoSize(path, nil)
+	if err != nil || size == 0 {
+		return ""
+	}
+	buf := make([]byte, size)
+	if err := windows.GetFileVersionInfo(path, 0, size, unsafe.Pointer(&buf[0])); err != nil {
+		return ""
+	}
+	var info *windows.VS_FIXEDFILEINFO
+	var infoLen uint32
+	if err := windows.VerQueryValue(unsafe.Pointer(&buf[0]), `\`, unsafe.Pointer(&info), &infoLen); err != nil || info == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", info.FileVersionMS>>16, info.FileVersionMS&0xffff, info.FileVersionLS>>16)
+}
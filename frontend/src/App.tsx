@@ -1,7 +1,8 @@
 import { useState, useEffect } from 'react';
 import { RefreshCw, PlayCircle, Activity, AlertTriangle, AccessibilityIcon, ThumbsUp } from 'lucide-react';
-import { CheckAndUpdate, GetState, RunStrategy, RunTests, StopStrategy } from '../wailsjs/go/main/App';
-import type { State, Strategy, RunningInfo } from './types/models';
+import { AdoptRunning, CheckAndUpdate, GetState, InstallAsService, PauseRunning, RemoveService, ResumeRunning, ResetDriver, RunStrategy, StartTests, StopStrategy } from '../wailsjs/go/main/App';
+import { EventsOn } from '../wailsjs/runtime/runtime';
+import type { State, Strategy, RunningInfo, DownloadProgress, LatestTagEvent, AutoRunEvent, CrashEvent, ResumeEvent, TestProgress, TestResult, TestJob } from './types/models';
 import StrategyCard from './components/StrategyCard';
 import UpdateOverlay from './components/UpdateOverlay';
 
@@ -12,8 +13,10 @@ function App() {
   const [error, setError] = useState<string>('');
   const [updateProgress, setUpdateProgress] = useState(0);
   const [isUpdating, setIsUpdating] = useState(false);
+  const [testProgress, setTestProgress] = useState<TestProgress>();
 
-  const strategies = state?.strategies || [];
+  // Service scripts need the dedicated install flow and can't be toggled like strategies.
+  const strategies = (state?.strategies || []).filter((s) => s.category !== 'service');
   const running: RunningInfo | undefined = state?.running || state?.config?.running;
   const currentVersion = state?.config?.version || 'n/a';
   const latestTag = state?.latestTag || '';
@@ -39,24 +42,73 @@ function App() {
     load();
   }, []);
 
+  useEffect(() => {
+    return EventsOn('state:latest-tag', (e: LatestTagEvent) => {
+      if (e.error) {
+        return;
+      }
+      setState((prev) => prev && { ...prev, latestTag: e.latestTag, hasUpdate: e.hasUpdate, releaseNotes: e.releaseNotes });
+    });
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('state:changed', (s: State) => setState(s));
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('autorun', (e: AutoRunEvent) => {
+      if (e.error) {
+        setError(`Auto-run ${e.strategy || ''} failed: ${e.error}`);
+      }
+    });
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('strategy:crashed', (e: CrashEvent) => {
+      if (e.gaveUp) {
+        setError(`${e.strategy} падает снова и снова и больше не перезапускается`);
+      } else if (!e.restarted) {
+        setError(`${e.strategy} неожиданно завершился` + (e.error ? `: ${e.error}` : ''));
+      }
+    });
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('power:resumed', (e: ResumeEvent) => {
+      if (!e.restarted) {
+        setError(`После выхода из сна не удалось перезапустить ${e.strategy}` + (e.error ? `: ${e.error}` : ''));
+      }
+    });
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('downloadProgress', (p: DownloadProgress) => {
+      if (p.percent >= 0) {
+        setUpdateProgress(p.percent);
+      }
+    });
+  }, []);
+
+  useEffect(() => {
+    return EventsOn('test:progress', (p: TestProgress) => setTestProgress(p));
+  }, []);
+
+  useEffect(() => {
+    // Results arrive one config at a time while the run is underway.
+    return EventsOn('test:result', (r: TestResult) => {
+      setState((prev) => prev && {
+        ...prev,
+        strategies: prev.strategies?.map((s) => (s.name === r.name ? { ...s, result: r, stale: false } : s)),
+      });
+    });
+  }, []);
+
   const handleUpdate = async () => {
     setIsUpdating(true);
     setUpdateProgress(0);
     setError('');
     try {
-      // Simulate progress
-      const progressInterval = setInterval(() => {
-        setUpdateProgress((prev) => {
-          if (prev >= 90) {
-            clearInterval(progressInterval);
-            return 90;
-          }
-          return prev + Math.random() * 15;
-        });
-      }, 500);
-
       const s = await CheckAndUpdate();
-      clearInterval(progressInterval);
       setUpdateProgress(100);
       setState(s);
 
@@ -71,20 +123,86 @@ function App() {
     }
   };
 
+  useEffect(() => {
+    // The run goes on in the background; the new state follows as "state:changed".
+    return EventsOn('test:job', (j: TestJob) => {
+      if (j.status === 'running') {
+        return;
+      }
+      setIsTestingAll(false);
+      if (j.status === 'failed') {
+        setError(j.error || 'Tests failed');
+      }
+    });
+  }, []);
+
   const handleTests = async () => {
     setIsTestingAll(true);
+    setTestProgress(undefined);
     setError('');
 
     try {
-      const s = await RunTests();
-      setState(s);
-      setIsTestingAll(false);
+      await StartTests({ mode: '' });
     } catch (e: any) {
       setError(e?.toString() ?? 'Tests failed');
       setIsTestingAll(false);
     }
   };
 
+  const handleAdopt = async (pid: number) => {
+    setError('');
+    try {
+      setState(await AdoptRunning(pid));
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Adopt failed');
+    }
+  };
+
+  const handleResetDriver = async () => {
+    setError('');
+    try {
+      setState(await ResetDriver());
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Driver reset failed');
+    }
+  };
+
+  const handlePause = async () => {
+    setError('');
+    try {
+      setState(await PauseRunning());
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Pause failed');
+    }
+  };
+
+  const handleResume = async () => {
+    setError('');
+    try {
+      setState(await ResumeRunning());
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Resume failed');
+    }
+  };
+
+  const handleInstallService = async (name: string) => {
+    setError('');
+    try {
+      setState(await InstallAsService(name));
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Service install failed');
+    }
+  };
+
+  const handleRemoveService = async () => {
+    setError('');
+    try {
+      setState(await RemoveService());
+    } catch (e: any) {
+      setError(e?.toString() ?? 'Service removal failed');
+    }
+  };
+
   const handleToggleStrategy = async (file: string, isRunning: boolean) => {
     setError('');
     try {
@@ -92,11 +210,20 @@ function App() {
         const s = await StopStrategy();
         setState(s);
       } else {
-        const s = await RunStrategy(file);
-        setState(s);
+        setState(await RunStrategy(file, false));
       }
     } catch (e: any) {
-      setError(e?.toString() ?? (isRunning ? 'Stop failed' : 'Run failed'));
+      const msg: string = e?.toString() ?? '';
+      if (!isRunning && msg.includes('other DPI bypass tools are running') &&
+        window.confirm(`${msg}\n\nЗапустить стратегию всё равно?`)) {
+        try {
+          setState(await RunStrategy(file, true));
+        } catch (e2: any) {
+          setError(e2?.toString() ?? 'Run failed');
+        }
+        return;
+      }
+      setError(msg || (isRunning ? 'Stop failed' : 'Run failed'));
     }
   };
 
@@ -157,7 +284,10 @@ function App() {
                   ) : (
                     <PlayCircle className="w-5 h-5" />
                   )}
-                  Запустить все тесты
+                  {isTesting && testProgress
+                    ? `Тестирование ${testProgress.completedCount}/${testProgress.total}` +
+                      (testProgress.remaining ? `, ~${Math.ceil(testProgress.remaining / 60)} мин` : '')
+                    : 'Запустить все тесты'}
                 </button>
               </div>
             </div>
@@ -168,6 +298,82 @@ function App() {
               </div>
             )}
 
+            {!isTesting && (state?.config?.lastRunChanges?.regressed ?? 0) > 0 && (
+              <div className="mb-4 p-4 bg-amber-100 border border-amber-400 text-amber-800 rounded-lg">
+                Стратегий, переставших работать с прошлого теста: {state?.config?.lastRunChanges?.regressed}
+              </div>
+            )}
+
+            {(state?.driverStatus?.state === 'error' || state?.driverStatus?.state === 'pending') && (
+              <div className="mb-4 p-4 bg-red-100 border border-red-400 text-red-700 rounded-lg flex items-center justify-between gap-4">
+                <span>
+                  Драйвер WinDivert {state.driverStatus.state === 'pending' ? 'завис при запуске или остановке' : 'не загружается'}
+                  {state.driverStatus.error ? `: ${state.driverStatus.error}` : ''}
+                </span>
+                <button
+                  onClick={handleResetDriver}
+                  className="px-4 py-2 bg-white hover:bg-gray-50 text-gray-700 rounded-lg font-medium shadow-md transition-all"
+                >
+                  Сбросить драйвер
+                </button>
+              </div>
+            )}
+
+            {state?.config?.paused && !running && (
+              <div className="mb-4 p-4 bg-gray-100 border border-gray-400 text-gray-800 rounded-lg flex items-center justify-between gap-4">
+                <span>Стратегия {state.config.paused.file} на паузе, обход блокировок выключен.</span>
+                <button
+                  onClick={handleResume}
+                  className="px-4 py-2 bg-white hover:bg-gray-50 text-gray-700 rounded-lg font-medium shadow-md transition-all"
+                >
+                  Продолжить
+                </button>
+              </div>
+            )}
+
+            {(state?.conflicts || []).filter((c) => c.kind !== 'zapret-service').length > 0 && (
+              <div className="mb-4 p-4 bg-amber-100 border border-amber-400 text-amber-800 rounded-lg">
+                Запущены другие средства обхода блокировок или VPN:{' '}
+                {(state?.conflicts || [])
+                  .filter((c) => c.kind !== 'zapret-service')
+                  .map((c) => (c.pid ? `${c.name} (PID ${c.pid})` : c.name))
+                  .join(', ')}
+                . Они мешают стратегиям, и результаты становятся непредсказуемыми.
+              </div>
+            )}
+
+            {state?.serviceInstalled && (
+              <div className="mb-4 p-4 bg-blue-100 border border-blue-400 text-blue-800 rounded-lg flex items-center justify-between gap-4">
+                <span>
+                  Установлена служба zapret{state.serviceStrategy ? ` (${state.serviceStrategy})` : ''}
+                  {state.serviceRunning ? ', она запущена: стратегии приложения запустить нельзя' : ', она остановлена'}.
+                </span>
+                <button
+                  onClick={handleRemoveService}
+                  className="px-4 py-2 bg-white hover:bg-gray-50 text-gray-700 rounded-lg font-medium shadow-md transition-all"
+                >
+                  Удалить службу
+                </button>
+              </div>
+            )}
+
+            {(state?.externalRunning || []).map((p) => (
+              <div key={p.pid} className="mb-4 p-4 bg-amber-100 border border-amber-400 text-amber-800 rounded-lg flex items-center justify-between gap-4">
+                <span title={p.commandLine}>
+                  Запущен сторонний winws.exe (PID {p.pid}{p.service ? ', служба' : ''})
+                  {p.strategy ? `: ${p.strategy}` : ''}. Он будет конфликтовать со стратегиями приложения.
+                </span>
+                {p.strategy && !running && (
+                  <button
+                    onClick={() => handleAdopt(p.pid)}
+                    className="px-4 py-2 bg-white hover:bg-gray-50 text-gray-700 rounded-lg font-medium shadow-md transition-all"
+                  >
+                    Отслеживать
+                  </button>
+                )}
+              </div>
+            ))}
+
             <div className="grid grid-cols-1 md:grid-cols-3 gap-4 mb-8">
               <div className="bg-white rounded-lg shadow-md p-6">
                 <div className="flex items-center justify-between">
@@ -221,8 +427,12 @@ function App() {
                   strategy={strategy}
                   isRunning={isRunning}
                   runningInfo={isRunning ? running : undefined}
+                  usage={isRunning ? state?.runningUsage : undefined}
                   isLoading={isRefreshing}
                   onToggleStrategy={handleToggleStrategy}
+                  onPause={isRunning ? handlePause : undefined}
+                  isService={state?.serviceInstalled && state.serviceStrategy === strategy.name}
+                  onInstallService={strategy.category === 'service' ? undefined : handleInstallService}
                 />
               );
             })}
@@ -1,20 +1,34 @@
-import { Server, Play, Square, CheckCircle, XCircle, Clock, Crown, CrownIcon } from 'lucide-react';
-import type { Strategy, RunningInfo } from '../types/models';
+import { Server, Play, Pause, Square, CheckCircle, XCircle, Clock, Crown, CrownIcon, AlertTriangle } from 'lucide-react';
+import type { Strategy, RunningInfo, RunningUsage } from '../types/models';
 
 interface StrategyCardProps {
   strategy: Strategy;
   isRunning: boolean;
   runningInfo?: RunningInfo;
+  usage?: RunningUsage;
   isLoading?: boolean;
   onToggleStrategy: (file: string, isRunning: boolean) => void;
+  onPause?: () => void;
+  isService?: boolean;
+  onInstallService?: (name: string) => void;
 }
 
+const formatUptime = (seconds: number) => {
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  return h > 0 ? `${h} ч ${m} мин` : `${m} мин`;
+};
+
 export default function StrategyCard({ 
   strategy, 
   isRunning, 
   runningInfo,
+  usage,
   isLoading, 
-  onToggleStrategy 
+  onToggleStrategy,
+  onPause,
+  isService,
+  onInstallService
 }: StrategyCardProps) {
   if (isLoading) {
     return (
@@ -94,18 +108,42 @@ export default function StrategyCard({
       </div>
 
       <div className="space-y-3 mb-4">
-        <div className="flex items-center gap-2 text-sm">
-          {getTestStatusIcon()}
-          <span className="text-gray-700">{getTestStatusText()}</span>
-        </div>
-        <div className="text-sm text-gray-600">
-          {getTestSummary()}
+        {/* Results measured on another release are shown faded: they may not hold for this one. */}
+        <div className={`space-y-3 ${strategy.stale ? 'opacity-50' : ''}`}>
+          <div className="flex items-center gap-2 text-sm">
+            {getTestStatusIcon()}
+            <span className="text-gray-700">{getTestStatusText()}</span>
+          </div>
+          <div className="text-sm text-gray-600">
+            {getTestSummary()}
+          </div>
         </div>
+        {strategy.stale && strategy.result?.version && (
+          <div className="text-xs text-gray-500">
+            Проверено на версии {strategy.result.version}
+          </div>
+        )}
+        {strategy.problems && strategy.problems.length > 0 && (
+          <div className="flex items-start gap-2 text-sm text-amber-700" title={strategy.problems.join('\n')}>
+            <AlertTriangle className="w-5 h-5 shrink-0" />
+            <span>Не хватает файлов: {strategy.problems.length}</span>
+          </div>
+        )}
         {isRunning && runningInfo && (
           <div className="text-sm text-gray-600">
-            PID: <span className="font-medium">{runningInfo.pid}</span>
+            PID: <span className="font-medium">{runningInfo.winwsPid || runningInfo.pid}</span>
+          </div>
+        )}
+        {isRunning && usage && (
+          <div className="text-sm text-gray-600">
+            Работает {formatUptime(usage.uptimeSeconds)}
+            {!usage.limited && ` · ${(usage.workingSet ?? 0) >> 20} МБ · CPU ${usage.cpuPercent.toFixed(1)}%`}
+            {(usage.restarts ?? 0) > 0 && ` · перезапусков: ${usage.restarts}`}
           </div>
         )}
+        {isService && (
+          <div className="text-sm text-blue-700">Установлена как служба</div>
+        )}
       </div>
 
       <button
@@ -128,6 +166,23 @@ export default function StrategyCard({
           </>
         )}
       </button>
+      {onPause && (
+        <button
+          onClick={onPause}
+          className="w-full mt-2 py-2 px-4 rounded-lg text-sm font-medium text-gray-700 bg-gray-100 hover:bg-gray-200 transition-colors flex items-center justify-center gap-2"
+        >
+          <Pause className="w-4 h-4" />
+          Пауза
+        </button>
+      )}
+      {onInstallService && !isService && (
+        <button
+          onClick={() => onInstallService(strategy.name)}
+          className="w-full mt-2 py-2 px-4 rounded-lg text-sm font-medium text-gray-700 bg-gray-100 hover:bg-gray-200 transition-colors"
+        >
+          Установить как службу
+        </button>
+      )}
     </div>
   );
 }
@@ -1,7 +1,28 @@
 export interface RunningInfo {
     file: string;
+    release?: string;
+    staged?: string;
     pid: number;
+    winwsPid?: number;
     startedAt: string;
+    sessionStartedAt?: string;
+    seenAt?: string;
+    restarts?: number;
+    log?: string;
+}
+
+export interface PausedInfo {
+    file: string;
+    release?: string;
+    pausedAt: string;
+}
+
+export interface RunningUsage {
+    uptimeSeconds: number;
+    workingSet?: number;
+    cpuPercent: number;
+    restarts?: number;
+    limited?: boolean;
 }
 
 export interface Config {
@@ -13,6 +34,58 @@ export interface Config {
     meta?: Record<string, any>;
     running?: RunningInfo;
     testInProgress: boolean;
+    paused?: PausedInfo;
+    testPid?: number;
+    testStartedAt?: string;
+    testLog?: string;
+    testTargets?: string[];
+    testDomains?: string[];
+    parseWarnings?: string[];
+    testJob?: TestJob;
+    lastRunChanges?: RunChanges;
+    quickCheckTargets?: string[];
+    quickCheck?: QuickCheckResult;
+    proxyUrl?: string;
+    mirrors?: string[];
+    apiMirrors?: string[];
+    updateChannel?: 'stable' | 'prerelease';
+    skippedVersions?: string[];
+    latestCheckMinutes?: number;
+    assetPattern?: string;
+    updateCheckDisabled?: boolean;
+    pendingVersion?: string;
+    favorites?: string[];
+    hidden?: string[];
+    strategyMeta?: Record<string, { alias?: string; note?: string; tags?: string[] }>;
+    runStats?: Record<string, RunStats>;
+    settings: Settings;
+    keepReleases: number;
+}
+
+export interface Settings {
+    hideProcesses: boolean;
+    testTimeoutMinutes?: number;
+    resultGraceSeconds?: number;
+    updateCheckHours?: number;
+    autoRunOnLaunch: boolean;
+    closeToTray: boolean;
+    autoApplyBest: boolean;
+    silenceNotifications: boolean;
+    failover: boolean;
+    failoverCheckMinutes?: number;
+    failoverFailures?: number;
+    failoverMaxSwitches?: number;
+    failoverDwellMinutes?: number;
+    failoverChain?: string[];
+    restartOnCrash: boolean;
+    restartAttempts?: number;
+    stopGraceSeconds?: number;
+    keepStrategyOnExit: boolean;
+    keepAwake: boolean;
+    restartOnResume: boolean;
+    skipDuplicateTests: boolean;
+    customDomainWeight?: number;
+    autoStart: boolean;
 }
 
 export interface TestResult {
@@ -26,13 +99,258 @@ export interface TestResult {
     blocked: number;
     status: string;
     lastTestedAt?: string;
+    version?: string;
+    mode?: 'standard' | 'dpi' | 'native';
+    domains?: Record<string, 'ok' | 'fail'>;
+    domainResults?: Record<string, 'ok' | 'err' | 'blocked'>;
+    durationMs?: number;
+}
+
+export interface TestRun {
+    id: string;
+    at: string;
+    version: string;
+    mode: string;
+    partial?: boolean;
+    results: Record<string, TestResult>;
+    best: string;
+}
+
+export interface StrategyHistoryPoint {
+    at: string;
+    version: string;
+    mode: string;
+    score: number;
+    status: string;
+    best: boolean;
+}
+
+export interface TestOptions {
+    mode: '' | 'standard' | 'dpi';
+    configs?: string[];
+    autoApplyBest?: boolean;
+    keepRunning?: boolean;
+    restoreBest?: boolean;
+    skipDiagnostics?: boolean;
+}
+
+export interface QuickCheckEndpoint {
+    target: string;
+    ok: boolean;
+    latencyMs: number;
+    error?: string;
+}
+
+export interface QuickCheckResult {
+    at: string;
+    strategy?: string;
+    endpoints: QuickCheckEndpoint[];
+    passed: number;
+    failed: number;
+}
+
+export interface FailoverEvent {
+    from: string;
+    to?: string;
+    error?: string;
+}
+
+export interface AutoApplied {
+    strategy: string;
+    substituted?: string;
+    error?: string;
+}
+
+export interface StrategyDelta {
+    name: string;
+    statusBefore: string;
+    statusAfter: string;
+    scoreBefore: number;
+    scoreAfter: number;
+    scoreDelta: number;
+}
+
+export interface RunComparison {
+    from: string;
+    fromAt: string;
+    to: string;
+    toAt: string;
+    strategies: StrategyDelta[];
+    regressed: string[];
+    improved: string[];
+    bestBefore: string;
+    bestAfter: string;
+}
+
+export interface RunChanges {
+    regressed: number;
+    improved: number;
+    bestChanged: boolean;
+    bestBefore?: string;
+    bestAfter?: string;
+}
+
+export type ResultsFormat = 'csv' | 'json' | 'markdown-table';
+
+export interface TestJob {
+    id: string;
+    status: 'running' | 'succeeded' | 'failed' | 'canceled';
+    mode: string;
+    configs?: string[];
+    startedAt: string;
+    finishedAt: string;
+    elapsed: number;
+    error?: string;
+    diagnostics?: DiagnosticsReport;
+}
+
+export interface DiagnosticCheck {
+    name: 'internet' | 'dns' | 'powershell' | 'other dpi tools' | 'vpn';
+    ok: boolean;
+    detail?: string;
+    blocking: boolean;
+}
+
+export interface DiagnosticsReport {
+    at: string;
+    checks: DiagnosticCheck[];
+    conflicts?: Conflict[];
+    ok: boolean;
+}
+
+export interface Conflict {
+    kind: 'zapret-service' | 'goodbyedpi' | 'byedpi' | 'winws' | 'vpn';
+    name: string;
+    pid?: number;
+    warning?: boolean;
 }
 
 export interface Strategy {
     name: string;
     file: string;
+    category: 'general' | 'discord' | 'util' | 'service';
+    details?: StrategyDetails;
+    custom?: boolean;
     result?: TestResult;
+    score: number;
     best?: boolean;
+    stale?: boolean;
+    alias?: string;
+    note?: string;
+    tags?: string[];
+    favorite?: boolean;
+    hidden?: boolean;
+    problems?: string[];
+    group: string;
+    gameFilter: boolean;
+    requiresAdmin: boolean;
+    stats?: RunStats;
+    fingerprint?: string;
+    duplicateOf?: string;
+}
+
+export interface StrategyQuery {
+    sortBy?: '' | 'name' | 'score' | 'lastTested';
+    includeHidden?: boolean;
+    tags?: string[];
+    matchAllTags?: boolean;
+}
+
+export interface RunStats {
+    launches: number;
+    lastLaunchedAt: string;
+    runtimeSeconds: number;
+    crashes?: number;
+    restarts?: number;
+}
+
+export interface ResumeEvent {
+    strategy: string;
+    restarted: boolean;
+    error?: string;
+}
+
+export interface CrashEvent {
+    strategy: string;
+    attempt: number;
+    restarted: boolean;
+    gaveUp?: boolean;
+    error?: string;
+}
+
+export interface StrategyDetails {
+    profiles: number;
+    hostlists?: string[];
+    ipsets?: string[];
+    desyncMethods?: string[];
+    tcpPorts?: string[];
+    udpPorts?: string[];
+    fakeOptions?: string[];
+    raw: string;
+    parsed: boolean;
+}
+
+export interface ListFile {
+    name: string;
+    path: string;
+    entries: number;
+    exclude: boolean;
+    hasBackup: boolean;
+}
+
+export interface ListEditResult {
+    changed: boolean;
+    restartNeeded: boolean;
+    running?: string;
+    restarted?: boolean;
+    restartError?: string;
+    warnings?: string[];
+}
+
+export interface StrategyDiff {
+    name: string;
+    status: 'added' | 'removed' | 'changed';
+    diff?: string;
+}
+
+export interface StrategyContent {
+    name: string;
+    path: string;
+    size: number;
+    encoding: string;
+    text: string;
+}
+
+export interface StrategyOptions {
+    base: string;
+    hostlist?: string;
+    desync?: string;
+    repeats?: number;
+    fakeTls?: string;
+    fakeQuic?: string;
+}
+
+export interface DriverStatus {
+    service?: string;
+    state: 'not-installed' | 'stopped' | 'running' | 'pending' | 'error';
+    code?: number;
+    error?: string;
+}
+
+export interface ExternalProcess {
+    pid: number;
+    path?: string;
+    commandLine?: string;
+    startedAt: string;
+    strategy?: string;
+    service?: boolean;
+}
+
+export interface StopResult {
+    tracked: number;
+    releases: number;
+    other: number;
+    graceful: boolean;
 }
 
 export interface State {
@@ -41,6 +359,93 @@ export interface State {
     latestTag?: string;
     hasUpdate?: boolean;
     currentPath?: string;
+    baseDir?: string;
+    releaseHealthy?: boolean;
+    missingFiles?: string[];
+    pendingVersion?: string;
+    appVersion?: string;
+    latestAppTag?: string;
+    hasAppUpdate?: boolean;
+    releaseNotes?: string;
+    repair?: RepairSummary;
+    listMigration?: ListMigration[];
+    strategyChanges?: StrategyDiff[];
     lastTestLog?: string;
+    autoStartWarning?: string;
+    testCanceled?: 'partial' | 'none';
+    configRecovery?: string;
+    elevated: boolean;
+    winwsVersion?: string;
+    parseWarnings?: string[];
+    autoApplied?: AutoApplied;
+    retested?: string[];
+    stopped?: StopResult;
+    quickCheck?: QuickCheckResult;
     running?: RunningInfo;
+    externalRunning?: ExternalProcess[];
+    driverStatus: DriverStatus;
+    serviceInstalled: boolean;
+    serviceRunning: boolean;
+    serviceStrategy?: string;
+    conflicts?: Conflict[];
+    runningUsage?: RunningUsage;
+}
+
+export interface DownloadProgress {
+    tag: string;
+    phase: 'downloading' | 'unpacking' | 'done';
+    percent: number;
+    bytes: number;
+    total: number;
+}
+
+export interface TestProgress {
+    current: string;
+    completedCount: number;
+    total: number;
+    elapsed: number;
+    remaining?: number;
+}
+
+export interface ReleaseInfo {
+    tag: string;
+    path: string;
+    sizeBytes: number;
+    current: boolean;
+    modTime: string;
+}
+
+export interface PruneSummary {
+    deleted: string[];
+    reclaimedBytes: number;
+}
+
+export interface RepairSummary {
+    tag: string;
+    replaced: string[];
+    restored: string[];
+}
+
+export interface ListMigration {
+    file: string;
+    addedLines?: string[];
+    copied?: boolean;
+}
+
+export interface LatestTagEvent {
+    latestTag: string;
+    hasUpdate: boolean;
+    releaseNotes: string;
+    error?: string;
+}
+
+export interface StorageUsage {
+    releasesBytes: number;
+    logsBytes: number;
+    freeBytes: number;
+}
+
+export interface AutoRunEvent {
+    strategy: string;
+    error?: string;
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxTestHistory is the number of test runs kept in the history file.
+const maxTestHistory = 30
+
+// TestRun is one test run in the history.
+type TestRun struct {
+	// ID identifies the run in CompareRuns; it is At in RFC 3339 form.
+	ID      string    `json:"id"`
+	At      time.Time `json:"at"`
+	Version string    `json:"version"`
+	// Mode is "standard", "dpi" or "native".
+	Mode string `json:"mode"`
+	// Partial is set for runs of some configs only; Results then holds just those.
+	Partial bool                  `json:"partial,omitempty"`
+	Results map[string]TestResult `json:"results"`
+	Best    string                `json:"best"`
+}
+
+// StrategyHistoryPoint is a strategy's outcome in one test run.
+type StrategyHistoryPoint struct {
+	At      time.Time `json:"at"`
+	Version string    `json:"version"`
+	Mode    string    `json:"mode"`
+	Score   int       `json:"score"`
+	Status  string    `json:"status"`
+	Best    bool      `json:"best"`
+}
+
+// GetTestHistory returns the recorded test runs, oldest first.
+func (s *Service) GetTestHistory() ([]TestRun, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	return s.loadTestHistory()
+}
+
+// GetStrategyHistory returns the outcomes of the strategy name across the recorded runs that
+// tested it, oldest first, scored the way strategies are ranked now.
+func (s *Service) GetStrategyHistory(name string) ([]StrategyHistoryPoint, error) {
+	runs, err := s.GetTestHistory()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	res := []StrategyHistoryPoint{}
+	for _, run := range runs {
+		r, ok := run.Results[name]
+		if !ok {
+			continue
+		}
+		res = append(res, StrategyHistoryPoint{
+			At:      run.At,
+			Version: run.Version,
+			Mode:    run.Mode,
+			Score:   scoreResult(r, cfg.Settings.domainWeight()),
+			Status:  r.Status,
+			Best:    run.Best == name,
+		})
+	}
+	return res, nil
+}
+
+// typicalTestDuration returns the average time a strategy took to test in the recorded runs of
+// mode, or of any mode when mode is "", and 0 when none was measured.
+func (s *Service) typicalTestDuration(mode string) time.Duration {
+	runs, err := s.GetTestHistory()
+	if err != nil {
+		return 0
+	}
+	var sum time.Duration
+	n := 0
+	for _, run := range runs {
+		if mode != "" && run.Mode != "" && run.Mode != mode {
+			continue
+		}
+		for _, r := range run.Results {
+			if r.DurationMs > 0 {
+				sum += time.Duration(r.DurationMs) * time.Millisecond
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / time.Duration(n)
+}
+
+// recordTestRun appends a finished run to the history, dropping the oldest runs beyond
+// maxTestHistory.
+func (s *Service) recordTestRun(run TestRun) {
+	if len(run.Results) == 0 {
+		return
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	runs, err := s.loadTestHistory()
+	if err != nil {
+		// Keep the damaged file for inspection, as loadConfig does, and start a new history.
+		path := s.dirs().historyPath
+		bad := fmt.Sprintf("%s.bad-%s", path, time.Now().Format("20060102-150405"))
+		if rerr := os.Rename(path, bad); rerr != nil {
+			s.logUpdate("reading test history: %v; not recording the run, since moving the file aside failed: %v", err, rerr)
+			return
+		}
+		s.logUpdate("reading test history: %v; moved it to %s and started a new one", err, bad)
+		runs = nil
+	}
+	runs = append(runs, run)
+	if len(runs) > maxTestHistory {
+		runs = runs[len(runs)-maxTestHistory:]
+	}
+	if err := s.saveTestHistory(runs); err != nil {
+		s.logUpdate("saving test history: %v", err)
+	}
+}
+
+// migrateTestHistory starts the history with the results kept in the config by versions that
+// only remembered the latest run.
+func (s *Service) migrateTestHistory() {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	if _, err := os.Stat(s.dirs().historyPath); !os.IsNotExist(err) {
+		return
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil || len(cfg.TestResults) == 0 {
+		return
+	}
+	run := newTestRun(cfg.TestResults, cfg.BestStrategy, cfg.LastTestAt, false)
+	if run.Version == "" {
+		run.Version = cfg.Version
+	}
+	if err := s.saveTestHistory([]TestRun{run}); err != nil {
+		s.logUpdate("saving test history: %v", err)
+	}
+}
+
+// loadTestHistory reads the history file. historyMu must be held.
+func (s *Service) loadTestHistory() ([]TestRun, error) {
+	data, err := os.ReadFile(s.dirs().historyPath)
+	if os.IsNotExist(err) {
+		return []TestRun{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []TestRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].At.Before(runs[j].At) })
+	for i := range runs {
+		if runs[i].ID == "" {
+			runs[i].ID = testRunID(runs[i].At)
+		}
+	}
+	return runs, nil
+}
+
+// saveTestHistory writes runs to the history file. historyMu must be held.
+func (s *Service) saveTestHistory(runs []TestRun) error {
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.dirs().historyPath, data, 0o644)
+}
+
+// newTestRun describes a run from its results; version and mode are taken from the results.
+func newTestRun(results map[string]TestResult, best string, at time.Time, partial bool) TestRun {
+	run := TestRun{ID: testRunID(at), At: at, Partial: partial, Results: make(map[string]TestResult, len(results)), Best: best}
+	for name, r := range results {
+		run.Results[name] = r
+		if run.Version == "" {
+			run.Version = r.Version
+		}
+		if run.Mode == "" {
+			run.Mode = r.Mode
+		}
+	}
+	return run
+}
+
+// testRunID is the ID of the run made at at.
+func testRunID(at time.Time) string {
+	return at.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordTestRunMovesDamagedHistoryAside(t *testing.T) {
+	s, _ := newTestService(t, "general.bat")
+	path := s.dirs().historyPath
+	if err := os.WriteFile(path, []byte("[{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results := map[string]TestResult{"general.bat": {Name: "general.bat", HTTP_OK: 5, Status: "ok"}}
+	s.recordTestRun(newTestRun(results, "general.bat", time.Now(), false))
+
+	bad, _ := filepath.Glob(path + ".bad-*")
+	if len(bad) != 1 {
+		t.Fatalf("damaged history kept as %v, want one .bad- file", bad)
+	}
+	if data, err := os.ReadFile(bad[0]); err != nil || string(data) != "[{not json" {
+		t.Errorf("moved-aside history = %q, %v", data, err)
+	}
+	s.historyMu.Lock()
+	runs, err := s.loadTestHistory()
+	s.historyMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Best != "general.bat" {
+		t.Errorf("new history = %+v, want just the recorded run", runs)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processJob is a Windows job object whose processes are killed when its last handle closes,
+// which the system does when the app exits or crashes.
+type processJob struct {
+	h windows.Handle
+}
+
+// newProcessJob creates an empty kill-on-close job.
+func newProcessJob() (*processJob, error) {
+	h, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(h, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	return &processJob{h: h}, nil
+}
+
+// Close kills whatever still runs in the job.
+func (j *processJob) Close() error {
+	return windows.CloseHandle(j.h)
+}
+
+// startInJob starts cmd in a new console and, with a job, puts it in the job before it runs, so
+// nothing it starts escapes the job. A nil job just starts cmd.
+func startInJob(cmd *exec.Cmd, hidden bool, job *processJob) error {
+	flags := uint32(createNewConsole)
+	if job != nil {
+		flags |= windows.CREATE_SUSPENDED
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= flags
+	cmd.SysProcAttr.HideWindow = hidden
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if job == nil {
+		return nil
+	}
+	pid := uint32(cmd.Process.Pid)
+	err := assignToJob(job, pid)
+	// Resumed even when the job couldn't take it: the strategy then runs as if no job was asked for.
+	resumeProcess(pid)
+	return err
+}
+
+// assignToJob puts the process pid in job.
+func assignToJob(job *processJob, pid uint32) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, pid)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	return windows.AssignProcessToJobObject(job.h, h)
+}
+
+// resumeProcess resumes the threads of the process pid, started suspended.
+func resumeProcess(pid uint32) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(snap)
+	e := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snap, &e); err == nil; err = windows.Thread32Next(snap, &e) {
+		if e.OwnerProcessID != pid {
+			continue
+		}
+		if h, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, e.ThreadID); err == nil {
+			_, _ = windows.ResumeThread(h)
+			windows.CloseHandle(h)
+		}
+	}
+}
+
+// closeRunJob closes the job of the running strategy, killing what is left of it. The caller
+// holds runMu.
+func (s *Service) closeRunJob() {
+	if s.runJob != nil {
+		_ = s.runJob.Close()
+		s.runJob = nil
+	}
+}
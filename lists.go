@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDomainLen is the longest host name DNS allows.
+const maxDomainLen = 253
+
+// domainRe matches a lowercase ASCII host name; a single label such as a TLD is allowed, since
+// winws hostlists match every subdomain of an entry.
+var domainRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// listBackupDir is the folder in a release that holds the copies made by backupList.
+const listBackupDir = "lists-backup"
+
+// ListFile is a hostlist in the current release's lists folder.
+type ListFile struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+	// Exclude is set for exclusion lists, whose domains winws leaves alone.
+	Exclude bool `json:"exclude"`
+	// HasBackup is set when RestoreListBackup has a copy to restore.
+	HasBackup bool `json:"hasBackup"`
+}
+
+// ListEditResult reports the outcome of AddListEntry, RemoveListEntry and RestoreListBackup.
+type ListEditResult struct {
+	// Changed is false when the domain was already in the list (add) or not in it (remove).
+	Changed bool `json:"changed"`
+	// RestartNeeded is set when a strategy is running: winws reads lists only at startup, so the
+	// change applies once the strategy is started again with RestartRunning.
+	RestartNeeded bool   `json:"restartNeeded"`
+	Running       string `json:"running,omitempty"`
+	// Restarted is set when autoRestart restarted the running strategy; RestartError is why it
+	// couldn't, the edit itself being kept.
+	Restarted    bool   `json:"restarted,omitempty"`
+	RestartError string `json:"restartError,omitempty"`
+	// Warnings are about a change that was made but may not do what the user expects.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// isHostlist reports whether a lists/ file name is an editable hostlist.
+func isHostlist(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "list-") && strings.HasSuffix(lower, ".txt")
+}
+
+// isExcludeList reports whether the hostlist name is an exclusion list.
+func isExcludeList(name string) bool {
+	return strings.Contains(strings.ToLower(name), "exclude")
+}
+
+// GetLists returns the hostlists of the current release.
+func (s *Service) GetLists() ([]ListFile, error) {
+	dir, err := s.listsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := []ListFile{}
+	for _, e := range entries {
+		if e.IsDir() || !isHostlist(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		lines, _, err := readListFile(path)
+		if err != nil {
+			return nil, err
+		}
+		backup, _ := latestListBackup(filepath.Dir(dir), e.Name())
+		res = append(res, ListFile{
+			Name:      e.Name(),
+			Path:      path,
+			Entries:   len(listEntries(lines)),
+			Exclude:   isExcludeList(e.Name()),
+			HasBackup: backup != "",
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// GetListEntries returns the domains in the hostlist name, without comments and blank lines.
+func (s *Service) GetListEntries(name string) ([]string, error) {
+	path, err := s.listPath(name)
+	if err != nil {
+		return nil, err
+	}
+	lines, _, err := readListFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return listEntries(lines), nil
+}
+
+// AddListEntry adds domain to the hostlist name unless it is already there. Adding to an exclusion
+// list warns when an inclusion list covers the same domain. With autoRestart a running strategy is
+// restarted to pick up the change, as with every list edit.
+func (s *Service) AddListEntry(name, domain string, autoRestart bool) (*ListEditResult, error) {
+	res, err := s.addListEntry(name, domain)
+	if err == nil && autoRestart {
+		s.restartForEdit(res)
+	}
+	return res, err
+}
+
+// addListEntry is AddListEntry without the restart.
+func (s *Service) addListEntry(name, domain string) (*ListEditResult, error) {
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.editList(name, func(lines []string) ([]string, bool) {
+		for _, l := range lines {
+			if strings.EqualFold(strings.TrimSpace(l), domain) {
+				return lines, false
+			}
+		}
+		// A trailing blank line stands for the final line ending; keep it last.
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			return append(lines[:n-1], domain, ""), true
+		}
+		return append(lines, domain, ""), true
+	})
+	if err != nil || !res.Changed || !isExcludeList(name) {
+		return res, err
+	}
+	lists, err := s.GetLists()
+	if err != nil {
+		return res, nil
+	}
+	for _, l := range lists {
+		if l.Exclude {
+			continue
+		}
+		entries, _ := s.GetListEntries(l.Name)
+		for _, e := range entries {
+			if domainsOverlap(domain, strings.ToLower(e)) {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s is also covered by %s in %s", domain, e, l.Name))
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// RemoveListEntry removes domain from the hostlist name.
+func (s *Service) RemoveListEntry(name, domain string, autoRestart bool) (*ListEditResult, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return nil, errors.New("domain is empty")
+	}
+	res, err := s.editList(name, func(lines []string) ([]string, bool) {
+		kept := lines[:0:0]
+		for _, l := range lines {
+			if strings.EqualFold(strings.TrimSpace(l), domain) {
+				continue
+			}
+			kept = append(kept, l)
+		}
+		return kept, len(kept) != len(lines)
+	})
+	if err == nil && autoRestart {
+		s.restartForEdit(res)
+	}
+	return res, err
+}
+
+// RestoreListBackup puts back the most recent backup of the hostlist name, undoing the edits made
+// since it was taken.
+func (s *Service) RestoreListBackup(name string, autoRestart bool) (*ListEditResult, error) {
+	res, err := s.restoreListBackup(name)
+	if err == nil && autoRestart {
+		s.restartForEdit(res)
+	}
+	return res, err
+}
+
+// restoreListBackup is RestoreListBackup without the restart.
+func (s *Service) restoreListBackup(name string) (*ListEditResult, error) {
+	path, err := s.listPath(name)
+	if err != nil {
+		return nil, err
+	}
+	s.listMu.Lock()
+	defer s.listMu.Unlock()
+	backup, err := latestListBackup(filepath.Dir(filepath.Dir(path)), name)
+	if err != nil {
+		return nil, err
+	}
+	if backup == "" {
+		return nil, fmt.Errorf("there is no backup of %s", name)
+	}
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
+		return nil, err
+	}
+	res := &ListEditResult{Changed: true}
+	s.markRestartNeeded(res)
+	return res, nil
+}
+
+// editList rewrites the hostlist name with the lines returned by edit, keeping its line endings.
+// The file is backed up before its first change in this session.
+func (s *Service) editList(name string, edit func([]string) ([]string, bool)) (*ListEditResult, error) {
+	path, err := s.listPath(name)
+	if err != nil {
+		return nil, err
+	}
+	s.listMu.Lock()
+	defer s.listMu.Unlock()
+	lines, eol, err := readListFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines, changed := edit(lines)
+	res := &ListEditResult{Changed: changed}
+	if !changed {
+		return res, nil
+	}
+	if !s.listBackedUp[path] {
+		if err := backupList(path); err != nil {
+			return nil, fmt.Errorf("backing up %s: %w", name, err)
+		}
+		if s.listBackedUp == nil {
+			s.listBackedUp = make(map[string]bool)
+		}
+		s.listBackedUp[path] = true
+	}
+	if err := writeFileAtomic(path, []byte(strings.Join(lines, eol)), 0o644); err != nil {
+		return nil, err
+	}
+	s.markRestartNeeded(res)
+	return res, nil
+}
+
+// markRestartNeeded flags res when a running strategy still uses the old list contents.
+func (s *Service) markRestartNeeded(res *ListEditResult) {
+	if cfg, err := s.configSnapshot(); err == nil && cfg.Running != nil && cfg.Running.alive() {
+		res.RestartNeeded = true
+		res.Running = cfg.Running.File
+	}
+}
+
+// restartForEdit restarts the running strategy when res needs it, recording the outcome in res.
+func (s *Service) restartForEdit(res *ListEditResult) {
+	if !res.RestartNeeded {
+		return
+	}
+	if _, err := s.RestartRunning(); err != nil {
+		res.RestartError = err.Error()
+		return
+	}
+	res.RestartNeeded, res.Restarted = false, true
+}
+
+// backupList copies the list file at path into the release's backup folder under a timestamped
+// name.
+func backupList(path string) error {
+	dir := filepath.Join(filepath.Dir(filepath.Dir(path)), listBackupDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path) + "." + time.Now().Format("20060102-150405")
+	return copyFile(path, filepath.Join(dir, name), info.Mode())
+}
+
+// latestListBackup returns the newest backup of the list name in release, or "" if there is none.
+func latestListBackup(release, name string) (string, error) {
+	dir := filepath.Join(release, listBackupDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	latest := ""
+	for _, e := range entries {
+		// The timestamp suffix sorts chronologically.
+		if !e.IsDir() && strings.HasPrefix(e.Name(), name+".") && e.Name() > latest {
+			latest = e.Name()
+		}
+	}
+	if latest == "" {
+		return "", nil
+	}
+	return filepath.Join(dir, latest), nil
+}
+
+// domainsOverlap reports whether one domain equals the other or is a subdomain of it.
+func domainsOverlap(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// listsDir is the lists folder of the current release.
+func (s *Service) listsDir() (string, error) {
+	current := s.currentReleasePath()
+	if current == "" {
+		return "", errors.New("no current release")
+	}
+	return filepath.Join(current, "lists"), nil
+}
+
+// listPath resolves the hostlist name in the current release.
+func (s *Service) listPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !isHostlist(name) {
+		return "", fmt.Errorf("%q is not a hostlist", name)
+	}
+	dir, err := s.listsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// readListFile splits a list file into lines and returns the line ending it uses. A file ending
+// with a line break yields a trailing empty line.
+func readListFile(path string) ([]string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	eol := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+	if len(data) == 0 {
+		return nil, eol, nil
+	}
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), eol, nil
+}
+
+// listEntries drops blank and comment lines.
+func listEntries(lines []string) []string {
+	res := []string{}
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// normalizeDomain lowercases domain, strips a pasted URL down to its host and checks the result
+// is a host name winws can match.
+func normalizeDomain(domain string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(d, "://"); i >= 0 {
+		d = d[i+3:]
+	}
+	if i := strings.IndexAny(d, "/?#"); i >= 0 {
+		d = d[:i]
+	}
+	d = strings.TrimSuffix(d, ".")
+	if d == "" {
+		return "", errors.New("domain is empty")
+	}
+	if strings.ContainsAny(d, "*?") {
+		return "", fmt.Errorf("%q: hostlists don't support wildcards; an entry already covers all of its subdomains", domain)
+	}
+	if len(d) > maxDomainLen || !domainRe.MatchString(d) {
+		return "", fmt.Errorf("%q is not a valid domain; non-Latin domains must be entered in punycode (xn--...)", domain)
+	}
+	return d, nil
+}
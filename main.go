@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"embed"
+	"os"
+	"strings"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -14,16 +16,52 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// singleInstanceID identifies the app for the single-instance lock; a second launch hands over to
+// the running instance and exits.
+const singleInstanceID = "zapret-ui-5f3c2a9e-8b1d-4e07-a6c4-2d9f1e7b3a60"
+
+const (
+	// flagMinimized starts the app hidden in the tray; autostart passes it.
+	flagMinimized = "--minimized"
+	// flagNoAutoRun skips Settings.AutoRunOnLaunch for this launch, for troubleshooting.
+	flagNoAutoRun = "--no-autorun"
+	// flagWaitPID=<pid> waits for the instance that relaunched the app as administrator to exit.
+	flagWaitPID = "--wait-pid"
+)
+
+// hasFlag reports whether the app was launched with the command-line flag name.
+func hasFlag(name string) bool {
+	for _, a := range os.Args[1:] {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValue returns the value of a name=value command-line flag, or "" when it wasn't passed.
+func flagValue(name string) string {
+	for _, a := range os.Args[1:] {
+		if k, v, ok := strings.Cut(a, "="); ok && strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
 func main() {
+	waitForPreviousInstance()
+
 	// Create an instance of the app structure
 	app := NewApp()
 
 	// Create application with options
 	err := wails.Run(&options.App{
-		Title:             "zapret-ui",
-		Width:             1024,
-		Height:            768,
-		HideWindowOnClose: true,
+		Title:  "zapret-ui",
+		Width:  1024,
+		Height: 768,
+		// Autostart launches straight into the tray.
+		StartHidden: hasFlag(flagMinimized),
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
@@ -32,7 +70,14 @@ func main() {
 			app.startup(ctx)
 			startTray(ctx)
 		},
-		OnShutdown: app.shutdown,
+		OnBeforeClose: app.beforeClose,
+		OnShutdown:    app.shutdown,
+		// The lock is a named mutex the OS releases when the owning process dies, so a crash
+		// never leaves a stale lock behind.
+		SingleInstanceLock: &options.SingleInstanceLock{
+			UniqueId:               singleInstanceID,
+			OnSecondInstanceLaunch: app.onSecondInstanceLaunch,
+		},
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// testModeNative marks results measured by RunNativeTests rather than the test script.
+const testModeNative = "native"
+
+// nativeProbeTimeout bounds each TCP and QUIC probe of a native test.
+const nativeProbeTimeout = 6 * time.Second
+
+// nativeTarget is a host the native tester checks with each strategy.
+type nativeTarget struct {
+	Host string
+	// QUIC is set for hosts that serve HTTP/3, whose UDP path is checked as well.
+	QUIC bool
+}
+
+// nativeTestTargets are the Discord and YouTube endpoints the official test script checks, plus
+// Google and Cloudflare as a baseline.
+var nativeTestTargets = []nativeTarget{
+	{Host: "discord.com"},
+	{Host: "gateway.discord.gg"},
+	{Host: "cdn.discordapp.com"},
+	{Host: "updates.discord.com"},
+	{Host: "www.youtube.com", QUIC: true},
+	{Host: "i.ytimg.com", QUIC: true},
+	{Host: "redirector.googlevideo.com", QUIC: true},
+	{Host: "www.google.com", QUIC: true},
+	{Host: "www.cloudflare.com", QUIC: true},
+}
+
+// RunNativeTests tests every general strategy without the PowerShell script: each one is started in
+// turn, the standard endpoints are requested over HTTPS and checked for TCP and QUIC reachability,
+// and the strategy is torn down before the next one. HTTPS requests count as HTTP checks and
+// reachability probes as pings, so the results compare with RunTests. A running strategy is
+// stopped first and started again when the run is over.
+func (s *Service) RunNativeTests() (*State, error) {
+	if !s.testing.CompareAndSwap(false, true) {
+		return nil, &TestInProgressError{}
+	}
+	defer s.testing.Store(false)
+	runCtx, stop := context.WithCancel(context.Background())
+	defer s.trackTestRun(stop)()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if !isElevated() {
+		return nil, &AdminRequiredError{Strategy: "native testing"}
+	}
+	// Registered before the staging cleanup below, so a custom strategy is restarted after it.
+	restore, err := s.pauseRunningForTests(false)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	// Resolved after the pause, which can apply a pending update.
+	current := s.currentReleasePath()
+	if current == "" {
+		return nil, errors.New("no current release")
+	}
+	defer s.stageCustomForTests(current)()
+	names, err := testConfigNames(current)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Settings.SkipDuplicateTests {
+		names = s.withoutDuplicates(names)
+	}
+
+	_ = s.updateConfig(func(cfg *Config) error {
+		cfg.TestResults = make(map[string]TestResult)
+		cfg.BestStrategy = ""
+		cfg.TestInProgress = true
+		cfg.TestTargets = nil
+		cfg.LastTestAt = time.Now()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(runCtx, s.testTimeout())
+	defer cancel()
+	client := newProbeClient()
+	version := filepath.Base(current)
+	started := time.Now()
+	results := make(map[string]TestResult)
+	typical := s.typicalTestDuration(testModeNative)
+	var measured []time.Duration
+	for i, name := range names {
+		s.emit(eventTestProgress, TestProgress{
+			Current:        name,
+			CompletedCount: i,
+			Total:          len(names),
+			Elapsed:        int(time.Since(started).Seconds()),
+			Remaining:      estimateRemaining(measured, typical, len(names)-i, 0),
+		})
+		strategyStarted := time.Now()
+		r, err := nativeTestStrategy(ctx, current, name, client, cfg.TestDomains)
+		if ctx.Err() != nil {
+			break
+		}
+		measured = append(measured, time.Since(strategyStarted))
+		if err != nil {
+			s.logUpdate("native test of %s: %v", name, err)
+			continue
+		}
+		r.DurationMs = time.Since(strategyStarted).Milliseconds()
+		r.Version = version
+		results[name] = r
+		s.emit(eventTestResult, r)
+	}
+	s.emit(eventTestProgress, TestProgress{CompletedCount: len(results), Total: len(names), Elapsed: int(time.Since(started).Seconds())})
+
+	var parsed *parsedResults
+	if len(results) > 0 {
+		parsed = &parsedResults{Results: results, Best: bestStrategy(results, cfg.Settings.domainWeight())}
+	}
+	canceled := runCtx.Err() != nil
+	if canceled {
+		s.markTestCanceled(parsed != nil)
+	}
+	s.finishTests(parsed, nil)
+	state, stateErr := s.State()
+	var runErr error
+	switch {
+	case canceled:
+		runErr = &TestsCanceledError{Partial: parsed != nil}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		runErr = &TestTimeoutError{Timeout: s.testTimeout()}
+	case parsed == nil:
+		runErr = errors.New("no strategy could be tested")
+	}
+	s.notifyTestsDone(runErr)
+	if runErr != nil {
+		return state, runErr
+	}
+	if applied := s.applyBestAfterTests(false); applied != nil {
+		if state, stateErr = s.State(); state != nil {
+			state.AutoApplied = applied
+		}
+	}
+	return state, stateErr
+}
+
+// nativeTestStrategy starts the strategy name and measures the standard endpoints and the custom
+// test domains through it.
+func nativeTestStrategy(ctx context.Context, current, name string, client *http.Client, domains []string) (TestResult, error) {
+	if _, err := os.Stat(filepath.Join(current, name)); err != nil {
+		return TestResult{}, err
+	}
+	stop, err := startProbeStrategy(ctx, current, name)
+	if err != nil {
+		return TestResult{}, err
+	}
+	defer stop()
+
+	r := TestResult{Name: name, Mode: testModeNative}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	check := func(probe func() bool, ok, fail *int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			passed := probe()
+			mu.Lock()
+			if passed {
+				*ok++
+			} else {
+				*fail++
+			}
+			mu.Unlock()
+		}()
+	}
+	for _, t := range nativeTestTargets {
+		host := t.Host
+		check(func() bool { return probeHTTPS(ctx, client, host) }, &r.HTTP_OK, &r.HTTP_ERR)
+		check(func() bool { return probeTCP(ctx, host) }, &r.PingOK, &r.PingFail)
+		if t.QUIC {
+			check(func() bool { return probeQUIC(ctx, host) }, &r.PingOK, &r.PingFail)
+		}
+	}
+	wg.Wait()
+	if len(domains) > 0 {
+		r.Domains = probeURLs(ctx, client, domains)
+	}
+	if err := ctx.Err(); err != nil {
+		return TestResult{}, err
+	}
+	if r.HTTP_ERR == 0 && r.PingFail == 0 {
+		r.Status = "ok"
+	} else {
+		r.Status = "fail"
+	}
+	r.LastTestedAt = time.Now()
+	return r, nil
+}
+
+// probeHTTPS reports whether an HTTPS request to host gets any response.
+func probeHTTPS(ctx context.Context, client *http.Client, host string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// probeTCP reports whether a TCP connection to port 443 of host can be opened.
+func probeTCP(ctx context.Context, host string) bool {
+	d := net.Dialer{Timeout: nativeProbeTimeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// probeQUIC reports whether host answers QUIC on UDP port 443. It sends a long-header packet with
+// a reserved version, which a QUIC server must answer with a version negotiation packet, so no
+// handshake is needed.
+func probeQUIC(ctx context.Context, host string) bool {
+	d := net.Dialer{Timeout: nativeProbeTimeout}
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(nativeProbeTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetDeadline(deadline)
+
+	// Servers only answer packets padded to the 1200 bytes of a client Initial.
+	pkt := make([]byte, 1200)
+	pkt[0] = 0xc0
+	binary.BigEndian.PutUint32(pkt[1:5], 0x1a2a3a4a)
+	pkt[5] = 8
+	_, _ = rand.Read(pkt[6:14])
+	pkt[14] = 8
+	_, _ = rand.Read(pkt[15:23])
+	if _, err := conn.Write(pkt); err != nil {
+		return false
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	// A version negotiation packet has the long header bit set and version 0.
+	return err == nil && n >= 5 && buf[0]&0x80 != 0 && binary.BigEndian.Uint32(buf[1:5]) == 0
+}
+
+// withoutDuplicates drops the names of strategies that duplicate another one, for runs with
+// Settings.SkipDuplicateTests set.
+func (s *Service) withoutDuplicates(names []string) []string {
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return names
+	}
+	duplicate := make(map[string]bool)
+	for _, st := range strategies {
+		if st.DuplicateOf != "" {
+			duplicate[strings.ToLower(st.Name)] = true
+		}
+	}
+	kept := names[:0]
+	for _, name := range names {
+		if !duplicate[strings.ToLower(name)] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// notify shows a desktop notification, unless Settings.SilenceNotifications is set or the user is
+// looking at the app anyway.
+func (s *Service) notify(title, text string) {
+	cfg, err := s.configSnapshot()
+	if err != nil || cfg.Settings.SilenceNotifications || appWindowFocused() {
+		return
+	}
+	if err := showToast(title, text); err != nil {
+		s.logUpdate("showing notification: %v", err)
+	}
+}
+
+// notifyTestsDone tells how a test run ended, err being what it returned.
+func (s *Service) notifyTestsDone(err error) {
+	var canceled *TestsCanceledError
+	switch {
+	case errors.As(err, &canceled):
+		s.notify("Tests canceled", canceled.Error())
+		return
+	case err != nil:
+		s.notify("Tests failed", err.Error())
+		return
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return
+	}
+	passed, failed := 0, 0
+	for _, r := range cfg.TestResults {
+		if r.Status == "ok" {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	text := fmt.Sprintf("%d passed, %d failed", passed, failed)
+	if cfg.BestStrategy != "" {
+		text = "Best: " + cfg.BestStrategy + ". " + text
+	}
+	s.notify("Tests finished", text)
+}
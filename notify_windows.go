@@ -0,0 +1,57 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// toastAppID is the AppUserModelID toasts are shown under. Windows drops toasts from apps without
+// a registered one, so they borrow PowerShell's.
+const toastAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows a toast with the title and text passed in the environment, which spares
+// quoting them into the script.
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$texts = $xml.GetElementsByTagName('text')
+$texts.Item(0).AppendChild($xml.CreateTextNode($env:ZAPRET_UI_TOAST_TITLE)) | Out-Null
+$texts.Item(1).AppendChild($xml.CreateTextNode($env:ZAPRET_UI_TOAST_TEXT)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($xml)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:ZAPRET_UI_TOAST_APP).Show($toast)
+`
+
+// showToast shows a Windows toast notification in the background.
+func showToast(title, text string) error {
+	cmd := exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-WindowStyle", "Hidden", "-Command", toastScript)
+	cmd.Env = append(os.Environ(),
+		"ZAPRET_UI_TOAST_TITLE="+title,
+		"ZAPRET_UI_TOAST_TEXT="+text,
+		"ZAPRET_UI_TOAST_APP="+toastAppID,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// appWindowFocused reports whether the foreground window belongs to this process, that is the
+// main window is visible and focused.
+func appWindowFocused() bool {
+	hwnd := windows.GetForegroundWindow()
+	if hwnd == 0 || !windows.IsWindowVisible(hwnd) {
+		return false
+	}
+	var pid uint32
+	if _, err := windows.GetWindowThreadProcessId(hwnd, &pid); err != nil {
+		return false
+	}
+	return pid == windows.GetCurrentProcessId()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// PausedInfo is a strategy stopped for a while with PauseRunning, to be started again by
+// ResumeRunning.
+type PausedInfo struct {
+	File     string    `json:"file"`
+	Release  string    `json:"release,omitempty"`
+	PausedAt time.Time `json:"pausedAt"`
+}
+
+// PauseRunning stops the running strategy and remembers it, so ResumeRunning can start it again.
+func (s *Service) PauseRunning() (*State, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Running == nil || !cfg.Running.alive() {
+		return nil, errors.New("no strategy is running")
+	}
+	paused := &PausedInfo{File: cfg.Running.File, Release: cfg.Running.Release, PausedAt: time.Now()}
+	if _, err := s.stopRunning(false); err != nil {
+		return nil, err
+	}
+	err = s.updateConfig(func(cfg *Config) error {
+		cfg.Paused = paused
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logUpdate("paused %s", paused.File)
+	return s.State()
+}
+
+// ResumeRunning starts the strategy PauseRunning stopped; starting it clears Config.Paused.
+func (s *Service) ResumeRunning() (*State, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Paused == nil {
+		return nil, errors.New("no strategy is paused")
+	}
+	state, err := s.runStrategy(cfg.Paused.File, false)
+	if err != nil {
+		return nil, err
+	}
+	s.logUpdate("resumed %s", cfg.Paused.File)
+	return state, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// eventPowerResumed carries a ResumeEvent when the system wakes up with a strategy running.
+const eventPowerResumed = "power:resumed"
+
+// resumeSettleDelay is how long after a wake the strategy is restarted, giving the network
+// adapters time to come back.
+const resumeSettleDelay = 5 * time.Second
+
+// ResumeEvent describes what was done about the running strategy after the system woke up.
+type ResumeEvent struct {
+	Strategy  string `json:"strategy"`
+	Restarted bool   `json:"restarted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// syncKeepAwake holds a power request while a strategy runs with Settings.KeepAwake and releases
+// it otherwise.
+func (s *Service) syncKeepAwake(cfg *Config) {
+	want := cfg.Settings.KeepAwake && cfg.Running != nil
+	s.awakeMu.Lock()
+	defer s.awakeMu.Unlock()
+	switch {
+	case want && s.awake == nil:
+		req, err := newPowerRequest("zapret-ui: a DPI bypass strategy is running")
+		if err != nil {
+			s.logUpdate("keeping the system awake failed: %v", err)
+			return
+		}
+		s.awake = req
+	case !want && s.awake != nil:
+		_ = s.awake.Close()
+		s.awake = nil
+	}
+}
+
+// watchPowerResume restarts the running strategy after each wake from sleep when
+// Settings.RestartOnResume is set: the WinDivert handle may not survive sleep although winws
+// keeps running.
+func (s *Service) watchPowerResume(ctx context.Context) {
+	unregister, err := registerResumeNotification()
+	if err != nil {
+		s.logUpdate("registering for wake notifications failed: %v", err)
+		return
+	}
+	defer unregister()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resumeCh:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resumeSettleDelay):
+		}
+		s.restartAfterResume()
+	}
+}
+
+// restartAfterResume is the part of watchPowerResume that runs after a wake.
+func (s *Service) restartAfterResume() {
+	cfg, err := s.configSnapshot()
+	if err != nil || !cfg.Settings.RestartOnResume || cfg.Running == nil || cfg.TestInProgress || s.testing.Load() {
+		return
+	}
+	ev := ResumeEvent{Strategy: cfg.Running.File}
+	s.logUpdate("system woke up, restarting %s", ev.Strategy)
+	state, err := s.RestartRunning()
+	if err != nil {
+		ev.Error = err.Error()
+		s.logUpdate("restarting %s after wake failed: %v", ev.Strategy, err)
+	} else {
+		ev.Restarted = true
+	}
+	s.emit(eventPowerResumed, ev)
+	if state == nil {
+		state, _ = s.State()
+	}
+	if state != nil {
+		s.emit(eventState, state)
+	}
+}
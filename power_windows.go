@@ -0,0 +1,103 @@
+//go:build windows
+
+package main
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Power functions x/sys/windows doesn't wrap.
+var (
+	procPowerCreateRequest = kernel32.NewProc("PowerCreateRequest")
+	procPowerSetRequest    = kernel32.NewProc("PowerSetRequest")
+	procPowerClearRequest  = kernel32.NewProc("PowerClearRequest")
+
+	powrprof                                     = windows.NewLazySystemDLL("powrprof.dll")
+	procPowerRegisterSuspendResumeNotification   = powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotification = powrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+)
+
+const (
+	powerRequestContextSimpleString = 0x1
+	powerRequestSystemRequired      = 1
+	deviceNotifyCallback            = 2
+	// pbtAPMResumeAutomatic is sent on every wake, whether or not a user is present.
+	pbtAPMResumeAutomatic = 0x12
+)
+
+// reasonContext is REASON_CONTEXT with a simple reason string; the padding covers the larger
+// detailed variant of its union.
+type reasonContext struct {
+	version uint32
+	flags   uint32
+	reason  *uint16
+	_       [2]uintptr
+}
+
+// deviceNotifySubscribeParameters is DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS.
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+// powerRequest keeps the system from sleeping while it is held.
+type powerRequest struct {
+	h windows.Handle
+}
+
+// newPowerRequest asks Windows to keep the system awake, giving reason in powercfg /requests.
+func newPowerRequest(reason string) (*powerRequest, error) {
+	ctx := reasonContext{flags: powerRequestContextSimpleString, reason: windows.StringToUTF16Ptr(reason)}
+	r, _, err := procPowerCreateRequest.Call(uintptr(unsafe.Pointer(&ctx)))
+	h := windows.Handle(r)
+	if h == windows.InvalidHandle || h == 0 {
+		return nil, err
+	}
+	if ok, _, err := procPowerSetRequest.Call(uintptr(h), powerRequestSystemRequired); ok == 0 {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	return &powerRequest{h: h}, nil
+}
+
+// Close lets the system sleep again.
+func (p *powerRequest) Close() error {
+	_, _, _ = procPowerClearRequest.Call(uintptr(p.h), powerRequestSystemRequired)
+	return windows.CloseHandle(p.h)
+}
+
+var (
+	// resumeCh receives a value when the system wakes up; resumeCallback feeds it.
+	resumeCh           = make(chan struct{}, 1)
+	resumeCallback     uintptr
+	resumeCallbackOnce sync.Once
+	// resumeParams is global so it stays put while registered.
+	resumeParams deviceNotifySubscribeParameters
+)
+
+// registerResumeNotification makes resumeCh receive a value each time the system wakes up, until
+// the returned func is called.
+func registerResumeNotification() (func(), error) {
+	resumeCallbackOnce.Do(func() {
+		// Called on a system thread; the work happens in watchPowerResume.
+		resumeCallback = syscall.NewCallback(func(context, typ, setting uintptr) uintptr {
+			if typ == pbtAPMResumeAutomatic {
+				select {
+				case resumeCh <- struct{}{}:
+				default:
+				}
+			}
+			return 0
+		})
+	})
+	resumeParams.callback = resumeCallback
+	var h uintptr
+	if r, _, _ := procPowerRegisterSuspendResumeNotification.Call(deviceNotifyCallback, uintptr(unsafe.Pointer(&resumeParams)), uintptr(unsafe.Pointer(&h))); r != 0 {
+		return nil, windows.Errno(r)
+	}
+	return func() { _, _, _ = procPowerUnregisterSuspendResumeNotification.Call(h) }, nil
+}
@@ -5,21 +5,30 @@ import (
 	"strings"
 )
 
-// RUN_PROCESS_HIDDEN controls whether external helper processes (PowerShell/cmd/bat) are launched hidden.
-//
-// Default: true (hide).
-// Override for debugging by setting env var RUN_PROCESS_HIDDEN=false (or 0/no/off).
-var RUN_PROCESS_HIDDEN = false
+// processHiddenOverride forces whether external helper processes (PowerShell/cmd/bat) are launched
+// hidden, regardless of Settings.HideProcesses. It is nil unless the RUN_PROCESS_HIDDEN env var is
+// set (true/false, 1/0, yes/no, on/off), which is meant for debugging only.
+var processHiddenOverride *bool
 
 func init() {
 	v := strings.TrimSpace(strings.ToLower(os.Getenv("RUN_PROCESS_HIDDEN")))
-	if v == "" {
-		return
-	}
+	var hidden bool
 	switch v {
 	case "0", "false", "no", "off":
-		RUN_PROCESS_HIDDEN = false
+		hidden = false
 	case "1", "true", "yes", "on":
-		RUN_PROCESS_HIDDEN = true
+		hidden = true
+	default:
+		return
+	}
+	processHiddenOverride = &hidden
+}
+
+// hideProcesses reports whether the next helper process should be launched hidden.
+func (s *Service) hideProcesses() bool {
+	if processHiddenOverride != nil {
+		return *processHiddenOverride
 	}
+	cfg, err := s.configSnapshot()
+	return err == nil && cfg.Settings.HideProcesses
 }
@@ -0,0 +1,356 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Console functions x/sys/windows doesn't wrap.
+var (
+	kernel32                  = windows.NewLazySystemDLL("kernel32.dll")
+	procAttachConsole         = kernel32.NewProc("AttachConsole")
+	procFreeConsole           = kernel32.NewProc("FreeConsole")
+	procSetConsoleCtrlHandler = kernel32.NewProc("SetConsoleCtrlHandler")
+	procGetProcessMemoryInfo  = kernel32.NewProc("K32GetProcessMemoryInfo")
+)
+
+// consoleMu serializes attaching to other processes' consoles: a process has one at most.
+var consoleMu sync.Mutex
+
+// batCommand runs the batch file path in cmd.exe from its folder. The command line is spelled out
+// because cmd.exe doesn't parse the quoting exec uses: a path with &, ^ or parentheses and no
+// spaces would go unquoted and be split. With /s, cmd.exe strips the outer quotes only.
+func batCommand(path string) *exec.Cmd {
+	cmd := exec.Command("cmd.exe")
+	cmd.Dir = filepath.Dir(path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /s /c ""` + path + `""`}
+	return cmd
+}
+
+// processStartTime returns the creation time of the process with the given PID.
+func processStartTime(pid int) (time.Time, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer windows.CloseHandle(h)
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, creation.Nanoseconds()), nil
+}
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// counters is what processCounters reads about a process.
+type counters struct {
+	started    time.Time
+	cpu        time.Duration
+	workingSet uint64
+}
+
+// processCounters reads the start time, CPU time used and working set of the process pid.
+func processCounters(pid int) (counters, error) {
+	var c counters
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return c, err
+	}
+	defer windows.CloseHandle(h)
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return c, err
+	}
+	c.started = time.Unix(0, creation.Nanoseconds())
+	// Kernel and user times are durations in 100 ns units, not dates.
+	ticks := (uint64(kernel.HighDateTime)<<32 | uint64(kernel.LowDateTime)) + (uint64(user.HighDateTime)<<32 | uint64(user.LowDateTime))
+	c.cpu = time.Duration(ticks * 100)
+	mem := processMemoryCounters{cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if r, _, err := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&mem)), uintptr(mem.cb)); r == 0 {
+		return c, err
+	}
+	c.workingSet = uint64(mem.WorkingSetSize)
+	return c, nil
+}
+
+// isPIDRunning checks if a process with given pid is alive.
+func isPIDRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION|windows.SYNCHRONIZE, false, uint32(pid))
+	if err != nil {
+		// Processes of other users or protected ones can't be opened but exist.
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h)
+	ev, err := windows.WaitForSingleObject(h, 0)
+	return err == nil && ev == uint32(windows.WAIT_TIMEOUT)
+}
+
+// waitProcessExit blocks until the process pid exits, or returns ctx's error once ctx is done.
+func waitProcessExit(ctx context.Context, pid int) error {
+	h, err := windows.OpenProcess(windows.SYNCHRONIZE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	for {
+		ev, err := windows.WaitForSingleObject(h, 1000)
+		if err != nil {
+			return err
+		}
+		if ev == windows.WAIT_OBJECT_0 {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// isProcessRunning is isPIDRunning that also checks the process's executable is image, so a PID
+// reused by another program, e.g. after a reboot, doesn't count.
+func isProcessRunning(pid int, image string) bool {
+	if !isPIDRunning(pid) {
+		return false
+	}
+	procs, err := processSnapshot()
+	if err != nil {
+		return false
+	}
+	for _, p := range procs {
+		if int(p.ProcessID) == pid {
+			return strings.EqualFold(windows.UTF16ToString(p.ExeFile[:]), image)
+		}
+	}
+	return false
+}
+
+// processSnapshot lists the processes running on the system.
+func processSnapshot() ([]windows.ProcessEntry32, error) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snap)
+	var procs []windows.ProcessEntry32
+	e := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snap, &e); err == nil; err = windows.Process32Next(snap, &e) {
+		procs = append(procs, e)
+	}
+	return procs, nil
+}
+
+// processesNamed returns the PIDs of the processes whose executable is image, e.g. "winws.exe".
+func processesNamed(image string) []int {
+	procs, _ := processSnapshot()
+	var pids []int
+	for _, p := range procs {
+		if strings.EqualFold(windows.UTF16ToString(p.ExeFile[:]), image) {
+			pids = append(pids, int(p.ProcessID))
+		}
+	}
+	return pids
+}
+
+// processDescendants returns the processes started by root, directly or through its children,
+// parents before their children. Root may have exited already. Processes created before since are
+// skipped: their parent was an earlier process with the same PID.
+func processDescendants(root int, since time.Time) []windows.ProcessEntry32 {
+	procs, _ := processSnapshot()
+	children := make(map[uint32][]windows.ProcessEntry32)
+	for _, p := range procs {
+		children[p.ParentProcessID] = append(children[p.ParentProcessID], p)
+	}
+	var res []windows.ProcessEntry32
+	seen := map[uint32]bool{uint32(root): true}
+	queue := []uint32{uint32(root)}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, c := range children[parent] {
+			if seen[c.ProcessID] {
+				continue
+			}
+			seen[c.ProcessID] = true
+			if started, err := processStartTime(int(c.ProcessID)); err != nil || started.Before(since) {
+				continue
+			}
+			res = append(res, c)
+			queue = append(queue, c.ProcessID)
+		}
+	}
+	return res
+}
+
+// winwsProcessesIn returns the PIDs of winws.exe processes whose executable lies inside dir and
+// that were created at or after since.
+func winwsProcessesIn(dir string, since time.Time) []int {
+	prefix := strings.ToLower(filepath.Clean(dir)) + string(filepath.Separator)
+	var pids []int
+	for _, pid := range processesNamed("winws.exe") {
+		path, err := processImagePath(uint32(pid))
+		if err != nil || !strings.HasPrefix(strings.ToLower(path), prefix) {
+			continue
+		}
+		if started, err := processStartTime(pid); err != nil || started.Before(since) {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// processImagePath returns the full path of the executable of the process pid.
+func processImagePath(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
+// processCommandLine returns the command line the process pid was started with.
+func processCommandLine(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+	// A UNICODE_STRING followed by its text; uint64s keep its pointer aligned.
+	buf := make([]uint64, 512)
+	for {
+		var n uint32
+		err := windows.NtQueryInformationProcess(h, windows.ProcessCommandLineInformation, unsafe.Pointer(&buf[0]), uint32(len(buf)*8), &n)
+		if err == nil {
+			break
+		}
+		if int(n) <= len(buf)*8 {
+			return "", err
+		}
+		buf = make([]uint64, (n+7)/8)
+	}
+	return (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0])).String(), nil
+}
+
+// processParentName returns the executable name of the process that started pid, "" if unknown.
+func processParentName(pid int) string {
+	procs, _ := processSnapshot()
+	parent := uint32(0)
+	for _, p := range procs {
+		if int(p.ProcessID) == pid {
+			parent = p.ParentProcessID
+		}
+	}
+	for _, p := range procs {
+		if parent != 0 && p.ProcessID == parent {
+			return windows.UTF16ToString(p.ExeFile[:])
+		}
+	}
+	return ""
+}
+
+// terminateProcess kills the process pid outright.
+func terminateProcess(pid int) error {
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	return windows.TerminateProcess(h, 1)
+}
+
+// killProcessTree kills pid and the processes it started and returns how many it killed. The
+// deepest descendants go first, so none is left running orphaned.
+func killProcessTree(pid int) int {
+	if pid <= 0 {
+		return 0
+	}
+	started, err := processStartTime(pid)
+	if err != nil {
+		return 0
+	}
+	tree := processDescendants(pid, started)
+	killed := 0
+	for i := len(tree) - 1; i >= 0; i-- {
+		if terminateProcess(int(tree[i].ProcessID)) == nil {
+			killed++
+		}
+	}
+	if terminateProcess(pid) == nil {
+		killed++
+	}
+	return killed
+}
+
+// winwsDescendant returns the PID of a winws.exe started by the process root, directly or through
+// its children, whose executable lies inside dir; 0 if there is none. Processes created before
+// since are skipped, so a parent PID reused since doesn't lead astray.
+func winwsDescendant(root int, dir string, since time.Time) int {
+	prefix := strings.ToLower(filepath.Clean(dir)) + string(filepath.Separator)
+	for _, p := range processDescendants(root, since) {
+		if !strings.EqualFold(windows.UTF16ToString(p.ExeFile[:]), "winws.exe") {
+			continue
+		}
+		if path, err := processImagePath(p.ProcessID); err == nil && strings.HasPrefix(strings.ToLower(path), prefix) {
+			return int(p.ProcessID)
+		}
+	}
+	return 0
+}
+
+// interruptProcess sends Ctrl+C to the console of the process pid, as if it were pressed in its
+// window, and waits up to grace for the process to exit. It reports whether it did.
+func interruptProcess(pid int, grace time.Duration) bool {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	// Fails if the app has a console of its own, e.g. started from a terminal in development.
+	if r, _, _ := procAttachConsole.Call(uintptr(pid)); r == 0 {
+		return false
+	}
+	// The event reaches every process on the console, the app included for now.
+	procSetConsoleCtrlHandler.Call(0, 1)
+	err := windows.GenerateConsoleCtrlEvent(windows.CTRL_C_EVENT, 0)
+	procFreeConsole.Call()
+	if err != nil {
+		procSetConsoleCtrlHandler.Call(0, 0)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	exited := waitProcessExit(ctx, pid) == nil
+	// Handled only after the wait, so the event can't arrive late and end the app.
+	procSetConsoleCtrlHandler.Call(0, 0)
+	return exited
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// parseProxyURL validates a user-supplied proxy URL. An empty string means "use the system/environment proxy".
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// newTransport builds an HTTP transport using proxy, or the environment proxy settings when proxy is nil.
+func newTransport(proxy *url.URL) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	} else {
+		t.Proxy = http.ProxyFromEnvironment
+	}
+	return t
+}
+
+// newHTTPClients returns the API client (15s timeout, redirects not followed) and the download client
+// (no timeout, redirects followed), both routed through proxy.
+func newHTTPClients(proxy *url.URL) (*http.Client, *http.Client) {
+	api := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: newTransport(proxy),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	dl := &http.Client{
+		Timeout:   0,
+		Transport: newTransport(proxy),
+	}
+	return api, dl
+}
+
+// applyProxy rebuilds the service HTTP clients for the given proxy URL.
+func (s *Service) applyProxy(raw string) error {
+	u, err := parseProxyURL(raw)
+	if err != nil {
+		return err
+	}
+	client, dlClient := newHTTPClients(u)
+	s.clientMu.Lock()
+	s.client, s.dlClient = client, dlClient
+	s.clientMu.Unlock()
+	return nil
+}
+
+// apiClient returns the client for GitHub API requests.
+func (s *Service) apiClient() *http.Client {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.client
+}
+
+// downloadClient returns the client for downloads, which has no overall timeout.
+func (s *Service) downloadClient() *http.Client {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.dlClient
+}
+
+// SetProxy validates, applies and persists the proxy URL. An empty string reverts to the system proxy.
+func (s *Service) SetProxy(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if err := s.applyProxy(raw); err != nil {
+		return err
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.ProxyURL = raw
+		return nil
+	})
+	// Cached release info may have been fetched through a different route.
+	s.invalidateLatest()
+	return err
+}
+
+// explainNetErr turns low-level proxy failures into an error that tells the user what to fix.
+func (s *Service) explainNetErr(err error) error {
+	if err == nil {
+		return err
+	}
+	cfg, cerr := s.configSnapshot()
+	if cerr != nil || cfg.ProxyURL == "" {
+		return err
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return fmt.Errorf("cannot connect to proxy %s, check the proxy address or clear it in settings: %w", cfg.ProxyURL, err)
+	}
+	var uErr *url.Error
+	if errors.As(err, &uErr) && uErr.Timeout() {
+		return fmt.Errorf("request through proxy %s timed out, check that the proxy is reachable: %w", cfg.ProxyURL, err)
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// quickCheckTimeout bounds each request of a quick check, so a blocked endpoint doesn't hold it up.
+	quickCheckTimeout = 4 * time.Second
+	// maxQuickCheckTargets bounds Config.QuickCheckTargets.
+	maxQuickCheckTargets = 20
+)
+
+// defaultQuickCheckTargets are representative Discord and YouTube endpoints, used unless
+// Config.QuickCheckTargets is set.
+var defaultQuickCheckTargets = []string{
+	"discord.com",
+	"gateway.discord.gg",
+	"cdn.discordapp.com",
+	"www.youtube.com",
+	"i.ytimg.com",
+	"redirector.googlevideo.com",
+}
+
+// QuickCheckResult is the outcome of QuickCheck.
+type QuickCheckResult struct {
+	At time.Time `json:"at"`
+	// Strategy is the strategy that was running, "" for the unprotected baseline.
+	Strategy  string               `json:"strategy,omitempty"`
+	Endpoints []QuickCheckEndpoint `json:"endpoints"`
+	Passed    int                  `json:"passed"`
+	Failed    int                  `json:"failed"`
+}
+
+// QuickCheckEndpoint is one probed endpoint of a quick check.
+type QuickCheckEndpoint struct {
+	Target string `json:"target"`
+	OK     bool   `json:"ok"`
+	// LatencyMs is the time to the response headers; it is 0 for failed requests.
+	LatencyMs int    `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// QuickCheck requests a few endpoints over HTTPS to tell within seconds whether the running
+// strategy still works. With no strategy running it measures the unprotected baseline. The result
+// is kept in Config.QuickCheck.
+func (s *Service) QuickCheck() (QuickCheckResult, error) {
+	if s.testing.Load() {
+		return QuickCheckResult{}, &TestInProgressError{}
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return QuickCheckResult{}, err
+	}
+	targets := cfg.QuickCheckTargets
+	if len(targets) == 0 {
+		targets = defaultQuickCheckTargets
+	}
+	res := QuickCheckResult{At: time.Now()}
+	if cfg.Running != nil && cfg.Running.alive() {
+		res.Strategy = cfg.Running.File
+	}
+	res.Endpoints = quickCheckProbe(context.Background(), targets)
+	for _, e := range res.Endpoints {
+		if e.OK {
+			res.Passed++
+		} else {
+			res.Failed++
+		}
+	}
+	err = s.updateConfig(func(cfg *Config) error {
+		r := res
+		cfg.QuickCheck = &r
+		return nil
+	})
+	return res, err
+}
+
+// SetQuickCheckTargets replaces the endpoints QuickCheck requests; entries are host names or
+// http(s) URLs, and an empty list restores the defaults.
+func (s *Service) SetQuickCheckTargets(targets []string) (*State, error) {
+	var res []string
+	for _, t := range targets {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		n, err := normalizeTestTarget(t)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(res, n) {
+			res = append(res, n)
+		}
+	}
+	if len(res) > maxQuickCheckTargets {
+		return nil, fmt.Errorf("at most %d quick check targets are allowed", maxQuickCheckTargets)
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.QuickCheckTargets = res
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// quickCheckProbe requests each target, at most probeConcurrency at a time, and returns the
+// outcomes in the order of targets.
+func quickCheckProbe(ctx context.Context, targets []string) []QuickCheckEndpoint {
+	client := newProbeClient()
+	client.Timeout = quickCheckTimeout
+	res := make([]QuickCheckEndpoint, len(targets))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			e := QuickCheckEndpoint{Target: t}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, testTargetURL(t), nil)
+			if err == nil {
+				start := time.Now()
+				var resp *http.Response
+				if resp, err = client.Do(req); err == nil {
+					resp.Body.Close()
+					e.OK, e.LatencyMs = true, int(time.Since(start).Milliseconds())
+				}
+			}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			res[i] = e
+		}(i, t)
+	}
+	wg.Wait()
+	return res
+}
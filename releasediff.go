@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// StrategyDiff describes how a general strategy differs between two releases.
+type StrategyDiff struct {
+	Name string `json:"name"`
+	// Status is "added", "removed" or "changed".
+	Status string `json:"status"`
+	// Diff is a unified diff of the winws.exe argument lines; it is only set for changed strategies.
+	Diff string `json:"diff,omitempty"`
+}
+
+// DiffRelease compares the general strategies of the installed releases tagA and tagB. Tags that
+// aren't installed are reported as *ReleaseNotInstalledError.
+func (s *Service) DiffRelease(tagA, tagB string) ([]StrategyDiff, error) {
+	a, err := s.releaseStrategyArgs(tagA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.releaseStrategyArgs(tagB)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	res := []StrategyDiff{}
+	for _, name := range names {
+		before, inA := a[name]
+		after, inB := b[name]
+		switch {
+		case !inA:
+			res = append(res, StrategyDiff{Name: name, Status: "added"})
+		case !inB:
+			res = append(res, StrategyDiff{Name: name, Status: "removed"})
+		default:
+			diff := unifiedDiff(tagA+"/"+name, tagB+"/"+name, before, after)
+			if diff != "" {
+				res = append(res, StrategyDiff{Name: name, Status: "changed", Diff: diff})
+			}
+		}
+	}
+	return res, nil
+}
+
+// releaseStrategyArgs returns the winws.exe argument lines of each general strategy in the
+// installed release tag, keyed by file name.
+func (s *Service) releaseStrategyArgs(tag string) (map[string][]string, error) {
+	if tag == "" || tag != filepath.Base(tag) {
+		return nil, fmt.Errorf("invalid release tag %q", tag)
+	}
+	dir := filepath.Join(s.dirs().releasesDir, tag)
+	if err := validateRelease(dir); err != nil {
+		return nil, &ReleaseNotInstalledError{Tag: tag}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	custom := s.customFiles()
+	res := make(map[string][]string)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".bat") || strategyCategory(name) != categoryGeneral {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if isStagedCopy(name, path, custom) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text, _ := decodeBatText(data)
+		res[name] = winwsLines(text)
+	}
+	return res, nil
+}
+
+// winwsLines returns the lines of the winws.exe command in a .bat script, following ^
+// continuations, trimmed of indentation and the trailing ^.
+func winwsLines(content string) []string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var res []string
+	for i, line := range lines {
+		if !strings.Contains(strings.ToLower(line), "winws.exe") {
+			continue
+		}
+		for ; i < len(lines); i++ {
+			l := strings.TrimSpace(lines[i])
+			cont := strings.HasSuffix(l, "^")
+			res = append(res, strings.TrimSpace(strings.TrimSuffix(l, "^")))
+			if !cont {
+				break
+			}
+		}
+		break
+	}
+	return res
+}
+
+// unifiedDiff renders the difference between a and b in unified diff format, or "" when they are
+// equal.
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		text string
+		ai   int // line index in a of this or the next a line
+		bi   int
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		// Grow the hunk until diffContext*2 unchanged lines separate it from the next change.
+		start := max(k-diffContext, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			n := end
+			for n < len(ops) && ops[n].kind == ' ' {
+				n++
+			}
+			if n == len(ops) || n-end > diffContext*2 {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = n
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		var countA, countB int
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[start].ai, countA), hunkRange(ops[start].bi, countB))
+		for _, o := range ops[start:end] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			out.WriteByte('\n')
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the start,count part of a hunk header; start is zero-based.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
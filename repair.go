@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RepairSummary lists the files RepairCurrentRelease touched, relative to the release directory.
+type RepairSummary struct {
+	Tag      string   `json:"tag"`
+	Replaced []string `json:"replaced"`
+	Restored []string `json:"restored"`
+}
+
+// RepairCurrentRelease re-downloads the active release and replaces its directory, e.g. after an
+// antivirus quarantined winws.exe. User-modified files under lists/ are carried over.
+func (s *Service) RepairCurrentRelease() (*State, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	tag := cfg.Version
+	if tag == "" {
+		return nil, errors.New("no current release")
+	}
+	ctx, done, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// winws.exe and the driver are locked while a strategy runs.
+	if cfg.Running != nil {
+		if err := s.StopRunning(); err != nil {
+			return nil, err
+		}
+	}
+
+	partial, size, err := s.downloadArchive(ctx, tag)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, &UpdateCanceledError{Tag: tag, Err: err}
+		}
+		return nil, err
+	}
+	defer os.Remove(partial)
+
+	targetDir := filepath.Join(s.dirs().releasesDir, tag)
+	backup := targetDir + backupSuffix
+	_ = os.RemoveAll(backup)
+	if _, err := os.Stat(targetDir); err == nil {
+		if err := os.Rename(targetDir, backup); err != nil {
+			return nil, err
+		}
+	}
+
+	s.emitProgress(tag, "unpacking", size, size)
+	if err := extractRelease(ctx, partial, targetDir); err != nil {
+		// Put the previous directory back so the user isn't left without a release.
+		if _, statErr := os.Stat(backup); statErr == nil {
+			_ = os.Rename(backup, targetDir)
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, &UpdateCanceledError{Tag: tag, Err: err}
+		}
+		return nil, err
+	}
+
+	summary := &RepairSummary{Tag: tag, Replaced: []string{}, Restored: []string{}}
+	if _, err := os.Stat(backup); err == nil {
+		summary.Replaced = changedFiles(backup, targetDir, "lists")
+		restored, err := restoreUserLists(backup, targetDir)
+		if err != nil {
+			return nil, err
+		}
+		summary.Restored = restored
+		_ = os.RemoveAll(backup)
+	}
+	s.logUpdate("repaired %s: %d files replaced, %d list files restored", tag, len(summary.Replaced), len(summary.Restored))
+	s.emitProgress(tag, "done", size, size)
+
+	state, err := s.State()
+	if err != nil {
+		return nil, err
+	}
+	state.Repair = summary
+	return state, nil
+}
+
+// changedFiles returns files in newDir that are missing from or differ in oldDir, skipping the skipDir subtree.
+func changedFiles(oldDir, newDir, skipDir string) []string {
+	var res []string
+	_ = filepath.WalkDir(newDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(newDir, path)
+		if d.IsDir() {
+			if strings.EqualFold(rel, skipDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sameFileContent(filepath.Join(oldDir, rel), path) {
+			res = append(res, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(res)
+	return res
+}
+
+// restoreUserLists copies files from oldDir/lists into newDir/lists when they differ from the
+// freshly extracted ones, or don't exist there at all.
+func restoreUserLists(oldDir, newDir string) ([]string, error) {
+	oldLists := filepath.Join(oldDir, "lists")
+	entries, err := os.ReadDir(oldLists)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var restored []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		src := filepath.Join(oldLists, e.Name())
+		dst := filepath.Join(newDir, "lists", e.Name())
+		if sameFileContent(src, dst) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return restored, err
+		}
+		if err := copyFile(src, dst, info.Mode()); err != nil {
+			return restored, err
+		}
+		restored = append(restored, "lists/"+e.Name())
+	}
+	return restored, nil
+}
+
+// ListMigration describes user entries carried from the previous release's lists/ into a new one.
+type ListMigration struct {
+	File       string   `json:"file"`
+	AddedLines []string `json:"addedLines,omitempty"`
+	// Copied is set when the file didn't exist in the new release and was copied as a whole.
+	Copied bool `json:"copied,omitempty"`
+}
+
+// mergeUserLists carries user additions from oldDir/lists into newDir/lists. Lines present in the
+// old file but missing from the new one are appended; files the new release lacks are copied.
+// The old release's pristine defaults aren't kept, so an entry upstream dropped is also carried over.
+func mergeUserLists(oldDir, newDir string) ([]ListMigration, error) {
+	oldLists := filepath.Join(oldDir, "lists")
+	entries, err := os.ReadDir(oldLists)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var res []ListMigration
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		src := filepath.Join(oldLists, e.Name())
+		dst := filepath.Join(newDir, "lists", e.Name())
+		if sameFileContent(src, dst) {
+			continue
+		}
+		if _, err := os.Stat(dst); os.IsNotExist(err) {
+			info, err := e.Info()
+			if err != nil {
+				return res, err
+			}
+			if err := copyFile(src, dst, info.Mode()); err != nil {
+				return res, err
+			}
+			res = append(res, ListMigration{File: e.Name(), Copied: true})
+			continue
+		}
+		added, err := appendMissingLines(src, dst)
+		if err != nil {
+			return res, err
+		}
+		if len(added) > 0 {
+			res = append(res, ListMigration{File: e.Name(), AddedLines: added})
+		}
+	}
+	return res, nil
+}
+
+// appendMissingLines appends the non-empty lines of src that dst doesn't contain and returns them.
+func appendMissingLines(src, dst string) ([]string, error) {
+	srcData, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	dstData, err := os.ReadFile(dst)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool)
+	for _, l := range strings.Split(string(dstData), "\n") {
+		have[strings.TrimSpace(l)] = true
+	}
+	var added []string
+	for _, l := range strings.Split(string(srcData), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || have[l] {
+			continue
+		}
+		have[l] = true
+		added = append(added, l)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	eol := "\n"
+	if bytes.Contains(dstData, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+	var text strings.Builder
+	if len(dstData) > 0 && !bytes.HasSuffix(dstData, []byte("\n")) {
+		text.WriteString(eol)
+	}
+	for _, l := range added {
+		text.WriteString(l + eol)
+	}
+	return added, appendFile(dst, text.String())
+}
+
+// sameFileContent reports whether both files exist and have identical contents.
+func sameFileContent(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil || ai.Size() != bi.Size() {
+		return false
+	}
+	ad, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	bd, err := os.ReadFile(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ad, bd)
+}
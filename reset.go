@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResetApp stops the running strategy and forgets test results, the best strategy and notes.
+// Unless keepReleases is set, downloaded releases and logs are deleted and the bundled release is
+// unpacked again. Settings such as the proxy and mirrors are kept. The refreshed state is also
+// emitted as "state:changed".
+func (s *Service) ResetApp(keepReleases bool) (*State, error) {
+	if !s.testing.CompareAndSwap(false, true) {
+		return nil, &TestInProgressError{}
+	}
+	defer s.testing.Store(false)
+	_, done, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := s.StopRunning(); err != nil {
+		return nil, err
+	}
+
+	s.historyMu.Lock()
+	err = os.Remove(s.dirs().historyPath)
+	s.historyMu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// State rehydrates results from the test results folder, so it has to go as well.
+	releases, _ := s.ListInstalledReleases()
+	for _, r := range releases {
+		_ = os.RemoveAll(filepath.Join(r.Path, "utils", "test results"))
+	}
+	if !keepReleases {
+		for _, dir := range []string{s.dirs().releasesDir, s.dirs().logsDir} {
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.ensureDirs(); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.updateConfig(func(cfg *Config) error {
+		cfg.TestResults = make(map[string]TestResult)
+		cfg.BestStrategy = ""
+		cfg.LastStrategy = ""
+		cfg.LastTestAt = time.Time{}
+		cfg.TestInProgress = false
+		cfg.TestJob = nil
+		cfg.LastRunChanges = nil
+		cfg.Running = nil
+		cfg.Meta = make(map[string]interface{})
+		if !keepReleases {
+			cfg.Version = ""
+			cfg.PendingVersion = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !keepReleases {
+		tag, err := s.seedEmbeddedRelease()
+		if err != nil {
+			s.logUpdate("re-seeding after reset failed: %v", err)
+		} else {
+			_ = s.updateConfig(func(cfg *Config) error {
+				cfg.Version = tag
+				return nil
+			})
+		}
+	}
+
+	state, err := s.State()
+	if err != nil {
+		return nil, err
+	}
+	s.emit(eventState, state)
+	return state, nil
+}
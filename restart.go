@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RestartRunning stops the running strategy, waits for its winws.exe to exit and starts the same
+// file again, so edits of its lists or files take effect. It counts as a restart of the run, not
+// a new launch.
+func (s *Service) RestartRunning() (*State, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Running == nil || !cfg.Running.alive() {
+		return nil, errors.New("no strategy is running")
+	}
+	prev := *cfg.Running
+	if _, err := s.stopRunning(false); err != nil {
+		return nil, err
+	}
+	if prev.WinwsPID > 0 {
+		// The new winws can't open the same WinDivert filter until the old one is gone.
+		ctx, cancel := context.WithTimeout(context.Background(), winwsStopWait)
+		err := waitProcessExit(ctx, prev.WinwsPID)
+		cancel()
+		if err != nil && isProcessRunning(prev.WinwsPID, "winws.exe") {
+			return nil, fmt.Errorf("winws.exe (PID %d) of %s didn't exit", prev.WinwsPID, prev.File)
+		}
+	}
+	// It ran before, so conflicts found now don't stop it from coming back.
+	state, err := s.launchStrategy(prev.File, true, &prev)
+	if err != nil {
+		return nil, err
+	}
+	s.logUpdate("restarted %s", prev.File)
+	return state, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Formats of ExportResults and CopyResultsToClipboard.
+const (
+	resultsFormatCSV      = "csv"
+	resultsFormatJSON     = "json"
+	resultsFormatMarkdown = "markdown-table"
+)
+
+// ExportedResult is one strategy's row in exported results.
+type ExportedResult struct {
+	Name      string    `json:"name"`
+	HTTPOK    int       `json:"httpOk"`
+	HTTPErr   int       `json:"httpErr"`
+	HTTPUnsup int       `json:"httpUnsup"`
+	PingOK    int       `json:"pingOk"`
+	PingFail  int       `json:"pingFail"`
+	Fail      int       `json:"fail"`
+	Blocked   int       `json:"blocked"`
+	Status    string    `json:"status"`
+	Score     int       `json:"score"`
+	Version   string    `json:"version"`
+	Mode      string    `json:"mode,omitempty"`
+	TestedAt  time.Time `json:"testedAt"`
+	Best      bool      `json:"best"`
+}
+
+// ExportResults writes the results of the last completed test run to path as "csv", "json" or
+// "markdown-table", best score first.
+func (s *Service) ExportResults(format, path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("export path must be absolute: %q", path)
+	}
+	data, err := s.formatResults(format)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
+
+// CopyResultsToClipboard puts the results of the last completed test run on the clipboard in the
+// format ExportResults takes.
+func (s *Service) CopyResultsToClipboard(format string) error {
+	data, err := s.formatResults(format)
+	if err != nil {
+		return err
+	}
+	if s.ctx == nil {
+		return errors.New("clipboard is not available")
+	}
+	return runtime.ClipboardSetText(s.ctx, string(data))
+}
+
+// formatResults renders the last completed results in format.
+func (s *Service) formatResults(format string) ([]byte, error) {
+	rows, err := s.exportedResults()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case resultsFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"name", "status", "score", "http_ok", "http_err", "http_unsup", "ping_ok", "ping_fail", "fail", "blocked", "version", "mode", "tested_at", "best"})
+		for _, r := range rows {
+			_ = w.Write([]string{
+				r.Name, r.Status, strconv.Itoa(r.Score),
+				strconv.Itoa(r.HTTPOK), strconv.Itoa(r.HTTPErr), strconv.Itoa(r.HTTPUnsup),
+				strconv.Itoa(r.PingOK), strconv.Itoa(r.PingFail), strconv.Itoa(r.Fail), strconv.Itoa(r.Blocked),
+				r.Version, r.Mode, r.TestedAt.Format(time.RFC3339), strconv.FormatBool(r.Best),
+			})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	case resultsFormatJSON:
+		return json.MarshalIndent(rows, "", "  ")
+	case resultsFormatMarkdown:
+		var b strings.Builder
+		b.WriteString("| Strategy | Status | Score | HTTP OK | HTTP ERR | Ping OK | Ping FAIL | Fail | Blocked | Version | Tested |\n")
+		b.WriteString("|---|---|--:|--:|--:|--:|--:|--:|--:|---|---|\n")
+		for _, r := range rows {
+			name := strings.ReplaceAll(r.Name, "|", `\|`)
+			if r.Best {
+				name = "**" + name + "**"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %d | %d | %d | %s | %s |\n",
+				name, r.Status, r.Score, r.HTTPOK, r.HTTPErr, r.PingOK, r.PingFail, r.Fail, r.Blocked,
+				r.Version, r.TestedAt.Format("2006-01-02 15:04"))
+		}
+		return []byte(b.String()), nil
+	}
+	return nil, fmt.Errorf("unknown results format %q", format)
+}
+
+// exportedResults returns the rows of the last completed test run, best score first. While a run
+// is in progress the stored results may be cleared or half-updated, so they are rebuilt from the
+// test history instead.
+func (s *Service) exportedResults() ([]ExportedResult, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	results, best := cfg.TestResults, cfg.BestStrategy
+	if cfg.TestInProgress || s.testing.Load() {
+		runs, err := s.GetTestHistory()
+		if err != nil {
+			return nil, err
+		}
+		results, best = completedResults(runs)
+	}
+	if len(results) == 0 {
+		return nil, errors.New("no test results to export")
+	}
+	weight := cfg.Settings.domainWeight()
+	rows := make([]ExportedResult, 0, len(results))
+	for name, r := range results {
+		rows = append(rows, ExportedResult{
+			Name: name, HTTPOK: r.HTTP_OK, HTTPErr: r.HTTP_ERR, HTTPUnsup: r.HTTP_UNSUP,
+			PingOK: r.PingOK, PingFail: r.PingFail, Fail: r.Fail, Blocked: r.Blocked,
+			Status: r.Status, Score: scoreResult(r, weight), Version: r.Version, Mode: r.Mode,
+			TestedAt: r.LastTestedAt, Best: name == best,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows, nil
+}
+
+// completedResults replays runs, oldest first, the way finishTests stores them: a full run
+// replaces the results and a partial one updates its entries.
+func completedResults(runs []TestRun) (map[string]TestResult, string) {
+	results := make(map[string]TestResult)
+	best := ""
+	for _, run := range runs {
+		if !run.Partial {
+			results = make(map[string]TestResult, len(run.Results))
+		}
+		for name, r := range run.Results {
+			results[name] = r
+		}
+		best = run.Best
+	}
+	return results, best
+}
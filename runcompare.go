@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RunComparison is how the results of one test run differ from an earlier one.
+type RunComparison struct {
+	From   string    `json:"from"`
+	FromAt time.Time `json:"fromAt"`
+	To     string    `json:"to"`
+	ToAt   time.Time `json:"toAt"`
+	// Strategies are the strategies tested in To, by name.
+	Strategies []StrategyDelta `json:"strategies"`
+	// Regressed passed in From and fail in To; Improved the other way round.
+	Regressed  []string `json:"regressed"`
+	Improved   []string `json:"improved"`
+	BestBefore string   `json:"bestBefore"`
+	BestAfter  string   `json:"bestAfter"`
+}
+
+// StrategyDelta is how a strategy's result changed between two runs. StatusBefore is "" for a
+// strategy From didn't test.
+type StrategyDelta struct {
+	Name         string `json:"name"`
+	StatusBefore string `json:"statusBefore"`
+	StatusAfter  string `json:"statusAfter"`
+	ScoreBefore  int    `json:"scoreBefore"`
+	ScoreAfter   int    `json:"scoreAfter"`
+	ScoreDelta   int    `json:"scoreDelta"`
+}
+
+// RunChanges summarises the comparison of the latest test run with the one before it.
+type RunChanges struct {
+	Regressed   int    `json:"regressed"`
+	Improved    int    `json:"improved"`
+	BestChanged bool   `json:"bestChanged"`
+	BestBefore  string `json:"bestBefore,omitempty"`
+	BestAfter   string `json:"bestAfter,omitempty"`
+}
+
+// CompareRuns compares the test runs with IDs a and b from the history, a being the earlier one.
+// An empty b means the latest run and an empty a the run before b.
+func (s *Service) CompareRuns(a, b string) (RunComparison, error) {
+	runs, err := s.GetTestHistory()
+	if err != nil {
+		return RunComparison{}, err
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return RunComparison{}, err
+	}
+	find := func(id string) int {
+		for i, run := range runs {
+			if run.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	to := len(runs) - 1
+	if b != "" {
+		if to = find(b); to < 0 {
+			return RunComparison{}, fmt.Errorf("no test run %s in the history", b)
+		}
+	}
+	from := to - 1
+	if a != "" {
+		if from = find(a); from < 0 {
+			return RunComparison{}, fmt.Errorf("no test run %s in the history", a)
+		}
+	}
+	if to < 0 || from < 0 {
+		return RunComparison{}, errors.New("the test history has fewer than two runs")
+	}
+	if from >= to {
+		return RunComparison{}, fmt.Errorf("test run %s is not earlier than %s", runs[from].ID, runs[to].ID)
+	}
+	return compareRuns(runs[from], runs[to], cfg.Settings.domainWeight()), nil
+}
+
+// compareRuns reports how the strategies tested in to did compared with from.
+func compareRuns(from, to TestRun, domainWeight int) RunComparison {
+	c := RunComparison{
+		From: from.ID, FromAt: from.At, To: to.ID, ToAt: to.At,
+		Strategies: []StrategyDelta{}, Regressed: []string{}, Improved: []string{},
+		BestBefore: from.Best, BestAfter: to.Best,
+	}
+	for name, after := range to.Results {
+		d := StrategyDelta{Name: name, StatusAfter: after.Status, ScoreAfter: scoreResult(after, domainWeight)}
+		if before, ok := from.Results[name]; ok {
+			d.StatusBefore, d.ScoreBefore = before.Status, scoreResult(before, domainWeight)
+			d.ScoreDelta = d.ScoreAfter - d.ScoreBefore
+			switch {
+			case before.Status == "ok" && after.Status != "ok":
+				c.Regressed = append(c.Regressed, name)
+			case before.Status != "ok" && after.Status == "ok":
+				c.Improved = append(c.Improved, name)
+			}
+		}
+		c.Strategies = append(c.Strategies, d)
+	}
+	sort.Slice(c.Strategies, func(i, j int) bool { return c.Strategies[i].Name < c.Strategies[j].Name })
+	sort.Strings(c.Regressed)
+	sort.Strings(c.Improved)
+	return c
+}
+
+// recordRunChanges stores in Config.LastRunChanges how the run just recorded compares with the one
+// before it; it is cleared when there is no earlier run.
+func (s *Service) recordRunChanges() {
+	var changes *RunChanges
+	if c, err := s.CompareRuns("", ""); err == nil {
+		changes = &RunChanges{
+			Regressed:   len(c.Regressed),
+			Improved:    len(c.Improved),
+			BestChanged: c.BestBefore != c.BestAfter,
+			BestBefore:  c.BestBefore,
+			BestAfter:   c.BestAfter,
+		}
+	}
+	_ = s.updateConfig(func(cfg *Config) error {
+		cfg.LastRunChanges = changes
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// strategyLogPrefix starts the names of the output logs of strategy runs.
+	strategyLogPrefix = "strategy_"
+	// testLogPrefix starts the names of the output logs of test runs.
+	testLogPrefix = "test_"
+	// maxLogsKept is how many logs of each kind rotateLogs leaves in the logs folder.
+	maxLogsKept = 20
+)
+
+// startMinRe matches the "start [title] [/min]" command that runs winws.exe in a strategy script.
+var startMinRe = regexp.MustCompile(`(?i)^(\s*@?)start(\s+"[^"]*")?(\s+/min)?(\s+/b)?\s`)
+
+// stageRun writes the strategy script src into the release at current as the .cmd file that is
+// actually run, with winws started by "start /b": a plain "start" gives winws a console of its own,
+// and its output would never reach the log the launcher's output goes to. %~dp0 still resolves to
+// the release and %~n0 to the strategy name.
+func stageRun(src, current string) (string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, l := range lines {
+		if strings.Contains(strings.ToLower(l), "winws.exe") {
+			lines[i] = startMinRe.ReplaceAllString(l, "${1}start$2 /b ")
+		}
+	}
+	name := filepath.Base(src)
+	dst := filepath.Join(current, strings.TrimSuffix(name, filepath.Ext(name))+".cmd")
+	if err := os.WriteFile(dst, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// createStrategyLog creates the log the output of a run of strategy file goes to, after rotating
+// the older ones out.
+func (s *Service) createStrategyLog(file string) (string, *os.File, error) {
+	rotateLogs(s.dirs().logsDir, strategyLogPrefix, maxLogsKept-1)
+	name := strings.TrimSuffix(file, filepath.Ext(file))
+	path := filepath.Join(s.dirs().logsDir, fmt.Sprintf("%s%s_%s.log", strategyLogPrefix, name, time.Now().Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, f, nil
+}
+
+// rotateLogs removes all but the keep newest logs in dir whose names start with prefix. Logs still
+// being written can't be removed and are left for the next rotation.
+func rotateLogs(dir, prefix string, keep int) {
+	matches, _ := filepath.Glob(filepath.Join(dir, prefix+"*.log"))
+	if len(matches) <= keep {
+		return
+	}
+	modTimes := make(map[string]time.Time, len(matches))
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil {
+			modTimes[m] = info.ModTime()
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return modTimes[matches[i]].After(modTimes[matches[j]]) })
+	for _, m := range matches[keep:] {
+		_ = os.Remove(m)
+	}
+}
+
+// GetRunningLogTail returns the last lines of the output of the running strategy or, when none
+// runs, of the most recent strategy run, so a strategy that exited at once can be looked into.
+func (s *Service) GetRunningLogTail(lines int) (string, error) {
+	if lines <= 0 {
+		lines = defaultTestLogLines
+	}
+	lines = min(lines, maxTestLogLines)
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return "", err
+	}
+	path := ""
+	if cfg.Running != nil {
+		path = cfg.Running.Log
+	} else {
+		path = latestLog(s.dirs().logsDir, strategyLogPrefix)
+	}
+	if path == "" {
+		return "", nil
+	}
+	return readLogTail(path, lines)
+}
+
+// latestLog returns the most recently written log in dir whose name starts with prefix.
+func latestLog(dir, prefix string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, prefix+"*.log"))
+	latest, latestTime := "", time.Time{}
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().After(latestTime) {
+			latest, latestTime = m, info.ModTime()
+		}
+	}
+	return latest
+}
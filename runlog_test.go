@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStageRunKeepsWinwsOnLauncherConsole(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "general (ALT).bat")
+	script := strings.Join([]string{
+		`@echo off`,
+		`set "BIN=%~dp0bin\"`,
+		`start "zapret: %~n0" /min "%BIN%winws.exe" --wf-tcp=80,443 --dpi-desync-start=1`,
+		`  START /MIN "%BIN%winws.exe" --wf-udp=443`,
+		`start "" /b "%BIN%winws.exe" --wf-tcp=443`,
+		`start https://example.com`,
+	}, "\r\n")
+	if err := os.WriteFile(src, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := stageRun(src, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "general (ALT).cmd"); staged != want {
+		t.Fatalf("staged to %s, want %s", staged, want)
+	}
+	data, err := os.ReadFile(staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(string(data), "\r\n")
+	want := []string{
+		`@echo off`,
+		`set "BIN=%~dp0bin\"`,
+		`start "zapret: %~n0" /b "%BIN%winws.exe" --wf-tcp=80,443 --dpi-desync-start=1`,
+		`  start /b "%BIN%winws.exe" --wf-udp=443`,
+		`start "" /b "%BIN%winws.exe" --wf-tcp=443`,
+		`start https://example.com`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(got), len(want), data)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
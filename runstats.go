@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runHeartbeatInterval is how often the running strategy's SeenAt marker is refreshed.
+const runHeartbeatInterval = time.Minute
+
+// RunStats is how much a strategy has been used; it is keyed by strategy file name in Config.
+type RunStats struct {
+	Launches       int       `json:"launches"`
+	LastLaunchedAt time.Time `json:"lastLaunchedAt"`
+	// RuntimeSeconds is the total time the strategy ran, counted when each run ends.
+	RuntimeSeconds int64 `json:"runtimeSeconds"`
+	// Crashes counts the times its winws exited on its own; see awaitCrash. Restarts counts the
+	// times it was started again after a crash or by RestartRunning, which aren't Launches.
+	Crashes  int `json:"crashes,omitempty"`
+	Restarts int `json:"restarts,omitempty"`
+}
+
+// recordLaunch counts a launch of the strategy name at t.
+func recordLaunch(cfg *Config, name string, t time.Time) {
+	if cfg.RunStats == nil {
+		cfg.RunStats = make(map[string]RunStats)
+	}
+	st := cfg.RunStats[name]
+	st.Launches++
+	st.LastLaunchedAt = t
+	cfg.RunStats[name] = st
+}
+
+// recordCrash counts an unexpected exit of the strategy name.
+func recordCrash(cfg *Config, name string) {
+	if cfg.RunStats == nil {
+		cfg.RunStats = make(map[string]RunStats)
+	}
+	st := cfg.RunStats[name]
+	st.Crashes++
+	cfg.RunStats[name] = st
+}
+
+// recordRestart counts a restart of the strategy name after a crash or by RestartRunning.
+func recordRestart(cfg *Config, name string) {
+	if cfg.RunStats == nil {
+		cfg.RunStats = make(map[string]RunStats)
+	}
+	st := cfg.RunStats[name]
+	st.Restarts++
+	cfg.RunStats[name] = st
+}
+
+// recordRunEnd adds the time between run's start and end to its strategy's runtime.
+func recordRunEnd(cfg *Config, run *RunningInfo, end time.Time) {
+	if run == nil || run.File == "" || !end.After(run.StartedAt) {
+		return
+	}
+	if cfg.RunStats == nil {
+		cfg.RunStats = make(map[string]RunStats)
+	}
+	st := cfg.RunStats[run.File]
+	st.RuntimeSeconds += int64(end.Sub(run.StartedAt) / time.Second)
+	cfg.RunStats[run.File] = st
+}
+
+// runHeartbeat refreshes Running.SeenAt while a strategy runs, so a run outliving a killed app is
+// still counted up to the last time it was seen.
+func (s *Service) runHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(runHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cfg, err := s.configSnapshot()
+		if err != nil || cfg.Running == nil {
+			continue
+		}
+		_, _ = s.State()
+	}
+}
@@ -9,6 +9,23 @@ if (!(Test-Path $manifestRelease)) {
   throw "Release manifest not found: $manifestRelease"
 }
 
+# The release embedded from seed/ is what the app runs on first launch without network access.
+$seedDir = Join-Path $root "seed"
+$seedPattern = "zapret-discord-youtube-*.zip"
+if (!(Get-ChildItem $seedDir -Filter $seedPattern -ErrorAction SilentlyContinue)) {
+  $tag = $env:ZAPRET_SEED_TAG
+  if (!$tag) {
+    Write-Host "[INFO] Looking up the latest zapret-discord-youtube release"
+    $tag = (Invoke-RestMethod "https://api.github.com/repos/Flowseal/zapret-discord-youtube/releases/latest").tag_name
+  }
+  $seedName = "zapret-discord-youtube-$tag.zip"
+  Write-Host "[INFO] Downloading seed release $seedName"
+  Invoke-WebRequest "https://github.com/Flowseal/zapret-discord-youtube/releases/download/$tag/$seedName" -OutFile (Join-Path $seedDir $seedName) -UseBasicParsing
+}
+if (!(Get-ChildItem $seedDir -Filter $seedPattern -ErrorAction SilentlyContinue)) {
+  throw "Seed release not found in $seedDir"
+}
+
 Copy-Item $manifestPath $manifestBackup -Force
 Copy-Item $manifestRelease $manifestPath -Force
 
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// seedFS holds the release bundled into the executable as seed/zapret-discord-youtube-<tag>.zip.
+//
+//go:embed all:seed
+var seedFS embed.FS
+
+const seedPrefix = "zapret-discord-youtube-"
+
+// seedEmbeddedRelease unpacks the newest embedded release into releasesDir and returns its tag.
+func (s *Service) seedEmbeddedRelease() (string, error) {
+	entries, err := fs.ReadDir(seedFS, "seed")
+	if err != nil {
+		return "", err
+	}
+	var best, bestFile string
+	var bestVer releaseVersion
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, seedPrefix) || !strings.HasSuffix(strings.ToLower(name), ".zip") {
+			continue
+		}
+		tag := strings.TrimSuffix(strings.TrimPrefix(name, seedPrefix), filepath.Ext(name))
+		v, ok := parseVersion(tag)
+		if !ok {
+			continue
+		}
+		if best == "" || compareVersions(v, bestVer) > 0 {
+			best, bestFile, bestVer = tag, name, v
+		}
+	}
+	if best == "" {
+		return "", errors.New("no bundled releases")
+	}
+
+	dst := filepath.Join(s.dirs().releasesDir, best)
+	if validateRelease(dst) != nil {
+		data, err := seedFS.ReadFile("seed/" + bestFile)
+		if err != nil {
+			return "", err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", err
+		}
+		_ = os.RemoveAll(dst)
+		if err := extractReleaseZip(context.Background(), zr, dst); err != nil {
+			return "", err
+		}
+	}
+	// Nothing is downloaded for a bundled release, so report completion straight away.
+	s.emitProgress(best, "done", 0, 0)
+	return best, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// appVersion is the version of zapret-ui itself; release builds override it with
+// -ldflags "-X main.appVersion=<tag>".
+var appVersion = "0.1.0"
+
+const (
+	// appReleaseURL is the GitHub API endpoint for the latest zapret-ui release.
+	appReleaseURL = "https://api.github.com/repos/shtanko-michael/zapret-ui/releases/latest"
+	// appCheckTTL limits how often the app's own release is looked up.
+	appCheckTTL = 6 * time.Hour
+)
+
+// appUpdateInfo returns the cached latest zapret-ui tag and whether it is newer than the running binary.
+// A stale value is refreshed in the background.
+func (s *Service) appUpdateInfo() (string, bool) {
+	s.latestMu.Lock()
+	rel, at := s.appLatest, s.appLatestAt
+	s.latestMu.Unlock()
+	if time.Since(at) >= appCheckTTL && s.appRefreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer s.appRefreshing.Store(false)
+			_, _ = s.fetchAppRelease()
+		}()
+	}
+	if rel == nil {
+		return "", false
+	}
+	return rel.TagName, isNewerVersion(rel.TagName, appVersion)
+}
+
+// isNewerVersion compares two tags as versions; unparseable tags never count as newer.
+func isNewerVersion(latest, current string) bool {
+	lv, lok := parseVersion(latest)
+	cv, cok := parseVersion(current)
+	return lok && cok && compareVersions(lv, cv) > 0
+}
+
+func (s *Service) fetchAppRelease() (*githubRelease, error) {
+	rel, err := s.fetchRelease(appReleaseURL)
+	s.latestMu.Lock()
+	defer s.latestMu.Unlock()
+	// Record the attempt even on failure so an offline machine isn't asked again on every State call.
+	s.appLatestAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	s.appLatest = rel
+	return rel, nil
+}
+
+// UpdateSelf downloads the newest zapret-ui executable, verifies its SHA-256 against the checksum
+// published with the release, and starts a helper that swaps the binary once this process exits.
+// The caller is expected to quit the app after a nil return.
+func (s *Service) UpdateSelf() error {
+	rel, err := s.fetchAppRelease()
+	if err != nil {
+		return err
+	}
+	if !isNewerVersion(rel.TagName, appVersion) {
+		return fmt.Errorf("zapret-ui %s is up to date", appVersion)
+	}
+	var exeAsset *githubAsset
+	for i, a := range rel.Assets {
+		if strings.HasSuffix(strings.ToLower(a.Name), ".exe") {
+			exeAsset = &rel.Assets[i]
+			break
+		}
+	}
+	if exeAsset == nil {
+		return fmt.Errorf("release %s has no executable", rel.TagName)
+	}
+	want, err := s.publishedChecksum(rel, exeAsset.Name)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	newExe := exe + ".new"
+	if err := s.downloadVerified(exeAsset.BrowserDownloadURL, newExe, want); err != nil {
+		_ = os.Remove(newExe)
+		return err
+	}
+
+	script := filepath.Join(os.TempDir(), fmt.Sprintf("zapret-ui-update-%d.bat", time.Now().Unix()))
+	if err := os.WriteFile(script, []byte(swapScript(exe, newExe, os.Getpid())), 0o644); err != nil {
+		_ = os.Remove(newExe)
+		return err
+	}
+	cmd := exec.Command("cmd", "/C", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if err := cmd.Start(); err != nil {
+		_ = os.Remove(newExe)
+		return err
+	}
+	_ = cmd.Process.Release()
+	return nil
+}
+
+// publishedChecksum finds the SHA-256 of name in a "<name>.sha256" or checksums asset of rel.
+func (s *Service) publishedChecksum(rel *githubRelease, name string) (string, error) {
+	for _, a := range rel.Assets {
+		lower := strings.ToLower(a.Name)
+		if lower != strings.ToLower(name)+".sha256" && !strings.Contains(lower, "checksums") && !strings.Contains(lower, "sha256sums") {
+			continue
+		}
+		req, err := http.NewRequest("GET", a.BrowserDownloadURL, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", "zapret-ui/1.0")
+		resp, err := s.downloadClient().Do(req)
+		if err != nil {
+			return "", s.explainNetErr(err)
+		}
+		sum := parseChecksum(resp.Body, name)
+		resp.Body.Close()
+		if sum != "" {
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no checksum for %s", rel.TagName, name)
+}
+
+// parseChecksum reads "<hash>  <file>" lines (or a bare hash) and returns the hash for name.
+func parseChecksum(r io.Reader, name string) string {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		if len(fields) == 1 || strings.EqualFold(strings.TrimPrefix(fields[len(fields)-1], "*"), name) {
+			return strings.ToLower(fields[0])
+		}
+	}
+	return ""
+}
+
+// downloadVerified downloads url to path and checks that its SHA-256 equals want.
+func (s *Service) downloadVerified(url, path, want string) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "zapret-ui/1.0")
+	resp, err := s.downloadClient().Do(req)
+	if err != nil {
+		return s.explainNetErr(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return errors.New("downloaded executable failed checksum verification")
+	}
+	return nil
+}
+
+// swapScript waits for pid to exit, replaces exe with newExe and relaunches. If the swap fails
+// the original binary is put back (or never moved) and started again.
+func swapScript(exe, newExe string, pid int) string {
+	backup := exe + ".bak"
+	lines := []string{
+		"@echo off",
+		":wait",
+		fmt.Sprintf(`tasklist /FI "PID eq %d" | find "%d" >nul && (timeout /t 1 /nobreak >nul & goto wait)`, pid, pid),
+		fmt.Sprintf(`move /Y "%s" "%s" >nul || goto fail`, exe, backup),
+		fmt.Sprintf(`move /Y "%s" "%s" >nul || (move /Y "%s" "%s" >nul & goto fail)`, newExe, exe, backup, exe),
+		fmt.Sprintf(`del "%s" >nul 2>&1`, backup),
+		fmt.Sprintf(`start "" "%s"`, exe),
+		`(goto) 2>nul & del "%~f0"`,
+		":fail",
+		fmt.Sprintf(`del "%s" >nul 2>&1`, newExe),
+		fmt.Sprintf(`start "" "%s"`, exe),
+		`(goto) 2>nul & del "%~f0"`,
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
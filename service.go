@@ -9,35 +9,130 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 const (
+	// githubHost and githubAPIHost are the URL prefixes swapped out when a mirror is used.
+	githubHost    = "https://github.com"
+	githubAPIHost = "https://api.github.com"
+	// repoAPIReleasesURL is the GitHub Releases API endpoint listing recent releases, newest first.
+	repoAPIReleasesURL = "https://api.github.com/repos/Flowseal/zapret-discord-youtube/releases?per_page=30"
+	// repoAPITagURL is the GitHub Releases API endpoint describing the release for a given tag.
+	repoAPITagURL = "https://api.github.com/repos/Flowseal/zapret-discord-youtube/releases/tags/%s"
 	// repoLatestURL points to the redirect URL that reveals the latest tag.
 	repoLatestURL = "https://github.com/Flowseal/zapret-discord-youtube/releases/latest"
-	// downloadTemplate builds the direct zip download URL for a given tag.
+	// downloadTemplate builds the direct zip download URL for a given tag when the API can't list assets.
 	downloadTemplate = "https://github.com/Flowseal/zapret-discord-youtube/releases/download/%s/zapret-discord-youtube-%s.zip"
 	// createNewConsole is the Windows flag to spawn a process in a new console window.
 	createNewConsole = 0x00000010
+	// stagingSuffix marks a release directory that is still being extracted.
+	stagingSuffix = ".staging"
+	// backupSuffix marks a release directory set aside while it is being repaired.
+	backupSuffix = ".old"
+	// diskSpaceFactor is the free space required per byte of release archive: the zip itself,
+	// its extracted copy, and headroom.
+	diskSpaceFactor = 4
+	// downloadAttempts is how many times a release download is tried before giving up.
+	downloadAttempts = 3
+	// defaultLatestCacheTTL limits how often State() asks GitHub for the latest release
+	// unless Config.LatestCheckMinutes overrides it.
+	defaultLatestCacheTTL = 15 * time.Minute
+	// channelStable and channelPrerelease are the supported Config.UpdateChannel values.
+	channelStable     = "stable"
+	channelPrerelease = "prerelease"
+	// latestRetryInterval is how long to wait before retrying a failed latest-release lookup.
+	latestRetryInterval = time.Minute
+	// eventLatestTag is the Wails event name carrying LatestTagEvent payloads.
+	eventLatestTag = "state:latest-tag"
+	// eventDownloadProgress is the Wails event name carrying DownloadProgress payloads.
+	eventDownloadProgress = "downloadProgress"
+	// eventState is the Wails event name carrying a full State after changes the UI didn't initiate.
+	eventState = "state:changed"
 )
 
 // Service coordinates config, downloads, strategy listing, test runs, and process launches.
 type Service struct {
-	baseDir     string
-	configPath  string
-	releasesDir string
-	logsDir     string
-	config      *Config
-	client      *http.Client
+	// pathsMu guards paths, which SetBaseDir changes; read them through dirs.
+	pathsMu sync.RWMutex
+	paths   dataPaths
+	config  *Config
+	// clientMu guards client and dlClient, which SetProxy replaces; read them through apiClient
+	// and downloadClient.
+	clientMu sync.RWMutex
+	client   *http.Client
+	dlClient *http.Client
+	ctx      context.Context
+
+	// cfgMu guards config and its writes to disk; use updateConfig and configSnapshot.
+	cfgMu sync.Mutex
+	// configMod is the modification time of config.json as last read or written by the app.
+	configMod time.Time
+	// testing is set while RunTests owns the PowerShell test run.
+	testing atomic.Bool
+	// testMu guards testCancel, testDone and testCanceled, through which CancelTests stops the run
+	// that holds testing and learns how it ended.
+	testMu       sync.Mutex
+	testCancel   context.CancelFunc
+	testDone     chan struct{}
+	testCanceled string
+	// testJob is the ID of the TestJob this session runs, guarded by testMu.
+	testJob string
+	// testDurations are how long the configs of the running test took; see setTestDurations.
+	testDurations map[string]time.Duration
+	// historyMu serializes reads and writes of the test history file.
+	historyMu sync.Mutex
+	// runMu serializes starting and stopping strategies.
+	runMu sync.Mutex
+	// runWatch cancels the crash watch of the running strategy; crashes counts its crashes in a
+	// row; runJob is the job object the strategy runs in, nil with Settings.KeepStrategyOnExit.
+	// All are guarded by runMu.
+	runWatch context.CancelFunc
+	crashes  crashSeries
+	runJob   *processJob
+	// usage keeps the previous CPU sample of the running strategy's winws.exe.
+	usage usageSampler
+	// awake is the power request held for Settings.KeepAwake; see syncKeepAwake.
+	awakeMu sync.Mutex
+	awake   *powerRequest
+	// listMu serializes edits of the release's list files; listBackedUp records the files backed
+	// up before their first edit this session.
+	listMu       sync.Mutex
+	listBackedUp map[string]bool
+
+	// latestMu guards the cached release lookup below; fetchMu serializes the lookups themselves.
+	latestMu    sync.Mutex
+	fetchMu     sync.Mutex
+	refreshing  atomic.Bool
+	latest      *githubRelease
+	releases    []githubRelease
+	latestAt    time.Time
+	latestTried time.Time
+	// appLatest caches the latest zapret-ui release for the self-update check.
+	appLatest     *githubRelease
+	appLatestAt   time.Time
+	appRefreshing atomic.Bool
+	// etag and etagURL remember the last release list response for conditional requests.
+	etag    string
+	etagURL string
+	// configRecovered is the path a corrupt config.json was moved to during this session.
+	configRecovered string
+
+	updateMu     sync.Mutex
+	updateCancel context.CancelFunc
+	bgCancel     context.CancelFunc
 }
 
 // Config is persisted state across app launches.
@@ -50,6 +145,59 @@ type Config struct {
 	Meta           map[string]interface{} `json:"meta,omitempty"`
 	Running        *RunningInfo           `json:"running,omitempty"`
 	TestInProgress bool                   `json:"testInProgress"`
+	// Paused is the strategy PauseRunning stopped, until ResumeRunning or another start.
+	Paused *PausedInfo `json:"paused,omitempty"`
+	// TestPID and TestStartedAt identify the PowerShell test process while TestInProgress is set.
+	TestPID       int       `json:"testPid,omitempty"`
+	TestStartedAt time.Time `json:"testStartedAt"`
+	// TestLog is the output log of the latest test run.
+	TestLog string `json:"testLog,omitempty"`
+	// TestTargets are the configs a RunTestFor or RunTestsWith run in progress tests; empty for a
+	// full run.
+	TestTargets []string `json:"testTargets,omitempty"`
+	// TestDomains are sites the user cares about, probed with each tested strategy running after
+	// the test script is done; see SetTestDomains.
+	TestDomains []string `json:"testDomains,omitempty"`
+	// ParseWarnings are what the parser couldn't read in the results of the last test run.
+	ParseWarnings []string `json:"parseWarnings,omitempty"`
+	// TestJob is the running or most recent test job; see StartTests.
+	TestJob *TestJob `json:"testJob,omitempty"`
+	// LastRunChanges compares the latest test run with the one before it.
+	LastRunChanges *RunChanges `json:"lastRunChanges,omitempty"`
+	// QuickCheckTargets are the endpoints QuickCheck requests; empty means the defaults.
+	QuickCheckTargets []string `json:"quickCheckTargets,omitempty"`
+	// QuickCheck is the result of the last QuickCheck.
+	QuickCheck *QuickCheckResult `json:"quickCheck,omitempty"`
+	// ProxyURL routes GitHub traffic through a proxy; empty means use the system proxy settings.
+	ProxyURL string `json:"proxyUrl,omitempty"`
+	// Mirrors are base URLs standing in for https://github.com when downloading release archives.
+	Mirrors []string `json:"mirrors,omitempty"`
+	// APIMirrors are base URLs standing in for https://api.github.com when resolving the latest release.
+	APIMirrors []string `json:"apiMirrors,omitempty"`
+	// UpdateChannel selects which releases count as updates: "stable" (default) or "prerelease".
+	UpdateChannel string `json:"updateChannel,omitempty"`
+	// SkippedVersions are tags the user dismissed; they no longer count as available updates.
+	SkippedVersions []string `json:"skippedVersions,omitempty"`
+	// LatestCheckMinutes is how long a latest-release lookup is cached; 0 means 15 minutes.
+	LatestCheckMinutes int `json:"latestCheckMinutes,omitempty"`
+	// AssetPattern is a glob selecting the release archive; "{tag}" expands to the tag.
+	// Empty means zapret-discord-youtube-{tag}.zip.
+	AssetPattern string `json:"assetPattern,omitempty"`
+	// UpdateCheckDisabled turns off the periodic background update check.
+	UpdateCheckDisabled bool `json:"updateCheckDisabled,omitempty"`
+	// PendingVersion is a downloaded release that becomes current once the running strategy stops.
+	PendingVersion string `json:"pendingVersion,omitempty"`
+	// Favorites and Hidden are strategy file names pinned to the top or left out of the list.
+	Favorites []string `json:"favorites,omitempty"`
+	Hidden    []string `json:"hidden,omitempty"`
+	// StrategyMeta holds user aliases and notes by strategy file name.
+	StrategyMeta map[string]StrategyUserMeta `json:"strategyMeta,omitempty"`
+	// RunStats counts launches and runtime by strategy file name.
+	RunStats map[string]RunStats `json:"runStats,omitempty"`
+	// Settings are the preferences edited through UpdateSettings.
+	Settings Settings `json:"settings"`
+	// KeepReleases is how many unpacked releases to retain after an update; 0 disables pruning.
+	KeepReleases int `json:"keepReleases"`
 }
 
 // TestResult captures analytics from the official PowerShell test script.
@@ -64,6 +212,15 @@ type TestResult struct {
 	Blocked      int       `json:"blocked"`
 	Status       string    `json:"status"` // ok | fail
 	LastTestedAt time.Time `json:"lastTestedAt"`
+	Version      string    `json:"version,omitempty"` // release tag the test ran against
+	Mode         string    `json:"mode,omitempty"`    // "standard", "dpi" or "native"
+	// Domains are the outcomes ("ok" or "fail") of probing Config.TestDomains with this strategy running.
+	Domains map[string]string `json:"domains,omitempty"`
+	// DomainResults are the outcomes ("ok", "err" or "blocked") of the domains the test script
+	// itself checked with this strategy, from the body of its output.
+	DomainResults map[string]string `json:"domainResults,omitempty"`
+	// DurationMs is how long testing this strategy took; 0 when it wasn't measured.
+	DurationMs int64 `json:"durationMs,omitempty"`
 }
 
 // Strategy is a single general*.bat with its last known test result.
@@ -71,42 +228,337 @@ type Strategy struct {
 	Name   string     `json:"name"`
 	File   string     `json:"file"`
 	Result TestResult `json:"result"`
-	Best   bool       `json:"best"`
+	// Score ranks Result (see scoreResult); Best marks the highest score of the last test run.
+	Score int  `json:"score"`
+	Best  bool `json:"best"`
+	// Stale is set when Result was measured on a different release than the current one.
+	Stale bool `json:"stale"`
+	// Category is "general", "discord", "util" or "service"; only general strategies are tested.
+	Category string `json:"category"`
+	// Details summarises the winws.exe arguments; nil for scripts that don't start winws.
+	Details *StrategyDetails `json:"details,omitempty"`
+	// Custom strategies live in the custom folder and are copied into the release to run.
+	Custom bool `json:"custom"`
+	// Alias, Note and Tags come from Config.StrategyMeta.
+	Alias    string   `json:"alias,omitempty"`
+	Note     string   `json:"note,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Favorite bool     `json:"favorite"`
+	Hidden   bool     `json:"hidden"`
+	// Problems lists files the strategy references that are missing, such as a winws.exe removed
+	// by an antivirus, and winws options the release's winws.exe doesn't support.
+	Problems []string `json:"problems,omitempty"`
+	// Group is the name shared by a strategy and its game-filter variant; GameFilter marks the variant.
+	Group      string `json:"group"`
+	GameFilter bool   `json:"gameFilter"`
+	// RequiresAdmin is set for strategies that start winws, which needs an elevated app.
+	RequiresAdmin bool `json:"requiresAdmin"`
+	// Stats comes from Config.RunStats; nil for strategies never launched.
+	Stats *RunStats `json:"stats,omitempty"`
+	// Fingerprint identifies the effective winws arguments; DuplicateOf names an earlier strategy
+	// (bundled before custom) with the same fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	DuplicateOf string `json:"duplicateOf,omitempty"`
 }
 
 // State is the DTO returned to the UI.
 type State struct {
-	Config      *Config      `json:"config"`
-	Strategies  []Strategy   `json:"strategies"`
-	LatestTag   string       `json:"latestTag"`
-	HasUpdate   bool         `json:"hasUpdate"`
-	CurrentPath string       `json:"currentPath"`
-	LastTestLog string       `json:"lastTestLog"`
-	Running     *RunningInfo `json:"running,omitempty"`
+	Config      *Config    `json:"config"`
+	Strategies  []Strategy `json:"strategies"`
+	LatestTag   string     `json:"latestTag"`
+	HasUpdate   bool       `json:"hasUpdate"`
+	CurrentPath string     `json:"currentPath"`
+	BaseDir     string     `json:"baseDir"`
+	// ReleaseHealthy is false when the current release lacks required files (see MissingFiles).
+	ReleaseHealthy bool     `json:"releaseHealthy"`
+	MissingFiles   []string `json:"missingFiles,omitempty"`
+	// PendingVersion is a downloaded update waiting for the running strategy to stop.
+	PendingVersion string `json:"pendingVersion,omitempty"`
+	AppVersion     string `json:"appVersion"`
+	LatestAppTag   string `json:"latestAppTag"`
+	HasAppUpdate   bool   `json:"hasAppUpdate"`
+	ReleaseNotes   string `json:"releaseNotes"`
+	// Repair is only set on the State returned by RepairCurrentRelease.
+	Repair *RepairSummary `json:"repair,omitempty"`
+	// ListMigration is only set on the State returned by CheckAndUpdate.
+	ListMigration []ListMigration `json:"listMigration,omitempty"`
+	// StrategyChanges is only set on the State returned by CheckAndUpdate; it compares the general
+	// strategies of the previous release with the downloaded one.
+	StrategyChanges []StrategyDiff `json:"strategyChanges,omitempty"`
+	LastTestLog     string         `json:"lastTestLog"`
+	// AutoStartWarning is set when autostart is enabled from a location that may not last.
+	AutoStartWarning string `json:"autoStartWarning,omitempty"`
+	// TestCanceled is only set on the State returned by CancelTests: "partial" when results
+	// written before the cancel were kept, "none" otherwise.
+	TestCanceled string `json:"testCanceled,omitempty"`
+	// ConfigRecovery is the path of an unreadable config.json that was set aside at startup.
+	ConfigRecovery string `json:"configRecovery,omitempty"`
+	// Elevated is false when the app runs without administrator rights and can't start winws.
+	Elevated bool `json:"elevated"`
+	// WinwsVersion is the file version of the current release's winws.exe, if it has one.
+	WinwsVersion string `json:"winwsVersion,omitempty"`
+	// ParseWarnings flag lines of the last test results the parser couldn't read, which usually
+	// means the test script's output format changed.
+	ParseWarnings []string `json:"parseWarnings,omitempty"`
+	// AutoApplied is only set on the State returned by a test run that started the best strategy
+	// because of TestOptions.AutoApplyBest or Settings.AutoApplyBest.
+	AutoApplied *AutoApplied `json:"autoApplied,omitempty"`
+	// Retested is only set on the State returned by RetestFailed: the strategies it got fresh
+	// results for.
+	Retested []string `json:"retested,omitempty"`
+	// Stopped is only set on the State returned by StopStrategy and StopAllWinws.
+	Stopped *StopResult `json:"stopped,omitempty"`
+	// QuickCheck is the last QuickCheck result, for showing how fresh it is.
+	QuickCheck *QuickCheckResult `json:"quickCheck,omitempty"`
+	Running    *RunningInfo      `json:"running,omitempty"`
+	// ExternalRunning are winws.exe processes the app didn't start; see AdoptRunning.
+	ExternalRunning []ExternalProcess `json:"externalRunning,omitempty"`
+	// DriverStatus is the state of the WinDivert driver service; see ResetDriver.
+	DriverStatus DriverStatus `json:"driverStatus"`
+	// ServiceInstalled reports the zapret Windows service; see InstallAsService.
+	ServiceInstalled bool `json:"serviceInstalled"`
+	ServiceRunning   bool `json:"serviceRunning"`
+	// ServiceStrategy is the strategy the service runs, "" when it can't be told.
+	ServiceStrategy string `json:"serviceStrategy,omitempty"`
+	// Conflicts are the other DPI bypass tools and VPNs active; external winws.exe processes are in
+	// ExternalRunning instead.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// RunningUsage is set while Running is.
+	RunningUsage *RunningUsage `json:"runningUsage,omitempty"`
 }
 
 // RunningInfo tracks the last launched strategy process.
 type RunningInfo struct {
-	File      string    `json:"file"`
+	File    string `json:"file"`
+	Release string `json:"release,omitempty"`
+	// Staged is the copy of the strategy script that was run, see stageRun; it is removed when the
+	// strategy stops.
+	Staged string `json:"staged,omitempty"`
+	// PID is the cmd.exe running the .bat, which exits once it has started winws; WinwsPID is the
+	// winws.exe it started, 0 if it wasn't found.
 	PID       int       `json:"pid"`
+	WinwsPID  int       `json:"winwsPid,omitempty"`
 	StartedAt time.Time `json:"startedAt"`
+	// SessionStartedAt is when the strategy was first started; unlike StartedAt, which belongs to
+	// the current process, it is kept across restarts.
+	SessionStartedAt time.Time `json:"sessionStartedAt,omitempty"`
+	// SeenAt is the last time the app saw the process alive. If the app is killed, the run is
+	// counted up to SeenAt once the next launch finds the process gone.
+	SeenAt time.Time `json:"seenAt,omitempty"`
+	// Restarts counts the times the strategy was restarted, by the crash watch or RestartRunning,
+	// since it was started.
+	Restarts int `json:"restarts,omitempty"`
+	// Log is the file the output of the strategy goes to; see GetRunningLogTail.
+	Log string `json:"log,omitempty"`
+}
+
+// sessionStart returns SessionStartedAt, or StartedAt for runs recorded before it was kept.
+func (r *RunningInfo) sessionStart() time.Time {
+	if r.SessionStartedAt.IsZero() {
+		return r.StartedAt
+	}
+	return r.SessionStartedAt
+}
+
+// alive reports whether the strategy still runs, going by its winws.exe if known and by the
+// launcher otherwise.
+func (r *RunningInfo) alive() bool {
+	if r.WinwsPID > 0 {
+		return isProcessRunning(r.WinwsPID, "winws.exe")
+	}
+	return isProcessRunning(r.PID, "cmd.exe")
+}
+
+// githubRelease is the subset of the GitHub release payload the app cares about.
+type githubRelease struct {
+	TagName     string        `json:"tag_name"`
+	Name        string        `json:"name"`
+	Body        string        `json:"body"`
+	HTMLURL     string        `json:"html_url"`
+	PublishedAt time.Time     `json:"published_at"`
+	Prerelease  bool          `json:"prerelease"`
+	Draft       bool          `json:"draft"`
+	Assets      []githubAsset `json:"assets"`
+}
+
+// githubAsset is a downloadable file attached to a GitHub release.
+type githubAsset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// ReleaseInfo describes a release unpacked under releasesDir.
+type ReleaseInfo struct {
+	Tag       string    `json:"tag"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"sizeBytes"`
+	Current   bool      `json:"current"`
+	ModTime   time.Time `json:"modTime"`
+}
+
+// PruneSummary reports what PruneReleases removed.
+type PruneSummary struct {
+	Deleted        []string `json:"deleted"`
+	ReclaimedBytes int64    `json:"reclaimedBytes"`
+}
+
+// UpdateCanceledError is returned by CheckAndUpdate when the update was aborted via CancelUpdate.
+type UpdateCanceledError struct {
+	Tag string
+	Err error
+}
+
+func (e *UpdateCanceledError) Error() string {
+	return fmt.Sprintf("update canceled: %s", e.Tag)
+}
+
+func (e *UpdateCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// ReleaseNotFoundError is returned when a requested tag doesn't exist upstream.
+type ReleaseNotFoundError struct {
+	Tag string
+}
+
+func (e *ReleaseNotFoundError) Error() string {
+	return fmt.Sprintf("release not found: %s", e.Tag)
+}
+
+// InsufficientDiskSpaceError is returned before a download when the releases volume is too full.
+type InsufficientDiskSpaceError struct {
+	Need uint64
+	Free uint64
+}
+
+func (e *InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space, need %d MB, %d MB free", e.Need>>20, e.Free>>20)
+}
+
+// StorageUsage reports how much disk space the app uses.
+type StorageUsage struct {
+	ReleasesBytes int64  `json:"releasesBytes"`
+	LogsBytes     int64  `json:"logsBytes"`
+	FreeBytes     uint64 `json:"freeBytes"`
+}
+
+// TestInProgressError is returned when RunTests is called while another test run is active.
+type TestInProgressError struct{}
+
+func (e *TestInProgressError) Error() string {
+	return "a test run is already in progress"
+}
+
+// TestTimeoutError is returned when a test run doesn't produce results within the configured timeout.
+type TestTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TestTimeoutError) Error() string {
+	return fmt.Sprintf("tests did not finish within the configured timeout of %s; raise the test timeout in settings (up to %d minutes)", e.Timeout, maxTestTimeoutMinutes)
+}
+
+// AdminRequiredError is returned by RunStrategy when the app isn't elevated: winws loads the
+// WinDivert driver, which needs administrator rights. RelaunchAsAdmin resolves it.
+type AdminRequiredError struct {
+	Strategy string
+}
+
+func (e *AdminRequiredError) Error() string {
+	return fmt.Sprintf("%s needs administrator rights; restart zapret-ui as administrator", e.Strategy)
+}
+
+// TestsCanceledError is returned by the RunTests family when CancelTests stopped the run.
+// Partial is set when results written before the cancel were kept.
+type TestsCanceledError struct {
+	Partial bool
+}
+
+func (e *TestsCanceledError) Error() string {
+	if e.Partial {
+		return "tests canceled, partial results kept"
+	}
+	return "tests canceled"
+}
+
+// LatestVersionUnknownError is returned when the latest version can't be determined, typically
+// because an intercepting proxy or captive portal answered instead of GitHub.
+type LatestVersionUnknownError struct {
+	Reason string
+}
+
+func (e *LatestVersionUnknownError) Error() string {
+	return fmt.Sprintf("could not determine latest version, your network may be interfering: %s", e.Reason)
+}
+
+// DownloadProgress is emitted while a release is being downloaded and unpacked.
+type DownloadProgress struct {
+	Tag     string  `json:"tag"`
+	Phase   string  `json:"phase"`   // downloading | unpacking | done
+	Percent float64 `json:"percent"` // -1 when the total size is unknown
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
 }
 
-// NewService sets up paths and an HTTP client.
+// NewService sets up paths and HTTP clients.
 func NewService() *Service {
-	base := defaultBaseDir()
-	return &Service{
-		baseDir:     base,
-		configPath:  filepath.Join(base, "config.json"),
-		releasesDir: filepath.Join(base, "releases"),
-		logsDir:     filepath.Join(base, "logs"),
-		client: &http.Client{
-			Timeout: 15 * time.Second,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		},
+	client, dlClient := newHTTPClients(nil)
+	s := &Service{
+		client:   client,
+		dlClient: dlClient,
+	}
+	s.setPaths(resolveBaseDir())
+	return s
+}
+
+// setContext stores the Wails context used to emit events to the frontend.
+func (s *Service) setContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// emit sends an event to the frontend; it is a no-op until the Wails context is set.
+func (s *Service) emit(name string, data interface{}) {
+	if s.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(s.ctx, name, data)
+}
+
+func (s *Service) emitProgress(tag, phase string, done, total int64) {
+	percent := -1.0
+	if total > 0 {
+		percent = float64(done) * 100 / float64(total)
+	}
+	if phase == "done" {
+		percent = 100
 	}
+	s.emit(eventDownloadProgress, DownloadProgress{
+		Tag:     tag,
+		Phase:   phase,
+		Percent: percent,
+		Bytes:   done,
+		Total:   total,
+	})
+}
+
+// progressReader reports the number of bytes read through it, throttled to avoid flooding the UI.
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	last     time.Time
+	onUpdate func(done, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if time.Since(p.last) >= 200*time.Millisecond || err == io.EOF {
+		p.last = time.Now()
+		p.onUpdate(p.done, p.total)
+	}
+	return n, err
 }
 
 func defaultBaseDir() string {
@@ -119,7 +571,8 @@ func defaultBaseDir() string {
 
 // ensureDirs prepares required folders.
 func (s *Service) ensureDirs() error {
-	for _, d := range []string{s.baseDir, s.releasesDir, s.logsDir} {
+	p := s.dirs()
+	for _, d := range []string{p.baseDir, p.releasesDir, p.logsDir, p.customDir} {
 		if err := os.MkdirAll(d, 0o755); err != nil {
 			return err
 		}
@@ -127,31 +580,64 @@ func (s *Service) ensureDirs() error {
 	return nil
 }
 
+// newConfig returns the defaults used before the first save.
+func newConfig() *Config {
+	return &Config{
+		TestResults: make(map[string]TestResult),
+		Meta:        make(map[string]interface{}),
+		// The window has always hidden to the tray on close.
+		Settings: Settings{CloseToTray: true},
+	}
+}
+
 func (s *Service) loadConfig() (*Config, error) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
 	if s.config != nil {
 		return s.config, nil
 	}
 	if err := s.ensureDirs(); err != nil {
 		return nil, err
 	}
-	cfg := &Config{
-		TestResults: make(map[string]TestResult),
-		Meta:        make(map[string]interface{}),
-	}
-	data, err := os.ReadFile(s.configPath)
+	cfg := newConfig()
+	data, err := os.ReadFile(s.dirs().configPath)
 	if err == nil {
-		_ = json.Unmarshal(data, cfg)
+		if err := json.Unmarshal(data, cfg); err != nil {
+			// Keep the damaged file for inspection and start over from defaults.
+			bad := fmt.Sprintf("%s.bad-%s", s.dirs().configPath, time.Now().Format("20060102-150405"))
+			_ = os.Rename(s.dirs().configPath, bad)
+			s.configRecovered = bad
+			cfg = newConfig()
+		} else if cfg.Settings.UpdateCheckHours == 0 {
+			// updateCheckHours used to live at the top level.
+			var legacy struct {
+				UpdateCheckHours int `json:"updateCheckHours"`
+			}
+			if json.Unmarshal(data, &legacy) == nil && legacy.UpdateCheckHours > 0 {
+				cfg.Settings.UpdateCheckHours = legacy.UpdateCheckHours
+			}
+		}
+	}
+	if cfg.ProxyURL != "" {
+		if err := s.applyProxy(cfg.ProxyURL); err != nil {
+			// Keep running with the system proxy rather than refusing to start.
+			cfg.ProxyURL = ""
+		}
 	}
 	if cfg.Version == "" {
-		// Seed from bundled release if present
-		if v, err := s.seedLocalRelease(); err == nil && v != "" {
+		// Seed from the release embedded into the executable, if any
+		if v, err := s.seedEmbeddedRelease(); err == nil && v != "" {
 			cfg.Version = v
 		}
 	}
+	if info, err := os.Stat(s.dirs().configPath); err == nil {
+		s.configMod = info.ModTime()
+	}
 	s.config = cfg
 	return cfg, nil
 }
 
+// saveConfig persists the in-memory config; the caller must hold cfgMu.
 func (s *Service) saveConfig() error {
 	if s.config == nil {
 		return errors.New("config nil")
@@ -160,187 +646,1134 @@ func (s *Service) saveConfig() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.configPath, data, 0o644)
+	if info, err := os.Stat(s.dirs().configPath); err == nil && !s.configMod.IsZero() && !info.ModTime().Equal(s.configMod) {
+		// The watcher hasn't picked the edit up yet; there is nothing to merge against, so say so.
+		s.logUpdate("config.json was edited outside the app since it was last read; the edit is overwritten")
+	}
+	if err := writeFileAtomic(s.dirs().configPath, data, 0o644); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.dirs().configPath); err == nil {
+		s.configMod = info.ModTime()
+	}
+	return nil
 }
 
-// seedLocalRelease copies a bundled ./release/<ver> into cache and returns the detected version.
-func (s *Service) seedLocalRelease() (string, error) {
-	cwd, err := os.Getwd()
+// updateConfig applies fn to the config and saves it, all under cfgMu.
+// The config is not saved when fn returns an error.
+func (s *Service) updateConfig(fn func(cfg *Config) error) error {
+	cfg, err := s.loadConfig()
 	if err != nil {
-		return "", err
+		return err
 	}
-	releaseRoot := filepath.Join(cwd, "release")
-	entries, err := os.ReadDir(releaseRoot)
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return s.saveConfig()
+}
+
+// configSnapshot returns a copy of the config that is safe to read and hand to the UI
+// while other calls keep mutating the live one.
+func (s *Service) configSnapshot() (*Config, error) {
+	cfg, err := s.loadConfig()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var versions []string
-	for _, e := range entries {
-		if e.IsDir() {
-			versions = append(versions, e.Name())
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	c := *cfg
+	c.TestResults = make(map[string]TestResult, len(cfg.TestResults))
+	for k, v := range cfg.TestResults {
+		if v.Domains != nil {
+			domains := make(map[string]string, len(v.Domains))
+			for d, outcome := range v.Domains {
+				domains[d] = outcome
+			}
+			v.Domains = domains
+		}
+		if v.DomainResults != nil {
+			domains := make(map[string]string, len(v.DomainResults))
+			for d, outcome := range v.DomainResults {
+				domains[d] = outcome
+			}
+			v.DomainResults = domains
 		}
+		c.TestResults[k] = v
 	}
-	if len(versions) == 0 {
-		return "", errors.New("no bundled releases")
+	c.Meta = make(map[string]interface{}, len(cfg.Meta))
+	for k, v := range cfg.Meta {
+		c.Meta[k] = v
 	}
-	sort.Strings(versions)
-	latest := versions[len(versions)-1]
-	src := filepath.Join(releaseRoot, latest)
-	dst := filepath.Join(s.releasesDir, latest)
-	if _, err := os.Stat(dst); err == nil {
-		return latest, nil
+	c.StrategyMeta = make(map[string]StrategyUserMeta, len(cfg.StrategyMeta))
+	for k, v := range cfg.StrategyMeta {
+		v.Tags = append([]string(nil), v.Tags...)
+		c.StrategyMeta[k] = v
 	}
-	if err := copyDir(src, dst); err != nil {
-		return "", err
+	c.RunStats = make(map[string]RunStats, len(cfg.RunStats))
+	for k, v := range cfg.RunStats {
+		c.RunStats[k] = v
+	}
+	if cfg.Running != nil {
+		r := *cfg.Running
+		c.Running = &r
+	}
+	if cfg.Paused != nil {
+		p := *cfg.Paused
+		c.Paused = &p
+	}
+	if cfg.TestJob != nil {
+		j := *cfg.TestJob
+		j.Configs = append([]string(nil), j.Configs...)
+		if j.Diagnostics != nil {
+			d := *j.Diagnostics
+			d.Checks = append([]DiagnosticCheck(nil), d.Checks...)
+			j.Diagnostics = &d
+		}
+		c.TestJob = &j
+	}
+	if cfg.LastRunChanges != nil {
+		rc := *cfg.LastRunChanges
+		c.LastRunChanges = &rc
+	}
+	if cfg.QuickCheck != nil {
+		q := *cfg.QuickCheck
+		q.Endpoints = append([]QuickCheckEndpoint(nil), q.Endpoints...)
+		c.QuickCheck = &q
+	}
+	c.Mirrors = append([]string(nil), cfg.Mirrors...)
+	c.APIMirrors = append([]string(nil), cfg.APIMirrors...)
+	c.SkippedVersions = append([]string(nil), cfg.SkippedVersions...)
+	c.Favorites = append([]string(nil), cfg.Favorites...)
+	c.Hidden = append([]string(nil), cfg.Hidden...)
+	c.TestTargets = append([]string(nil), cfg.TestTargets...)
+	c.TestDomains = append([]string(nil), cfg.TestDomains...)
+	c.ParseWarnings = append([]string(nil), cfg.ParseWarnings...)
+	c.QuickCheckTargets = append([]string(nil), cfg.QuickCheckTargets...)
+	c.Settings.FailoverChain = append([]string(nil), cfg.Settings.FailoverChain...)
+	return &c, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path, syncs it, and renames it into place,
+// so a crash mid-write leaves either the old or the new content, never a truncated file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
 	}
-	return latest, nil
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
 func (s *Service) State() (*State, error) {
-	cfg, err := s.loadConfig()
+	// Validate running process if we have one recorded.
+	pending, abandonedTarget := false, false
+	err := s.updateConfig(func(cfg *Config) error {
+		if cfg.Running != nil {
+			if cfg.Running.alive() {
+				cfg.Running.SeenAt = time.Now()
+			} else {
+				recordRunEnd(cfg, cfg.Running, cfg.Running.SeenAt)
+				cfg.Running = nil
+			}
+		}
+		// A test run this session doesn't own was left behind by a crash or a closed app.
+		if cfg.TestInProgress && !s.testing.Load() && !processAlive(cfg.TestPID, cfg.TestStartedAt) {
+			abandonedTarget = len(cfg.TestTargets) > 0
+			cfg.TestInProgress = false
+			cfg.TestPID = 0
+			cfg.TestStartedAt = time.Time{}
+			cfg.TestTargets = nil
+		}
+		pending = cfg.PendingVersion != "" && cfg.Running == nil
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	// A strategy that exited on its own (or an app restart) frees us to apply a deferred update.
+	if pending {
+		s.applyPendingUpdate()
+	}
+	if current := s.currentReleasePath(); abandonedTarget && current != "" {
+		restoreResults(current)
+	}
+
 	// Rehydrate last test results from disk for initial UI load.
 	// Try to refresh in-memory results from the latest test_results file on disk,
 	// so cards are populated immediately on app start without re-running tests.
-	if current := s.currentReleasePath(); current != "" {
+	if current := s.currentReleasePath(); current != "" && s.needsRehydration() {
 		if latest, err := s.parseLatestResult(current); err == nil && len(latest.Results) > 0 {
-			cfg.TestResults = latest.Results
-			cfg.BestStrategy = latest.Best
-			_ = s.saveConfig()
-		}
-		// Validate running process if we have one recorded.
-		if cfg.Running != nil {
-			if !isPIDRunning(cfg.Running.PID) {
-				cfg.Running = nil
-				_ = s.saveConfig()
-			}
+			_ = s.updateConfig(func(cfg *Config) error {
+				cfg.TestResults = latest.Results
+				cfg.BestStrategy = latest.Best
+				cfg.ParseWarnings = latest.Warnings
+				return nil
+			})
 		}
 	}
 
-	latest, _ := s.latestTag()
-	hasUpdate := latest != "" && latest != cfg.Version
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	// Never wait for GitHub here: use whatever is cached and refresh in the background.
+	rel, fresh := s.cachedLatest()
+	if !fresh {
+		s.refreshLatestAsync()
+	}
+	latest, notes := "", ""
+	if rel != nil {
+		latest, notes = rel.TagName, rel.Body
+	}
+	hasUpdate := s.updateAvailable(cfg, latest)
+	latestApp, hasAppUpdate := s.appUpdateInfo()
 
-	strategies, _ := s.listStrategies()
-	for i := range strategies {
-		res, ok := cfg.TestResults[strategies[i].Name]
-		if ok {
-			strategies[i].Result = res
-		}
-		if cfg.BestStrategy != "" && cfg.BestStrategy == strategies[i].Name {
-			strategies[i].Best = true
+	healthy := false
+	var missing []string
+	if current := s.currentReleasePath(); current != "" {
+		if m, err := missingReleaseFiles(current); err == nil {
+			missing = m
+			healthy = len(m) == 0
+		} else {
+			missing = []string{filepath.Base(current)}
 		}
 	}
 
+	strategies, _ := s.listStrategies()
+	strategies = decorateStrategies(cfg, strategies, false)
+
+	s.syncKeepAwake(cfg)
+	winSvc := queryWinService(strategies)
+	var usage *RunningUsage
+	if cfg.Running != nil {
+		usage = s.runningUsage(cfg.Running)
+	}
+
+	startWarning := ""
+	if cfg.Settings.AutoStart {
+		startWarning = autoStartWarning()
+	}
+
 	return &State{
-		Config:      cfg,
-		Strategies:  strategies,
-		LatestTag:   latest,
-		HasUpdate:   hasUpdate,
-		CurrentPath: s.currentReleasePath(),
-		Running:     cfg.Running,
+		Config:           cfg,
+		Strategies:       strategies,
+		LatestTag:        latest,
+		HasUpdate:        hasUpdate,
+		CurrentPath:      s.currentReleasePath(),
+		BaseDir:          s.dirs().baseDir,
+		ReleaseHealthy:   healthy,
+		MissingFiles:     missing,
+		PendingVersion:   cfg.PendingVersion,
+		AppVersion:       appVersion,
+		LatestAppTag:     latestApp,
+		HasAppUpdate:     hasAppUpdate,
+		ReleaseNotes:     notes,
+		ConfigRecovery:   s.configRecovered,
+		AutoStartWarning: startWarning,
+		Elevated:         isElevated(),
+		WinwsVersion:     winwsVersion(s.currentReleasePath()),
+		ParseWarnings:    cfg.ParseWarnings,
+		LastTestLog:      cfg.TestLog,
+		QuickCheck:       cfg.QuickCheck,
+		Running:          cfg.Running,
+		ExternalRunning:  s.externalWinws(cfg, strategies),
+		DriverStatus:     driverStatus(),
+		ServiceInstalled: winSvc.Installed,
+		ServiceRunning:   winSvc.Running,
+		ServiceStrategy:  winSvc.Strategy,
+		Conflicts:        s.detectConflicts(cfg, false),
+		RunningUsage:     usage,
 	}, nil
 }
 
 func (s *Service) currentReleasePath() string {
-	cfg, err := s.loadConfig()
+	cfg, err := s.configSnapshot()
 	if err != nil || cfg.Version == "" {
 		return ""
 	}
-	return filepath.Join(s.releasesDir, cfg.Version)
+	return filepath.Join(s.dirs().releasesDir, cfg.Version)
 }
 
-func (s *Service) latestTag() (string, error) {
-	req, err := http.NewRequest("GET", repoLatestURL, nil)
-	if err != nil {
-		return "", err
+// LatestTagEvent is emitted as "state:latest-tag" when a background latest-release check completes.
+type LatestTagEvent struct {
+	LatestTag    string `json:"latestTag"`
+	HasUpdate    bool   `json:"hasUpdate"`
+	ReleaseNotes string `json:"releaseNotes"`
+	Error        string `json:"error,omitempty"`
+}
+
+// cachedLatest returns the cached latest release and whether it is recent enough to skip a refresh.
+// After a failed lookup the cache counts as fresh for latestRetryInterval so offline use doesn't spam requests.
+func (s *Service) cachedLatest() (*githubRelease, bool) {
+	s.latestMu.Lock()
+	defer s.latestMu.Unlock()
+	if s.latest != nil && time.Since(s.latestAt) < s.latestCacheTTL() {
+		return s.latest, true
 	}
-	req.Header.Set("User-Agent", "zapret-ui/1.0")
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return "", err
+	return s.latest, time.Since(s.latestTried) < latestRetryInterval
+}
+
+// invalidateLatest drops the cached latest release, e.g. after a setting that affects the lookup changed.
+func (s *Service) invalidateLatest() {
+	s.latestMu.Lock()
+	s.latest = nil
+	s.latestTried = time.Time{}
+	s.latestMu.Unlock()
+}
+
+// refreshLatestAsync looks up the latest release in the background and emits eventLatestTag when done.
+func (s *Service) refreshLatestAsync() {
+	if !s.refreshing.CompareAndSwap(false, true) {
+		return
 	}
-	defer resp.Body.Close()
+	go func() {
+		defer s.refreshing.Store(false)
+		ev, _ := s.refreshLatest(false)
+		s.emit(eventLatestTag, ev)
+	}()
+}
 
-	loc := resp.Header.Get("Location")
-	if loc == "" {
-		// maybe already at final URL
-		loc = resp.Request.URL.String()
+// refreshLatest looks up the latest release and summarises it for the UI.
+func (s *Service) refreshLatest(force bool) (LatestTagEvent, error) {
+	var ev LatestTagEvent
+	rel, err := s.latestRelease(force)
+	if err != nil {
+		ev.Error = err.Error()
+		return ev, err
 	}
-	parts := strings.Split(strings.TrimRight(loc, "/"), "/")
-	if len(parts) == 0 {
-		return "", errors.New("cannot parse latest tag")
+	ev.LatestTag = rel.TagName
+	ev.ReleaseNotes = rel.Body
+	if cfg, cerr := s.configSnapshot(); cerr == nil {
+		ev.HasUpdate = s.updateAvailable(cfg, rel.TagName)
 	}
-	tag := parts[len(parts)-1]
-	return tag, nil
+	return ev, nil
 }
 
-func (s *Service) CheckAndUpdate() (*State, error) {
-	cfg, err := s.loadConfig()
-	if err != nil {
+// RefreshLatest forces a latest-release lookup and returns refreshed state.
+func (s *Service) RefreshLatest() (*State, error) {
+	if _, err := s.refreshLatest(true); err != nil {
 		return nil, err
 	}
-	latest, err := s.latestTag()
-	if err != nil {
-		return nil, err
+	return s.State()
+}
+
+// updateAvailable reports whether latest should be offered to the user as an update.
+func (s *Service) updateAvailable(cfg *Config, latest string) bool {
+	return s.isNewerRelease(latest, cfg.Version) && !containsString(cfg.SkippedVersions, latest)
+}
+
+// latestRelease returns the newest release on the configured update channel. Results are cached
+// for the configured TTL unless force is set; even then the request is conditional on the last ETag.
+// The GitHub API is preferred; the redirect of the releases page (stable only) is used when the API is unavailable.
+func (s *Service) latestRelease(force bool) (*githubRelease, error) {
+	// Serialize lookups; fields read by fetchReleases (etag, releases) are only written under fetchMu.
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	if !force {
+		s.latestMu.Lock()
+		rel, at := s.latest, s.latestAt
+		s.latestMu.Unlock()
+		if rel != nil && time.Since(at) < s.latestCacheTTL() {
+			return rel, nil
+		}
 	}
-	if cfg.Version == latest && latest != "" {
-		return s.State()
+	var apiMirrors []string
+	channel := channelStable
+	if cfg, cerr := s.configSnapshot(); cerr == nil {
+		apiMirrors = cfg.APIMirrors
+		if cfg.UpdateChannel == channelPrerelease {
+			channel = channelPrerelease
+		}
 	}
-	if err := s.downloadAndUnpack(latest); err != nil {
-		return nil, err
+	var releases []githubRelease
+	var err error
+	for _, u := range mirrorURLs(repoAPIReleasesURL, githubAPIHost, apiMirrors) {
+		if releases, err = s.fetchReleases(u); err == nil {
+			break
+		}
+		s.logUpdate("release list lookup via %s failed: %v", u, err)
+	}
+	var rel *githubRelease
+	if err == nil {
+		for i := range releases {
+			r := releases[i]
+			if r.Draft || (r.Prerelease && channel != channelPrerelease) {
+				continue
+			}
+			if rel == nil || r.PublishedAt.After(rel.PublishedAt) {
+				rel = &r
+			}
+		}
+		if rel == nil {
+			err = fmt.Errorf("no %s releases found", channel)
+		}
+	}
+	if rel == nil {
+		tag, rerr := s.latestTagFromRedirect()
+		if rerr != nil {
+			s.latestMu.Lock()
+			s.latestTried = time.Now()
+			s.latestMu.Unlock()
+			// A page we couldn't make sense of says more about the network than the API error does.
+			var unknown *LatestVersionUnknownError
+			if errors.As(rerr, &unknown) {
+				return nil, rerr
+			}
+			return nil, err
+		}
+		rel = &githubRelease{TagName: tag}
+	}
+	s.latestMu.Lock()
+	s.latest = rel
+	s.releases = releases
+	s.latestAt = time.Now()
+	s.latestTried = s.latestAt
+	s.latestMu.Unlock()
+	return rel, nil
+}
+
+// isNewerRelease reports whether latest should be offered as an update over current.
+// Tags are compared as versions; if either tag can't be parsed, publish dates from the
+// fetched release list decide, and failing that any different tag counts as newer.
+func (s *Service) isNewerRelease(latest, current string) bool {
+	if latest == "" || latest == current {
+		return false
 	}
-	cfg.Version = latest
-	if err := s.saveConfig(); err != nil {
+	if current == "" {
+		return true
+	}
+	lv, lok := parseVersion(latest)
+	cv, cok := parseVersion(current)
+	if lok && cok {
+		return compareVersions(lv, cv) > 0
+	}
+	s.latestMu.Lock()
+	defer s.latestMu.Unlock()
+	var l, c *githubRelease
+	for i := range s.releases {
+		switch s.releases[i].TagName {
+		case latest:
+			l = &s.releases[i]
+		case current:
+			c = &s.releases[i]
+		}
+	}
+	if l == nil || c == nil {
+		return true
+	}
+	return l.PublishedAt.After(c.PublishedAt)
+}
+
+// SetUpdateChannel switches between the stable and prerelease update channels.
+func (s *Service) SetUpdateChannel(channel string) (*State, error) {
+	if channel != channelStable && channel != channelPrerelease {
+		return nil, fmt.Errorf("unknown update channel %q", channel)
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.UpdateChannel = channel
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.invalidateLatest()
 	return s.State()
 }
 
-func (s *Service) downloadAndUnpack(tag string) error {
+// SkipVersion dismisses tag so it is no longer reported as an available update.
+func (s *Service) SkipVersion(tag string) (*State, error) {
+	tag = strings.TrimSpace(tag)
 	if tag == "" {
-		return errors.New("tag empty")
+		return nil, errors.New("tag empty")
 	}
-	if err := s.ensureDirs(); err != nil {
-		return err
+	err := s.updateConfig(func(cfg *Config) error {
+		if !containsString(cfg.SkippedVersions, tag) {
+			cfg.SkippedVersions = append(cfg.SkippedVersions, tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	targetDir := filepath.Join(s.releasesDir, tag)
-	if fi, err := os.Stat(targetDir); err == nil && fi.IsDir() {
-		return nil // already unpacked
+	return s.State()
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) latestCacheTTL() time.Duration {
+	if cfg, err := s.configSnapshot(); err == nil && cfg.LatestCheckMinutes > 0 {
+		return time.Duration(cfg.LatestCheckMinutes) * time.Minute
 	}
-	url := fmt.Sprintf(downloadTemplate, tag, tag)
-	req, err := http.NewRequest("GET", url, nil)
+	return defaultLatestCacheTTL
+}
+
+func (s *Service) fetchReleases(apiURL string) ([]githubRelease, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("User-Agent", "zapret-ui/1.0")
-	resp, err := (&http.Client{Timeout: 0}).Do(req)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// A 304 answer doesn't count against GitHub's unauthenticated rate limit.
+	conditional := s.etag != "" && s.etagURL == apiURL && len(s.releases) > 0
+	if conditional {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	resp, err := s.apiClient().Do(req)
 	if err != nil {
-		return err
+		return nil, s.explainNetErr(err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("download failed: %s", resp.Status)
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return s.releases, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 403/429 usually mean the unauthenticated rate limit was hit.
+		return nil, fmt.Errorf("github api: %s", resp.Status)
+	}
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, errors.New("github api: no releases")
 	}
-	buf, err := io.ReadAll(resp.Body)
+	s.etag = resp.Header.Get("ETag")
+	s.etagURL = apiURL
+	return releases, nil
+}
+
+// latestTagFromRedirect derives the latest tag from the Location header of the releases/latest redirect.
+func (s *Service) latestTagFromRedirect() (string, error) {
+	req, err := http.NewRequest("GET", repoLatestURL, nil)
 	if err != nil {
-		return err
+		return "", err
+	}
+	req.Header.Set("User-Agent", "zapret-ui/1.0")
+	resp, err := s.apiClient().Do(req)
+	if err != nil {
+		return "", s.explainNetErr(err)
+	}
+	defer resp.Body.Close()
+
+	// resp.Location resolves relative Location headers against the request URL.
+	loc, err := resp.Location()
+	if err != nil {
+		return "", &LatestVersionUnknownError{Reason: fmt.Sprintf("no redirect from releases page (%s)", resp.Status)}
+	}
+	// Expect .../releases/tag/<tag>; anything else is likely a proxy or captive portal page.
+	parts := strings.Split(strings.Trim(loc.Path, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] != "tag" {
+		return "", &LatestVersionUnknownError{Reason: fmt.Sprintf("unexpected redirect to %s", loc.Redacted())}
+	}
+	tag := parts[len(parts)-1]
+	if _, ok := parseVersion(tag); !ok {
+		return "", &LatestVersionUnknownError{Reason: fmt.Sprintf("%q doesn't look like a version", tag)}
 	}
-	return unzipBuffer(buf, targetDir)
+	return tag, nil
 }
 
-func unzipBuffer(data []byte, dest string) error {
-	br := bytes.NewReader(data)
-	zr, err := zip.NewReader(br, int64(len(data)))
+func (s *Service) CheckAndUpdate() (*State, error) {
+	cfg, err := s.configSnapshot()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, f := range zr.File {
-		fp := filepath.Join(dest, f.Name)
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(fp, f.Mode()); err != nil {
+	rel, err := s.latestRelease(true)
+	if err != nil {
+		return nil, err
+	}
+	latest := rel.TagName
+	if latest == "" || !s.isNewerRelease(latest, cfg.Version) {
+		return s.State()
+	}
+
+	ctx, done, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := s.downloadAndUnpack(ctx, latest); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, &UpdateCanceledError{Tag: latest, Err: err}
+		}
+		return nil, err
+	}
+	s.notify("Update downloaded", "zapret "+latest+" is ready")
+
+	// The running strategy uses files from the current tree; switching under it is deferred.
+	deferred := false
+	err = s.updateConfig(func(cfg *Config) error {
+		if cfg.Running != nil && cfg.Running.alive() {
+			cfg.PendingVersion = latest
+			deferred = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var changes []StrategyDiff
+	if cfg.Version != "" {
+		if changes, err = s.DiffRelease(cfg.Version, latest); err != nil {
+			s.logUpdate("comparing strategies of %s and %s: %v", cfg.Version, latest, err)
+		}
+	}
+	if deferred {
+		s.logUpdate("%s downloaded, waiting for the running strategy to stop", latest)
+		state, err := s.State()
+		if err != nil {
+			return nil, err
+		}
+		state.StrategyChanges = changes
+		return state, nil
+	}
+
+	migrated, err := s.applyVersion(latest)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.State()
+	if err != nil {
+		return nil, err
+	}
+	state.ListMigration = migrated
+	state.StrategyChanges = changes
+	return state, nil
+}
+
+// applyVersion makes tag the current release: user list entries are carried over from the
+// previous release, the config is saved, and old releases are pruned if configured.
+func (s *Service) applyVersion(tag string) ([]ListMigration, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	var migrated []ListMigration
+	if previous := cfg.Version; previous != "" && previous != tag {
+		migrated, err = mergeUserLists(filepath.Join(s.dirs().releasesDir, previous), filepath.Join(s.dirs().releasesDir, tag))
+		if err != nil {
+			s.logUpdate("migrating lists from %s to %s failed: %v", previous, tag, err)
+		}
+		for _, m := range migrated {
+			if m.Copied {
+				s.logUpdate("lists/%s copied from %s", m.File, previous)
+			} else {
+				s.logUpdate("lists/%s: %d user entries carried over from %s", m.File, len(m.AddedLines), previous)
+			}
+		}
+	}
+
+	err = s.updateConfig(func(cfg *Config) error {
+		cfg.Version = tag
+		if cfg.PendingVersion == tag {
+			cfg.PendingVersion = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.KeepReleases > 0 {
+		_, _ = s.PruneReleases(cfg.KeepReleases)
+	}
+	return migrated, nil
+}
+
+// applyPendingUpdate switches to a deferred update once nothing runs from the old release.
+func (s *Service) applyPendingUpdate() {
+	cfg, err := s.configSnapshot()
+	if err != nil || cfg.PendingVersion == "" {
+		return
+	}
+	tag := cfg.PendingVersion
+	if validateRelease(filepath.Join(s.dirs().releasesDir, tag)) != nil {
+		// The download vanished (pruned or deleted by hand); forget about it.
+		_ = s.updateConfig(func(cfg *Config) error {
+			cfg.PendingVersion = ""
+			return nil
+		})
+		return
+	}
+	if _, err := s.applyVersion(tag); err != nil {
+		s.logUpdate("applying pending update %s failed: %v", tag, err)
+		return
+	}
+	s.logUpdate("applied pending update %s", tag)
+}
+
+// ApplyPendingUpdate stops the running strategy so a deferred update takes effect, then
+// optionally starts the same strategy again from the new release.
+func (s *Service) ApplyPendingUpdate(restart bool) (*State, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PendingVersion == "" {
+		return s.State()
+	}
+	file := ""
+	if cfg.Running != nil {
+		file = cfg.Running.File
+	}
+	// StopRunning applies the pending version.
+	if err := s.StopRunning(); err != nil {
+		return nil, err
+	}
+	if restart && file != "" {
+		return s.RunStrategy(file, false)
+	}
+	return s.State()
+}
+
+// DownloadRelease downloads and unpacks tag without making it current, so it can be selected
+// later with SwitchRelease. A tag that is already unpacked and valid is left as is.
+func (s *Service) DownloadRelease(tag string) error {
+	if _, err := s.loadConfig(); err != nil {
+		return err
+	}
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag != filepath.Base(tag) {
+		return fmt.Errorf("invalid release tag %q", tag)
+	}
+	if validateRelease(filepath.Join(s.dirs().releasesDir, tag)) == nil {
+		return nil
+	}
+	if _, err := s.releaseByTag(tag); err != nil {
+		return err
+	}
+	ctx, done, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := s.downloadAndUnpack(ctx, tag); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return &UpdateCanceledError{Tag: tag, Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// releaseByTag looks tag up through the GitHub API (and API mirrors).
+// It returns *ReleaseNotFoundError when the API answers 404.
+func (s *Service) releaseByTag(tag string) (*githubRelease, error) {
+	var apiMirrors []string
+	if cfg, err := s.configSnapshot(); err == nil {
+		apiMirrors = cfg.APIMirrors
+	}
+	primary := fmt.Sprintf(repoAPITagURL, url.PathEscape(tag))
+	var err error
+	for _, u := range mirrorURLs(primary, githubAPIHost, apiMirrors) {
+		var rel *githubRelease
+		if rel, err = s.fetchRelease(u); err == nil {
+			return rel, nil
+		}
+		var nf *ReleaseNotFoundError
+		if errors.As(err, &nf) {
+			nf.Tag = tag
+			return nil, nf
+		}
+		s.logUpdate("release lookup for %s via %s failed: %v", tag, u, err)
+	}
+	return nil, err
+}
+
+func (s *Service) fetchRelease(apiURL string) (*githubRelease, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "zapret-ui/1.0")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.apiClient().Do(req)
+	if err != nil {
+		return nil, s.explainNetErr(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &ReleaseNotFoundError{}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github api: %s", resp.Status)
+	}
+	var rel githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+// beginUpdate returns a context canceled by CancelUpdate. Only one update may run at a time;
+// the returned func must be called when the update finishes.
+func (s *Service) beginUpdate() (context.Context, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	if s.updateCancel != nil {
+		cancel()
+		return nil, nil, errors.New("update already in progress")
+	}
+	s.updateCancel = cancel
+	return ctx, func() {
+		s.updateMu.Lock()
+		s.updateCancel = nil
+		s.updateMu.Unlock()
+		cancel()
+	}, nil
+}
+
+// CancelUpdate aborts an in-progress CheckAndUpdate, if any.
+func (s *Service) CancelUpdate() {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	if s.updateCancel != nil {
+		s.updateCancel()
+	}
+}
+
+func (s *Service) downloadAndUnpack(ctx context.Context, tag string) error {
+	if tag == "" {
+		return errors.New("tag empty")
+	}
+	if err := s.ensureDirs(); err != nil {
+		return err
+	}
+	targetDir := filepath.Join(s.dirs().releasesDir, tag)
+	if fi, err := os.Stat(targetDir); err == nil && fi.IsDir() {
+		if validateRelease(targetDir) == nil {
+			s.emitProgress(tag, "done", 0, 0)
+			return nil // already unpacked
+		}
+		// A broken directory (e.g. left by an older interrupted extraction) is replaced.
+		s.logUpdate("release %s is incomplete, re-extracting", tag)
+		if err := os.RemoveAll(targetDir); err != nil {
+			return err
+		}
+	}
+	partial, size, err := s.downloadArchive(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	s.emitProgress(tag, "unpacking", size, size)
+	if err := extractRelease(ctx, partial, targetDir); err != nil {
+		// Drop the archive since resuming a corrupt file would only fail again.
+		_ = os.Remove(partial)
+		return err
+	}
+	_ = os.Remove(partial)
+	s.emitProgress(tag, "done", size, size)
+	return nil
+}
+
+// checkDiskSpace fails early when the releases volume can't hold an archive of size bytes plus its
+// extracted copy. The check is skipped when the size isn't known.
+func (s *Service) checkDiskSpace(size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	free, err := freeDiskSpace(s.dirs().releasesDir)
+	if err != nil {
+		return nil
+	}
+	need := uint64(size) * diskSpaceFactor
+	if free < need {
+		return &InsufficientDiskSpaceError{Need: need, Free: free}
+	}
+	return nil
+}
+
+// GetStorageUsage reports the space used by releases and logs and what is left on the volume.
+func (s *Service) GetStorageUsage() (*StorageUsage, error) {
+	if err := s.ensureDirs(); err != nil {
+		return nil, err
+	}
+	usage := &StorageUsage{
+		ReleasesBytes: dirSize(s.dirs().releasesDir),
+		LogsBytes:     dirSize(s.dirs().logsDir),
+	}
+	if free, err := freeDiskSpace(s.dirs().releasesDir); err == nil {
+		usage.FreeBytes = free
+	}
+	return usage, nil
+}
+
+// downloadArchive downloads the zip for tag, trying the primary host and then each mirror,
+// and returns the path of the downloaded file with its size. The caller removes the file.
+func (s *Service) downloadArchive(ctx context.Context, tag string) (string, int64, error) {
+	asset, err := s.resolveAsset(tag)
+	if err != nil {
+		return "", 0, err
+	}
+	primary := fmt.Sprintf(downloadTemplate, tag, tag)
+	if asset != nil {
+		primary = asset.BrowserDownloadURL
+		if err := s.checkDiskSpace(asset.Size); err != nil {
+			return "", 0, err
+		}
+	}
+	var mirrors []string
+	if cfg, err := s.configSnapshot(); err == nil {
+		mirrors = cfg.Mirrors
+	}
+	// The partial file survives failed attempts (and app restarts) so the next try can resume it.
+	partial := filepath.Join(s.dirs().releasesDir, tag+".zip.partial")
+
+	var size int64
+sources:
+	for _, url := range mirrorURLs(primary, githubHost, mirrors) {
+		for attempt := 1; attempt <= downloadAttempts; attempt++ {
+			size, err = s.downloadToFile(ctx, url, partial, tag)
+			if err == nil {
+				s.logUpdate("downloaded %s from %s (%d bytes)", tag, url, size)
+				break sources
+			}
+			if ctx.Err() != nil {
+				break sources
+			}
+			s.logUpdate("download of %s from %s failed (attempt %d/%d): %v", tag, url, attempt, downloadAttempts, err)
+			if attempt < downloadAttempts {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(attempt*attempt) * time.Second):
+				}
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		// A canceled update starts from scratch next time.
+		_ = os.Remove(partial)
+		return "", 0, ctx.Err()
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return partial, size, nil
+}
+
+// extractRelease unpacks the archive file into targetDir via extractReleaseZip.
+func extractRelease(ctx context.Context, archive, targetDir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return extractReleaseZip(ctx, &zr.Reader, targetDir)
+}
+
+// extractReleaseZip unpacks zr into a staging directory next to targetDir, validates it,
+// and renames it into place so targetDir only ever exists fully populated.
+func extractReleaseZip(ctx context.Context, zr *zip.Reader, targetDir string) error {
+	staging := targetDir + stagingSuffix
+	_ = os.RemoveAll(staging)
+	if err := unzipReader(ctx, zr, staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	if err := validateRelease(staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	if err := os.Rename(staging, targetDir); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	return nil
+}
+
+// releaseManifest lists files every usable release must contain, relative to the release root.
+var releaseManifest = []string{
+	"bin/winws.exe",
+	"bin/WinDivert.dll",
+	"bin/WinDivert64.sys",
+	"utils/test zapret.ps1",
+}
+
+// validateRelease checks that dir looks like an unpacked zapret release.
+func validateRelease(dir string) error {
+	missing, err := missingReleaseFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid release %s: missing %s", filepath.Base(dir), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingReleaseFiles returns the manifest entries absent from dir, plus "general*.bat" when there are no strategies.
+func missingReleaseFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	hasStrategy := false
+	for _, e := range entries {
+		name := strings.ToLower(e.Name())
+		if !e.IsDir() && strings.HasPrefix(name, "general") && strings.HasSuffix(name, ".bat") {
+			hasStrategy = true
+			break
+		}
+	}
+	if !hasStrategy {
+		missing = append(missing, "general*.bat")
+	}
+	for _, f := range releaseManifest {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f))); err != nil {
+			missing = append(missing, f)
+		}
+	}
+	return missing, nil
+}
+
+// downloadToFile fetches url into path, resuming from the current size of path when the server supports it.
+// It returns the final size of the file.
+func (s *Service) downloadToFile(ctx context.Context, url, path, tag string) (int64, error) {
+	var offset int64
+	if fi, err := os.Stat(path); err == nil {
+		offset = fi.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "zapret-ui/1.0")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.downloadClient().Do(req)
+	if err != nil {
+		return 0, s.explainNetErr(err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Our partial file doesn't match what the server has; start over on the next attempt.
+		_ = os.Remove(path)
+		return 0, fmt.Errorf("download failed: %s", resp.Status)
+	case resp.StatusCode >= 400:
+		return 0, fmt.Errorf("download failed: %s", resp.Status)
+	default:
+		// Server ignored the Range header and sent the whole file.
+		offset = 0
+		flags |= os.O_TRUNC
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	s.emitProgress(tag, "downloading", offset, total)
+	body := &progressReader{
+		r:     resp.Body,
+		done:  offset,
+		total: total,
+		onUpdate: func(done, total int64) {
+			s.emitProgress(tag, "downloading", done, total)
+		},
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	size := offset + written
+	if total > 0 && size != total {
+		return 0, fmt.Errorf("download incomplete: got %d of %d bytes", size, total)
+	}
+	return size, nil
+}
+
+// SetMirrors persists the download and API mirror base URLs.
+func (s *Service) SetMirrors(mirrors, apiMirrors []string) (*State, error) {
+	for _, m := range append(append([]string{}, mirrors...), apiMirrors...) {
+		u, err := url.Parse(strings.TrimSpace(m))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror URL %q", m)
+		}
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.Mirrors = mirrors
+		cfg.APIMirrors = apiMirrors
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateLatest()
+	return s.State()
+}
+
+// mirrorURLs returns primary followed by the same URL rebased onto each mirror.
+func mirrorURLs(primary, host string, mirrors []string) []string {
+	urls := []string{primary}
+	for _, m := range mirrors {
+		m = strings.TrimRight(strings.TrimSpace(m), "/")
+		if m == "" {
+			continue
+		}
+		urls = append(urls, m+strings.TrimPrefix(primary, host))
+	}
+	return urls
+}
+
+// logUpdate appends a timestamped line to the update log.
+func (s *Service) logUpdate(format string, args ...interface{}) {
+	line := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	_ = appendFile(filepath.Join(s.dirs().logsDir, "update.log"), line)
+}
+
+// unzipReader extracts zr into dest, stopping early if ctx is canceled.
+func unzipReader(ctx context.Context, zr *zip.Reader, dest string) error {
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fp, err := safeZipPath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(fp, f.Mode()); err != nil {
 				return err
 			}
 			continue
@@ -352,20 +1785,164 @@ func unzipBuffer(data []byte, dest string) error {
 		if err != nil {
 			return err
 		}
-		out, err := os.OpenFile(fp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
-		if err != nil {
-			rc.Close()
-			return err
+		out, err := os.OpenFile(fp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
+		if err != nil {
+			rc.Close()
+			return err
+		}
+		if _, err := io.Copy(out, rc); err != nil {
+			out.Close()
+			rc.Close()
+			return err
+		}
+		out.Close()
+		rc.Close()
+	}
+	return nil
+}
+
+// safeZipPath resolves a zip entry name against dest and rejects entries that would escape it.
+func safeZipPath(dest, name string) (string, error) {
+	// Zip entries may use either separator regardless of the platform that created them.
+	norm := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(norm, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.Contains(norm, ":") {
+		return "", fmt.Errorf("zip entry %q: absolute paths are not allowed", name)
+	}
+	root := filepath.Clean(dest)
+	fp := filepath.Join(root, filepath.FromSlash(norm))
+	if fp != root && !strings.HasPrefix(fp, root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("zip entry %q: path escapes destination directory", name)
+	}
+	return fp, nil
+}
+
+// ListInstalledReleases returns every unpacked release, newest first.
+func (s *Service) ListInstalledReleases() ([]ReleaseInfo, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(s.dirs().releasesDir)
+	if err != nil {
+		return nil, err
+	}
+	var res []ReleaseInfo
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasSuffix(e.Name(), stagingSuffix) || strings.HasSuffix(e.Name(), backupSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(s.dirs().releasesDir, e.Name())
+		res = append(res, ReleaseInfo{
+			Tag:       e.Name(),
+			Path:      dir,
+			SizeBytes: dirSize(dir),
+			Current:   e.Name() == cfg.Version,
+			ModTime:   info.ModTime(),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ModTime.After(res[j].ModTime) })
+	return res, nil
+}
+
+// SwitchRelease makes an already unpacked release the current one.
+func (s *Service) SwitchRelease(tag string) (*State, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" || tag != filepath.Base(tag) {
+		return nil, fmt.Errorf("invalid release tag %q", tag)
+	}
+	dir := filepath.Join(s.dirs().releasesDir, tag)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("release %s is not installed: %w", tag, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("release %s is empty", tag)
+	}
+	if tag == cfg.Version {
+		return s.State()
+	}
+	// The running strategy was launched from the old release tree.
+	if cfg.Running != nil {
+		if err := s.StopRunning(); err != nil {
+			return nil, err
+		}
+	}
+	// Existing results are kept; State marks them stale since they were measured on another release.
+	err = s.updateConfig(func(cfg *Config) error {
+		cfg.Version = tag
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// PruneReleases deletes all but the keep most recent releases.
+// The current release and the one a running strategy was started from are never removed.
+func (s *Service) PruneReleases(keep int) (*PruneSummary, error) {
+	if keep < 1 {
+		return nil, errors.New("keep must be at least 1")
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	releases, err := s.ListInstalledReleases()
+	if err != nil {
+		return nil, err
+	}
+	summary := &PruneSummary{Deleted: []string{}}
+	for i, r := range releases {
+		if i < keep || r.Current || r.Tag == cfg.Version || r.Tag == cfg.PendingVersion {
+			continue
+		}
+		if cfg.Running != nil && r.Tag == cfg.Running.Release {
+			continue
 		}
-		if _, err := io.Copy(out, rc); err != nil {
-			out.Close()
-			rc.Close()
-			return err
+		if err := os.RemoveAll(r.Path); err != nil {
+			return summary, err
 		}
-		out.Close()
-		rc.Close()
+		summary.Deleted = append(summary.Deleted, r.Tag)
+		summary.ReclaimedBytes += r.SizeBytes
 	}
-	return nil
+	return summary, nil
+}
+
+// SetKeepReleases persists the automatic pruning setting.
+func (s *Service) SetKeepReleases(keep int) (*State, error) {
+	if keep < 0 {
+		return nil, errors.New("keep must not be negative")
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.KeepReleases = keep
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
 }
 
 func (s *Service) listStrategies() ([]Strategy, error) {
@@ -377,28 +1954,95 @@ func (s *Service) listStrategies() ([]Strategy, error) {
 	if err != nil {
 		return nil, err
 	}
+	custom := s.customFiles()
+	bundled := make(map[string]bool)
 	var res []Strategy
 	for _, e := range entries {
 		name := e.Name()
-		if e.IsDir() || strings.HasPrefix(strings.ToLower(name), "service") {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".bat") {
 			continue
 		}
-		if strings.HasSuffix(strings.ToLower(name), ".bat") && strings.HasPrefix(strings.ToLower(name), "general") {
-			res = append(res, Strategy{
-				Name: name,
-				File: filepath.Join(current, name),
-			})
+		file := filepath.Join(current, name)
+		if isStagedCopy(name, file, custom) {
+			continue
 		}
-	}
-	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+		bundled[strings.ToLower(name)] = true
+		res = append(res, Strategy{
+			Name:     name,
+			File:     file,
+			Category: strategyCategory(name),
+			Details:  parseStrategyFile(file, current),
+		})
+	}
+	for _, c := range custom {
+		name := c.name
+		if bundled[strings.ToLower(name)] {
+			name = customName(name)
+		}
+		res = append(res, Strategy{
+			Name:     name,
+			File:     c.path,
+			Category: strategyCategory(name),
+			Details:  parseStrategyFile(c.path, current),
+			Custom:   true,
+		})
+	}
+	version := winwsVersion(current)
+	for i := range res {
+		res[i].Problems = strategyProblems(res[i].Details, current, version)
+		res[i].Group, res[i].GameFilter = strategyGroup(res[i].Name)
+		res[i].RequiresAdmin = res[i].Details != nil
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if ci, cj := categoryOrder[res[i].Category], categoryOrder[res[j].Category]; ci != cj {
+			return ci < cj
+		}
+		return res[i].Name < res[j].Name
+	})
+	markDuplicates(res)
 	return res, nil
 }
 
+// RunTests executes the official test script for all configs (standard mode) and replaces the
+// stored results with its output.
 func (s *Service) RunTests() (*State, error) {
-	cfg, err := s.loadConfig()
+	return s.runTestsSync(TestOptions{})
+}
+
+// RunTestFor tests the general strategy name alone. Only its entry in TestResults is updated and
+// BestStrategy is recomputed over all results.
+func (s *Service) RunTestFor(name string) (*State, error) {
+	if name == "" {
+		return nil, errors.New("no strategy given")
+	}
+	return s.runTestsSync(TestOptions{Configs: []string{name}})
+}
+
+// runTests drives the test script in the mode opts selects, for every config or for opts.Configs.
+func (s *Service) runTests(opts TestOptions) (*State, error) {
+	if !s.testing.CompareAndSwap(false, true) {
+		return nil, &TestInProgressError{}
+	}
+	defer s.testing.Store(false)
+	runCtx, stop := context.WithCancel(context.Background())
+	defer s.trackTestRun(stop)()
+	if _, err := s.loadConfig(); err != nil {
+		return nil, err
+	}
+	opts, selected, err := s.resolveTestOptions(opts)
 	if err != nil {
 		return nil, err
 	}
+	if !opts.KeepRunning {
+		// Registered before the staging cleanup below, so a custom strategy is restarted after it.
+		restore, err := s.pauseRunningForTests(opts.RestoreBest)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
+	// Resolved after the pause: stopping the strategy applies a pending update, which can switch
+	// and prune the release.
 	current := s.currentReleasePath()
 	if current == "" {
 		return nil, errors.New("no current release")
@@ -407,37 +2051,98 @@ func (s *Service) RunTests() (*State, error) {
 	if _, err := os.Stat(ps1); err != nil {
 		return nil, err
 	}
-
-	// Remove old test results files to ensure only fresh output is parsed
+	if !opts.SkipDiagnostics {
+		// Checked once the running strategy is out of the way, so any winws left is someone else's.
+		report, err := s.RunDiagnostics()
+		if err != nil {
+			return nil, err
+		}
+		if !report.OK {
+			return nil, &DiagnosticsFailedError{Report: report}
+		}
+	}
 	resultsDir := filepath.Join(current, "utils", "test results")
-	_ = os.RemoveAll(resultsDir)
-	_ = os.MkdirAll(resultsDir, 0o755)
+	if len(selected) == 0 {
+		// Remove old test results files to ensure only fresh output is parsed
+		_ = os.RemoveAll(resultsDir)
+		_ = os.MkdirAll(resultsDir, 0o755)
+		// The test script only looks at the release folder.
+		defer s.stageCustomForTests(current)()
+	} else {
+		for _, st := range selected {
+			if !st.Custom {
+				continue
+			}
+			staged, err := stageCustom(st, current)
+			if err != nil {
+				return nil, err
+			}
+			defer os.Remove(staged)
+		}
+	}
+	answers, err := testAnswers(opts, current)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) > 0 {
+		// The previous run's results stay in place once this run is over.
+		if err := setAsideResults(current); err != nil {
+			return nil, err
+		}
+		defer restoreResults(current)
+	}
 
-	// Clear config and mark tests as in progress for the UI
-	cfg.TestResults = make(map[string]TestResult)
-	cfg.BestStrategy = ""
-	cfg.TestInProgress = true
-	cfg.LastTestAt = time.Now()
-	_ = s.saveConfig()
+	// Mark tests as in progress for the UI; a full run starts from a clean slate.
+	_ = s.updateConfig(func(cfg *Config) error {
+		if len(opts.Configs) == 0 {
+			cfg.TestResults = make(map[string]TestResult)
+			cfg.BestStrategy = ""
+		}
+		cfg.TestInProgress = true
+		cfg.TestTargets = opts.Configs
+		cfg.LastTestAt = time.Now()
+		return nil
+	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Minute)
+	timeout := s.testTimeout()
+	ctx, cancel := context.WithTimeout(runCtx, timeout)
 	defer cancel()
 
 	resultCh := make(chan *parsedResults, 1)
 	errCh := make(chan error, 1)
 	go s.waitForResultFile(ctx, current, resultCh, errCh)
 
-	// auto answers: 1 (standard), 1 (all configs)
-	input := bytes.NewBufferString("1\n1\n")
-
-	logFile := filepath.Join(s.logsDir, fmt.Sprintf("test_%d.log", time.Now().Unix()))
-	psCmd, psDone, startErr := startPowerShellToLog(ctx, current, ps1, input, logFile)
+	testStarted := time.Now()
+	rotateLogs(s.dirs().logsDir, testLogPrefix, maxLogsKept-1)
+	logFile := filepath.Join(s.dirs().logsDir, fmt.Sprintf("%s%d.log", testLogPrefix, testStarted.Unix()))
+	psCmd, stdin, psDone, startErr := startTestScript(ctx, current, ps1, logFile, s.hideProcesses())
+	promptErr := make(chan error, 1)
+	if startErr == nil {
+		pid := psCmd.Process.Pid
+		started, err := processStartTime(pid)
+		if err != nil {
+			started = time.Now()
+		}
+		_ = s.updateConfig(func(cfg *Config) error {
+			cfg.TestPID = pid
+			cfg.TestStartedAt = started
+			cfg.TestLog = logFile
+			return nil
+		})
+		go answerTestPrompts(ctx, stdin, logFile, opts, answers, promptErr)
+		go s.watchTestProgress(ctx, logFile, current, opts.Mode, opts.Configs, started)
+	}
 	if startErr != nil {
-		cfg.TestResults = make(map[string]TestResult)
-		cfg.BestStrategy = ""
-		cfg.TestInProgress = false
-		cfg.LastTestAt = time.Now()
-		_ = s.saveConfig()
+		_ = s.updateConfig(func(cfg *Config) error {
+			if len(opts.Configs) == 0 {
+				cfg.TestResults = make(map[string]TestResult)
+				cfg.BestStrategy = ""
+			}
+			cfg.TestInProgress = false
+			cfg.TestTargets = nil
+			cfg.LastTestAt = time.Now()
+			return nil
+		})
 		state, stateErr := s.State()
 		if stateErr != nil {
 			return nil, stateErr
@@ -464,12 +2169,21 @@ waitLoop:
 				killProcessTree(psCmd.Process.Pid)
 			}
 			break waitLoop
+		case watchErr = <-promptErr:
+			// Whatever the script would run with a wrong answer isn't what was asked for.
+			if psCmd != nil && psCmd.Process != nil {
+				killProcessTree(psCmd.Process.Pid)
+			}
+			break waitLoop
 		case cmdErr = <-psDone:
 			// PowerShell exited; if results haven't appeared yet we can still wait until ctx timeout,
 			// but usually this means the script failed early.
 			if cmdErr != nil {
-				// Give watcher a short chance to observe the result file.
-				time.Sleep(500 * time.Millisecond)
+				// Give watcher a chance to observe a result file written right before the exit.
+				select {
+				case parsed = <-resultCh:
+				case <-time.After(s.resultGrace()):
+				}
 				break waitLoop
 			}
 		case <-ctx.Done():
@@ -484,21 +2198,25 @@ waitLoop:
 		}
 	}
 
-	if parsed != nil {
-		cfg.TestResults = parsed.Results
-		cfg.BestStrategy = parsed.Best
-	} else {
-		cfg.TestResults = make(map[string]TestResult)
-		cfg.BestStrategy = ""
+	canceled := errors.Is(watchErr, context.Canceled) && runCtx.Err() != nil
+	s.killTestWinws(current, testStarted)
+	if canceled {
+		parsed = s.cancelTestCleanup(current)
+	} else if parsed != nil {
+		s.probeTestDomains(runCtx, current, parsed)
 	}
-	cfg.TestInProgress = false
-	cfg.LastTestAt = time.Now()
-	_ = s.saveConfig()
+	s.finishTests(parsed, opts.Configs)
 
 	state, stateErr := s.State()
 
 	// Bubble up the most relevant error while still returning state for the UI.
+	if canceled {
+		return state, &TestsCanceledError{Partial: parsed != nil}
+	}
 	if parsed == nil {
+		if errors.Is(watchErr, context.DeadlineExceeded) {
+			return state, &TestTimeoutError{Timeout: timeout}
+		}
 		if watchErr != nil && watchErr != context.Canceled {
 			return state, watchErr
 		}
@@ -511,13 +2229,162 @@ waitLoop:
 	if cmdErr != nil {
 		return state, cmdErr
 	}
+	for _, r := range parsed.Results {
+		if r.Mode != "" && r.Mode != opts.Mode {
+			return state, &TestModeMismatchError{Want: opts.Mode, Got: r.Mode}
+		}
+	}
+	if applied := s.applyBestAfterTests(opts.AutoApplyBest); applied != nil {
+		if state, stateErr = s.State(); state != nil {
+			state.AutoApplied = applied
+		}
+	}
 
 	return state, stateErr
 }
 
+// finishTests stores the outcome of a test run (nil when no results were produced), records it in
+// the test history and clears the in-progress markers. A run of some configs only updates their
+// entries.
+func (s *Service) finishTests(parsed *parsedResults, targets []string) {
+	now := time.Now()
+	if parsed != nil {
+		s.applyTestDurations(parsed.Results)
+	}
+	var run TestRun
+	_ = s.updateConfig(func(cfg *Config) error {
+		switch {
+		case len(targets) > 0:
+			tested := make(map[string]TestResult)
+			for _, name := range targets {
+				if r, ok := parsedResult(parsed, name); ok {
+					if cfg.TestResults == nil {
+						cfg.TestResults = make(map[string]TestResult)
+					}
+					cfg.TestResults[name] = r
+					tested[name] = r
+				}
+			}
+			cfg.BestStrategy = bestStrategy(cfg.TestResults, cfg.Settings.domainWeight())
+			run = newTestRun(tested, cfg.BestStrategy, now, true)
+		case parsed != nil:
+			cfg.TestResults = parsed.Results
+			cfg.BestStrategy = parsed.Best
+			run = newTestRun(parsed.Results, parsed.Best, now, false)
+		default:
+			cfg.TestResults = make(map[string]TestResult)
+			cfg.BestStrategy = ""
+		}
+		cfg.ParseWarnings = nil
+		if parsed != nil {
+			cfg.ParseWarnings = parsed.Warnings
+		}
+		cfg.TestInProgress = false
+		cfg.TestTargets = nil
+		cfg.TestPID = 0
+		cfg.TestStartedAt = time.Time{}
+		cfg.LastTestAt = now
+		return nil
+	})
+	s.recordTestRun(run)
+	if len(run.Results) > 0 {
+		s.recordRunChanges()
+	}
+}
+
+// reattachTests resumes watching a test run started by a previous app session whose PowerShell
+// process is still alive, instead of leaving it orphaned or starting another run. The final
+// state is emitted as "state:changed".
+func (s *Service) reattachTests() {
+	cfg, err := s.configSnapshot()
+	if err != nil || !cfg.TestInProgress || !processAlive(cfg.TestPID, cfg.TestStartedAt) {
+		return
+	}
+	current := s.currentReleasePath()
+	if current == "" || !s.testing.CompareAndSwap(false, true) {
+		return
+	}
+	pid := cfg.TestPID
+	jobID := ""
+	if cfg.TestJob != nil && cfg.TestJob.Status == testJobRunning {
+		jobID = cfg.TestJob.ID
+		s.testMu.Lock()
+		s.testJob = jobID
+		s.testMu.Unlock()
+	}
+	s.logUpdate("re-attached to test run pid %d started %s", pid, cfg.TestStartedAt.Format(time.RFC3339))
+	runCtx, stop := context.WithCancel(context.Background())
+	finish := s.trackTestRun(stop)
+	go func() {
+		defer s.testing.Store(false)
+		defer finish()
+		ctx, cancel := context.WithDeadline(runCtx, cfg.TestStartedAt.Add(s.testTimeout()))
+		defer cancel()
+		resultCh := make(chan *parsedResults, 1)
+		errCh := make(chan error, 1)
+		go s.waitForResultFile(ctx, current, resultCh, errCh)
+		if cfg.TestLog != "" {
+			mode := ""
+			if cfg.TestJob != nil {
+				mode = cfg.TestJob.Mode
+			}
+			go s.watchTestProgress(ctx, cfg.TestLog, current, mode, cfg.TestTargets, cfg.TestStartedAt)
+		}
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		var parsed *parsedResults
+	wait:
+		for {
+			select {
+			case parsed = <-resultCh:
+				break wait
+			case <-errCh:
+				break wait
+			case <-ticker.C:
+				if !processAlive(pid, cfg.TestStartedAt) {
+					// Give the watcher a moment to pick up a file written right before exit.
+					select {
+					case parsed = <-resultCh:
+					case <-time.After(s.resultGrace()):
+					}
+					break wait
+				}
+			}
+		}
+		// Same as RunTests: the script may sit in ReadKey after writing results.
+		if processAlive(pid, cfg.TestStartedAt) {
+			killProcessTree(pid)
+		}
+		s.killTestWinws(current, cfg.TestStartedAt)
+		if parsed == nil && runCtx.Err() != nil {
+			parsed = s.cancelTestCleanup(current)
+		}
+		s.finishTests(parsed, cfg.TestTargets)
+		if len(cfg.TestTargets) > 0 {
+			restoreResults(current)
+		}
+		if jobID != "" {
+			var jobErr error
+			switch {
+			case runCtx.Err() != nil:
+				jobErr = &TestsCanceledError{Partial: parsed != nil}
+			case parsed == nil:
+				jobErr = errors.New("the test run left no results")
+			}
+			s.finishTestJob(jobID, jobErr)
+		}
+		if state, err := s.State(); err == nil {
+			s.emit(eventState, state)
+		}
+	}()
+}
+
 type parsedResults struct {
 	Results map[string]TestResult
 	Best    string
+	// Warnings are about output parseAnalytics couldn't make sense of.
+	Warnings []string
 }
 
 func (s *Service) parseLatestResult(current string) (*parsedResults, error) {
@@ -549,55 +2416,110 @@ func (s *Service) parseLatestResult(current string) (*parsedResults, error) {
 	if err != nil {
 		return nil, err
 	}
-	return parseAnalytics(string(data))
+	parsed, err := parseAnalytics(string(data))
+	if err != nil {
+		return nil, err
+	}
+	// Results live inside the release tree, so the directory name is the version they were measured on.
+	version := filepath.Base(current)
+	for name, res := range parsed.Results {
+		res.Version = version
+		res.LastTestedAt = latestTime
+		parsed.Results[name] = res
+	}
+	return parsed, nil
 }
 
-// waitForResultFile polls the results directory until a test_results_*.txt file appears and can be parsed.
+// resultDirRetries is how many seconds in a row the results directory may be unreadable before
+// waitForResultFile gives up. The test script recreates it right after RunTests clears it.
+const resultDirRetries = 30
+
+// waitForResultFile polls the results directory every second until a test_results_*.txt file
+// appears, stops growing and can be parsed; see resultPoller.
 func (s *Service) waitForResultFile(ctx context.Context, current string, resultCh chan<- *parsedResults, errCh chan<- error) {
-	resultsDir := filepath.Join(current, "utils", "test results")
+	p := &resultPoller{svc: s, current: current}
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
-
 	for {
 		select {
 		case <-ctx.Done():
 			errCh <- ctx.Err()
 			return
 		case <-ticker.C:
-			entries, err := os.ReadDir(resultsDir)
-			if err != nil {
+			parsed, err := p.poll()
+			switch {
+			case err != nil:
 				errCh <- err
 				return
+			case parsed != nil:
+				resultCh <- parsed
+				return
 			}
-			hasResultFile := false
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				name := strings.ToLower(e.Name())
-				if strings.HasPrefix(name, "test_results_") && strings.HasSuffix(name, ".txt") {
-					hasResultFile = true
-					break
-				}
-			}
-			if !hasResultFile {
-				continue
-			}
-			parsed, err := s.parseLatestResult(current)
-			if err != nil {
-				// File may still be being written; keep waiting.
-				continue
-			}
-			resultCh <- parsed
-			return
 		}
 	}
 }
 
-func startPowerShellToLog(ctx context.Context, workdir, script string, input *bytes.Buffer, logFile string) (*exec.Cmd, <-chan error, error) {
+// resultPoller is what waitForResultFile keeps between polls of the results directory of the
+// release at current.
+type resultPoller struct {
+	svc        *Service
+	current    string
+	readErrors int
+	// seen is the result file as of the previous poll; it is parsed once it looks the same twice.
+	seen os.FileInfo
+}
+
+// poll looks at the results directory once. It returns the results once the newest result file
+// has settled, an error once the directory couldn't be read resultDirRetries times in a row, and
+// nil, nil while there is nothing to parse yet. Failures to read the directory are retried, since
+// the script may be recreating it.
+func (p *resultPoller) poll() (*parsedResults, error) {
+	entries, err := os.ReadDir(filepath.Join(p.current, "utils", "test results"))
+	if err != nil {
+		if p.readErrors++; p.readErrors >= resultDirRetries {
+			return nil, err
+		}
+		p.seen = nil
+		return nil, nil
+	}
+	p.readErrors = 0
+	var info os.FileInfo
+	for _, e := range entries {
+		name := strings.ToLower(e.Name())
+		if e.IsDir() || !strings.HasPrefix(name, "test_results_") || !strings.HasSuffix(name, ".txt") {
+			continue
+		}
+		if fi, err := e.Info(); err == nil && (info == nil || fi.ModTime().After(info.ModTime())) {
+			info = fi
+		}
+	}
+	if info == nil || info.Size() == 0 {
+		p.seen = nil
+		return nil, nil
+	}
+	stable := p.seen != nil && p.seen.Name() == info.Name() && p.seen.Size() == info.Size() && p.seen.ModTime().Equal(info.ModTime())
+	p.seen = info
+	if !stable {
+		// The script may still be writing it.
+		return nil, nil
+	}
+	parsed, err := p.svc.parseLatestResult(p.current)
+	if err != nil {
+		p.seen = nil
+		return nil, nil
+	}
+	return parsed, nil
+}
+
+// startTestScript starts the test script for runTests; tests replace it to run without PowerShell.
+var startTestScript = startPowerShellToLog
+
+// startPowerShellToLog starts script with its output going to logFile and returns a pipe to its
+// input, which the test script reads its menu answers from.
+func startPowerShellToLog(ctx context.Context, workdir, script, logFile string, hidden bool) (*exec.Cmd, io.WriteCloser, <-chan error, error) {
 	args := []string{"-NoProfile", "-ExecutionPolicy", "Bypass"}
-	if RUN_PROCESS_HIDDEN {
-		// Keep the process non-intrusive for users. For debugging, set RUN_PROCESS_HIDDEN=false.
+	if hidden {
+		// Keep the process non-intrusive for users (Settings.HideProcesses).
 		args = append(args, "-WindowStyle", "Hidden")
 	}
 	args = append(args, "-File", script)
@@ -607,23 +2529,24 @@ func startPowerShellToLog(ctx context.Context, workdir, script string, input *by
 	// but hide it by default so it doesn't bother users.
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		CreationFlags: createNewConsole,
-		HideWindow:    RUN_PROCESS_HIDDEN,
+		HideWindow:    hidden,
 	}
-	if input != nil {
-		cmd.Stdin = input
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	_ = os.MkdirAll(filepath.Dir(logFile), 0o755)
 	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	cmd.Stdout = f
 	cmd.Stderr = f
 
 	if err := cmd.Start(); err != nil {
 		_ = f.Close()
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	done := make(chan error, 1)
@@ -632,29 +2555,37 @@ func startPowerShellToLog(ctx context.Context, workdir, script string, input *by
 		_ = f.Close()
 	}()
 
-	return cmd, done, nil
+	return cmd, stdin, done, nil
 }
 
-func killProcessTree(pid int) {
-	if pid <= 0 {
-		return
-	}
-	_ = exec.Command("taskkill", "/PID", fmt.Sprintf("%d", pid), "/T", "/F").Run()
-}
+// maxParseWarnings bounds the ParseWarnings kept from one results file.
+const maxParseWarnings = 20
+
+// Metric keys of the per-config result lines, uppercased.
+var (
+	resultStdKeys = []string{"HTTP OK", "ERR", "UNSUP", "PING OK", "FAIL"}
+	resultDpiKeys = []string{"OK", "FAIL", "UNSUP", "BLOCKED"}
+)
 
+// parseAnalytics reads the per-config result lines of test script output in any of the encodings
+// decodeScriptOutput understands. Lines of the analytics section it can't read and metrics it
+// doesn't know are reported as warnings, so changes in the script's format show up.
 func parseAnalytics(content string) (*parsedResults, error) {
-	lines := strings.Split(content, "\n")
-	// inAnalytics := false
+	lines := strings.Split(decodeScriptOutput([]byte(content)), "\n")
+	inAnalytics := false
 	results := make(map[string]TestResult)
 	best := ""
-
-	reStd := regexp.MustCompile(`^(.*) : HTTP OK: (\d+), ERR: (\d+), UNSUP: (\d+), Ping OK: (\d+), Fail: (\d+)`)
-	reDpi := regexp.MustCompile(`^(.*) : OK: (\d+), FAIL: (\d+), UNSUP: (\d+), BLOCKED: (\d+)`)
+	var warnings []string
+	warn := func(w string) {
+		if len(warnings) < maxParseWarnings && !containsString(warnings, w) {
+			warnings = append(warnings, w)
+		}
+	}
 
 	for _, raw := range lines {
 		line := strings.TrimSpace(raw)
 		if line == "=== ANALYTICS ===" {
-			// inAnalytics = true
+			inAnalytics = true
 			continue
 		}
 		if strings.HasPrefix(line, "Best strategy:") {
@@ -664,46 +2595,88 @@ func parseAnalytics(content string) (*parsedResults, error) {
 		if line == "" {
 			continue
 		}
-		if m := reStd.FindStringSubmatch(line); len(m) == 7 {
-			name := strings.TrimSpace(m[1])
-			res := TestResult{
-				Name:       name,
-				HTTP_OK:    atoi(m[2]),
-				HTTP_ERR:   atoi(m[3]),
-				HTTP_UNSUP: atoi(m[4]),
-				PingOK:     atoi(m[5]),
-				PingFail:   atoi(m[6]),
-			}
-			if res.HTTP_ERR == 0 && res.PingFail == 0 {
-				res.Status = "ok"
-			} else {
-				res.Status = "fail"
+		res, unknown, ok := parseResultLine(line)
+		if !ok {
+			if inAnalytics {
+				warn(fmt.Sprintf("unrecognized analytics line: %q", line))
 			}
-			results[name] = res
 			continue
 		}
-		if m := reDpi.FindStringSubmatch(line); len(m) == 6 {
-			name := strings.TrimSpace(m[1])
-			res := TestResult{
-				Name:       name,
-				HTTP_OK:    atoi(m[2]),
-				Fail:       atoi(m[3]),
-				HTTP_UNSUP: atoi(m[4]),
-				Blocked:    atoi(m[5]),
-			}
-			if res.Fail == 0 && res.Blocked == 0 {
-				res.Status = "ok"
-			} else {
-				res.Status = "fail"
-			}
-			results[name] = res
-			continue
+		for _, k := range unknown {
+			warn(fmt.Sprintf("unknown %s result field %q", res.Mode, k))
 		}
+		results[res.Name] = res
 	}
 	if len(results) == 0 {
 		return nil, errors.New("no analytics parsed")
 	}
-	return &parsedResults{Results: results, Best: best}, nil
+	parseDomainResults(lines, results)
+	// The script names its own pick, but ranking is ours so that Best and the score order agree.
+	// The script's output carries no custom domain outcomes, so their weight doesn't matter here.
+	if b := bestStrategy(results, 0); b != "" {
+		best = b
+	}
+	return &parsedResults{Results: results, Best: best, Warnings: warnings}, nil
+}
+
+// parseResultLine parses a trimmed "<config> : KEY: n, KEY: n, ..." result line of either test
+// mode. Config names may contain " : " themselves, so the metrics start after the last one. Keys
+// that belong to neither mode are returned in unknown instead of failing the line.
+func parseResultLine(line string) (res TestResult, unknown []string, ok bool) {
+	i := strings.LastIndex(line, " : ")
+	if i <= 0 {
+		return TestResult{}, nil, false
+	}
+	metrics := make(map[string]int)
+	for _, part := range strings.Split(line[i+len(" : "):], ",") {
+		key, value, found := strings.Cut(part, ":")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || err != nil {
+			return TestResult{}, nil, false
+		}
+		metrics[strings.ToUpper(strings.Join(strings.Fields(key), " "))] = n
+	}
+	has := func(k string) bool {
+		_, ok := metrics[k]
+		return ok
+	}
+	res.Name = strings.TrimSpace(line[:i])
+	var known []string
+	switch {
+	case has("HTTP OK"):
+		res.Mode, known = testModeStandard, resultStdKeys
+		res.HTTP_OK = metrics["HTTP OK"]
+		res.HTTP_ERR = metrics["ERR"]
+		res.HTTP_UNSUP = metrics["UNSUP"]
+		res.PingOK = metrics["PING OK"]
+		res.PingFail = metrics["FAIL"]
+		res.Status = "ok"
+		if res.HTTP_ERR != 0 || res.PingFail != 0 {
+			res.Status = "fail"
+		}
+	case has("OK") && (has("FAIL") || has("BLOCKED")):
+		res.Mode, known = testModeDPI, resultDpiKeys
+		res.HTTP_OK = metrics["OK"]
+		res.Fail = metrics["FAIL"]
+		res.HTTP_UNSUP = metrics["UNSUP"]
+		res.Blocked = metrics["BLOCKED"]
+		res.Status = "ok"
+		if res.Fail != 0 || res.Blocked != 0 {
+			res.Status = "fail"
+		}
+	default:
+		return TestResult{}, nil, false
+	}
+	if res.Name == "" {
+		return TestResult{}, nil, false
+	}
+	for k := range metrics {
+		if !containsString(known, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return res, unknown, true
 }
 
 func atoi(s string) int {
@@ -829,13 +2802,37 @@ func appendFile(path, text string) error {
 	return err
 }
 
-func (s *Service) RunStrategy(file string) (*State, error) {
-	cfg, err := s.loadConfig()
+// RunStrategy stops the running strategy and starts file instead. Other DPI bypass tools found
+// running fail it with a ConflictError unless force is set; the zapret Windows service always does.
+func (s *Service) RunStrategy(file string, force bool) (*State, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.runStrategy(file, force)
+}
+
+// runStrategy is RunStrategy with runMu held.
+func (s *Service) runStrategy(file string, force bool) (*State, error) {
+	return s.launchStrategy(file, force, nil)
+}
+
+// launchStrategy starts file the way runStrategy does. prev is the run this one restarts, nil for
+// a new one: a restart is counted in RunStats.Restarts and RunningInfo.Restarts rather than as a
+// launch.
+func (s *Service) launchStrategy(file string, force bool, prev *RunningInfo) (*State, error) {
+	cfg, err := s.configSnapshot()
 	if err != nil {
 		return nil, err
 	}
+	if ws := queryWinService(nil); ws.Running {
+		return nil, &ServiceConflictError{Service: ws.Strategy}
+	}
+	if !force {
+		if c := blockingConflicts(s.detectConflicts(cfg, true)); len(c) > 0 {
+			return nil, &ConflictError{Conflicts: c}
+		}
+	}
 	// Stop previously running strategy if tracked
-	_ = s.StopRunning()
+	_, _ = s.stopRunning(false)
 
 	current := s.currentReleasePath()
 	if current == "" {
@@ -845,91 +2842,245 @@ func (s *Service) RunStrategy(file string) (*State, error) {
 	if !filepath.IsAbs(full) {
 		full = filepath.Join(current, file)
 	}
+	name := filepath.Base(full)
+	if st, err := s.strategyByName(name); err == nil && st.Custom {
+		full = st.File
+	}
 	if _, err := os.Stat(full); err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	// Launch in a visible console window via PowerShell Start-Process and capture PID.
-	windowStyle := "Normal"
-	if RUN_PROCESS_HIDDEN {
-		windowStyle = "Hidden"
-	}
-	psCmd := fmt.Sprintf("$p = Start-Process -FilePath %q -WorkingDirectory %q -WindowStyle %s -PassThru; Write-Output $p.Id", full, filepath.Dir(full), windowStyle)
-	args := []string{"-NoProfile"}
-	if RUN_PROCESS_HIDDEN {
-		args = append(args, "-WindowStyle", "Hidden")
+	if strategyCategory(name) == categoryService {
+		return nil, fmt.Errorf("%s installs the Windows service and can't be started as a strategy", name)
 	}
-	args = append(args, "-Command", psCmd)
-	cmd := exec.CommandContext(ctx, "powershell", args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: RUN_PROCESS_HIDDEN}
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
+	if !isElevated() && parseStrategyFile(full, current) != nil {
+		return nil, &AdminRequiredError{Strategy: name}
+	}
+	staged, err := stageRun(full, current)
+	if err != nil {
 		return nil, err
 	}
-	pid := atoi(strings.TrimSpace(buf.String()))
+	// The staged copy belongs to the run once it is recorded; any earlier return removes it.
+	keepStaged := false
+	defer func() {
+		if !keepStaged {
+			_ = os.Remove(staged)
+		}
+	}()
+	// Started directly, so the PID is cmd.Process's and the job below can hold the process.
+	cmd := batCommand(staged)
+	// winws reports why it can't start on its output, which would vanish with its console.
+	logPath, logFile, err := s.createStrategyLog(name)
+	if err != nil {
+		s.logUpdate("creating the output log of %s failed: %v", name, err)
+	} else {
+		cmd.Stdout, cmd.Stderr = logFile, logFile
+	}
+	var job *processJob
+	if !s.keepStrategyOnExit() {
+		var err error
+		if job, err = newProcessJob(); err != nil {
+			s.logUpdate("creating a job for %s failed, it will outlive the app: %v", name, err)
+		}
+	}
+	launched := time.Now()
+	err = startInJob(cmd, s.hideProcesses(), job)
+	if logFile != nil {
+		// The launcher has its own handle.
+		logFile.Close()
+	}
+	if err != nil {
+		if job != nil {
+			_ = job.Close()
+			job = nil
+		}
+		if cmd.Process == nil {
+			return nil, err
+		}
+		s.logUpdate("putting %s in a job failed, it will outlive the app: %v", name, err)
+	}
+	s.runJob = job
+	keepStaged = true
+	pid := cmd.Process.Pid
+	go func() { _ = cmd.Wait() }()
+	winwsPID := 0
 	if pid > 0 {
-		cfg.Running = &RunningInfo{
-			File:      filepath.Base(full),
-			PID:       pid,
-			StartedAt: time.Now(),
+		winwsPID = waitForWinws(pid, current, launched)
+	}
+	if winwsPID == 0 && logPath != "" && !isPIDRunning(pid) {
+		s.logUpdate("%s exited without starting winws.exe; see %s", name, logPath)
+	}
+	var run *RunningInfo
+	_ = s.updateConfig(func(cfg *Config) error {
+		if pid > 0 {
+			now := time.Now()
+			cfg.Running = &RunningInfo{
+				File:             name,
+				Release:          cfg.Version,
+				Staged:           staged,
+				PID:              pid,
+				WinwsPID:         winwsPID,
+				StartedAt:        now,
+				SessionStartedAt: now,
+				SeenAt:           now,
+				Log:              logPath,
+			}
+			if prev != nil {
+				recordRestart(cfg, cfg.Running.File)
+				cfg.Running.Restarts = prev.Restarts + 1
+				cfg.Running.SessionStartedAt = prev.sessionStart()
+			} else {
+				recordLaunch(cfg, cfg.Running.File, now)
+			}
+			r := *cfg.Running
+			run = &r
 		}
-		_ = s.saveConfig()
+		cfg.LastStrategy = name
+		cfg.Paused = nil
+		return nil
+	})
+	if run != nil {
+		s.watchRun(*run)
 	}
-
-	cfg.LastStrategy = filepath.Base(full)
-	_ = s.saveConfig()
 	return s.State()
 }
 
-// StopRunning terminates the tracked running process and all related processes.
-func (s *Service) StopRunning() error {
-	cfg, err := s.loadConfig()
-	if err != nil {
-		return err
+// winwsLookupTimeout bounds the wait for the winws.exe a launched strategy starts.
+const winwsLookupTimeout = 5 * time.Second
+
+// waitForWinws waits for the launcher pid, started at launched, to start a winws.exe from the
+// release folder current and returns its PID, 0 if none showed up in time.
+func waitForWinws(pid int, current string, launched time.Time) int {
+	deadline := time.Now().Add(winwsLookupTimeout)
+	for {
+		if winws := winwsDescendant(pid, current, launched); winws > 0 || time.Now().After(deadline) {
+			return winws
+		}
+		time.Sleep(250 * time.Millisecond)
 	}
+}
+
+// StopResult counts the processes a stop terminated, by how they were found.
+type StopResult struct {
+	// Tracked are the launcher and winws.exe of the running strategy.
+	Tracked int `json:"tracked"`
+	// Releases are other winws.exe instances started from the releases folder.
+	Releases int `json:"releases"`
+	// Other are winws.exe instances from anywhere else; only StopAllWinws stops them.
+	Other int `json:"other"`
+	// Graceful is set when the strategy's winws exited on Ctrl+C within Settings.StopGraceSeconds
+	// rather than being killed.
+	Graceful bool `json:"graceful"`
+}
 
-	// Aggressively kill all winws.exe processes using multiple methods
-	// Method 1: taskkill with tree kill (kills process and all children)
-	cmd1 := exec.Command("taskkill", "/IM", "winws.exe", "/T", "/F")
-	cmd1.Run() // Ignore errors, just try
+// StopRunning terminates the tracked running strategy and any winws.exe started from the releases
+// folder, leaving winws instances of the service installation or other tools alone.
+func (s *Service) StopRunning() error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	_, err := s.stopRunning(false)
+	return err
+}
 
-	// Method 2: PowerShell - more reliable, waits for completion
-	psScript := `
-$procs = Get-Process -Name winws -ErrorAction SilentlyContinue
-if ($procs) {
-    $procs | Stop-Process -Force -ErrorAction SilentlyContinue
-    Start-Sleep -Milliseconds 200
-    # Double-check and kill any remaining
-    $remaining = Get-Process -Name winws -ErrorAction SilentlyContinue
-    if ($remaining) {
-        $remaining | Stop-Process -Force -ErrorAction Stop
-    }
+// StopStrategy is StopRunning returning the new state, with what was terminated in Stopped.
+func (s *Service) StopStrategy() (*State, error) {
+	return s.stopWith(false)
 }
-`
-	cmd2 := exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", psScript)
-	cmd2.Run() // Ignore errors, just try
 
-	// Method 3: Also try wmic for additional reliability
-	cmd3 := exec.Command("wmic", "process", "where", "name='winws.exe'", "delete")
-	cmd3.Run() // Ignore errors
+// StopAllWinws stops the running strategy and every winws.exe on the system, whoever started it.
+func (s *Service) StopAllWinws() (*State, error) {
+	return s.stopWith(true)
+}
 
-	if cfg.Running != nil {
-		// Try to kill the tracked PID (might be cmd.exe or powershell.exe parent)
-		if isPIDRunning(cfg.Running.PID) {
-			// Use PowerShell Stop-Process for more reliable termination
-			_ = exec.Command("powershell", "-NoProfile", "-Command", fmt.Sprintf("Stop-Process -Id %d -Force -ErrorAction SilentlyContinue", cfg.Running.PID)).Run()
-			// Also try taskkill as fallback with tree kill
-			_ = exec.Command("taskkill", "/PID", fmt.Sprintf("%d", cfg.Running.PID), "/T", "/F").Run()
+func (s *Service) stopWith(all bool) (*State, error) {
+	s.runMu.Lock()
+	res, err := s.stopRunning(all)
+	if err == nil {
+		// A deliberate stop leaves nothing to resume.
+		err = s.updateConfig(func(cfg *Config) error {
+			cfg.Paused = nil
+			return nil
+		})
+	}
+	s.runMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.State()
+	if err != nil {
+		return nil, err
+	}
+	state.Stopped = &res
+	return state, nil
+}
+
+// stopRunning terminates the tracked strategy and the winws.exe instances inside the releases
+// folder, and with all every other winws.exe as well.
+func (s *Service) stopRunning(all bool) (StopResult, error) {
+	if s.runWatch != nil {
+		s.runWatch()
+		s.runWatch = nil
+	}
+	var res StopResult
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return res, err
+	}
+	if r := cfg.Running; r != nil {
+		end := r.SeenAt
+		if r.alive() {
+			end = time.Now()
+		}
+		// Ctrl+C lets winws close its WinDivert handle; killed, it can leave the driver stuck.
+		if r.WinwsPID > 0 && isProcessRunning(r.WinwsPID, "winws.exe") {
+			if interruptProcess(r.WinwsPID, cfg.Settings.stopGrace()) {
+				res.Graceful = true
+				res.Tracked++
+			} else if terminateProcess(r.WinwsPID) == nil {
+				res.Tracked++
+			}
+		}
+		// The launcher was created before the run was recorded; a later process has reused its PID.
+		if created, err := processStartTime(r.PID); err == nil && !created.After(r.StartedAt) {
+			res.Tracked += killProcessTree(r.PID)
+		}
+		if r.Staged != "" {
+			_ = os.Remove(r.Staged)
 		}
 
-		cfg.Running = nil
-		_ = s.saveConfig()
+		_ = s.updateConfig(func(cfg *Config) error {
+			recordRunEnd(cfg, cfg.Running, end)
+			cfg.Running = nil
+			return nil
+		})
+	}
+	// Instances the tracking lost, e.g. from a run recorded before a crash.
+	for _, pid := range winwsProcessesIn(s.dirs().releasesDir, time.Time{}) {
+		if terminateProcess(pid) == nil {
+			res.Releases++
+		}
+	}
+	if all {
+		res.Other = killAllWinws()
+	}
+	s.closeRunJob()
+	if cfg, err := s.configSnapshot(); err == nil {
+		s.syncKeepAwake(cfg)
 	}
 
-	return nil
+	s.applyPendingUpdate()
+	return res, nil
+}
+
+// killAllWinws kills every winws.exe process and returns how many it killed. Only StopAllWinws
+// does this; everything else stops the instances it started, see winwsProcessesIn.
+func killAllWinws() int {
+	killed := 0
+	for _, pid := range processesNamed("winws.exe") {
+		if terminateProcess(pid) == nil {
+			killed++
+		}
+	}
+	return killed
 }
 
 // StopAllRunning is called on shutdown to ensure cleanup.
@@ -973,14 +3124,15 @@ func copyFile(src, dst string, perm os.FileMode) error {
 	return err
 }
 
-// isPIDRunning checks if a process with given pid is alive.
-func isPIDRunning(pid int) bool {
+// processAlive reports whether pid still refers to the process created at startedAt, so a PID
+// reused by an unrelated process doesn't count.
+func processAlive(pid int, startedAt time.Time) bool {
 	if pid <= 0 {
 		return false
 	}
-	out, err := exec.Command("tasklist", "/FI", fmt.Sprintf("pid eq %d", pid)).CombinedOutput()
+	created, err := processStartTime(pid)
 	if err != nil {
 		return false
 	}
-	return strings.Contains(string(out), fmt.Sprintf("%d", pid))
+	return startedAt.IsZero() || created.Sub(startedAt).Abs() < time.Second
 }
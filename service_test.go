@@ -0,0 +1,402 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// helperProcessEnv makes the test binary act as the stand-in for the test script's PowerShell.
+const helperProcessEnv = "ZAPRET_UI_HELPER_PROCESS"
+
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv(helperProcessEnv) != "1" {
+		return
+	}
+	// Killed by runTests once the results file is parsed.
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// newTestService returns a service whose data folder is a temporary one holding release v1 with
+// the given general strategies; the release's other files are empty.
+func newTestService(t *testing.T, strategies ...string) (*Service, string) {
+	t.Helper()
+	s := &Service{}
+	s.client, s.dlClient = newHTTPClients(nil)
+	s.setPaths(t.TempDir())
+	if err := s.ensureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	current := filepath.Join(s.dirs().releasesDir, "v1")
+	if err := os.MkdirAll(filepath.Join(current, "utils"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range strategies {
+		if err := os.WriteFile(filepath.Join(current, name), []byte("@echo off\r\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(current, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range releaseManifest {
+		if err := os.WriteFile(filepath.Join(current, filepath.FromSlash(f)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &Config{Version: "v1"}
+	cfg.Settings.SilenceNotifications = true
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.dirs().configPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return s, current
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// stubTestScript replaces startTestScript with one that logs a result line per strategy and then
+// writes the results file, the way the test script does.
+func stubTestScript(t *testing.T, strategies []string) {
+	t.Helper()
+	orig := startTestScript
+	t.Cleanup(func() { startTestScript = orig })
+	startTestScript = func(ctx context.Context, workdir, script, logFile string, hidden bool) (*exec.Cmd, io.WriteCloser, <-chan error, error) {
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(os.Environ(), helperProcessEnv+"=1")
+		if err := cmd.Start(); err != nil {
+			return nil, nil, nil, err
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		go func() {
+			var lines []string
+			for i, name := range strategies {
+				line := fmt.Sprintf("%s : HTTP OK: %d, ERR: %d, UNSUP: 0, PING OK: 2, FAIL: 0", name, 4-i, i)
+				lines = append(lines, line)
+				_ = appendFile(logFile, "Testing "+name+"\n"+line+"\n")
+				time.Sleep(100 * time.Millisecond)
+			}
+			results := "=== ANALYTICS ===\n" + strings.Join(lines, "\n") + "\n"
+			_ = os.WriteFile(filepath.Join(workdir, "utils", "test results", "test_results_1.txt"), []byte(results), 0o644)
+		}()
+		return cmd, nopWriteCloser{io.Discard}, done, nil
+	}
+}
+
+// TestStateWhileTestsRun reads the state over and over while a test run records its results; run
+// it with -race.
+func TestStateWhileTestsRun(t *testing.T) {
+	strategies := []string{"general.bat", "general (ALT).bat", "general (FAKE TLS).bat"}
+	s, _ := newTestService(t, strategies...)
+	stubTestScript(t, strategies)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := s.State(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	state, err := s.RunTestsWith(TestOptions{SkipDiagnostics: true})
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Config.TestResults) != len(strategies) {
+		t.Fatalf("got %d results, want %d: %v", len(state.Config.TestResults), len(strategies), state.Config.TestResults)
+	}
+	if state.Config.BestStrategy != "general.bat" {
+		t.Errorf("best strategy = %q, want general.bat", state.Config.BestStrategy)
+	}
+	if state.Config.TestInProgress {
+		t.Error("test still marked in progress")
+	}
+}
+
+// releaseZip returns a zip of a minimal valid release plus the extra entries, with their
+// names stored as given.
+func releaseZip(t *testing.T, extra ...string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range append([]string{"general.bat", "bin/winws.exe", "bin/WinDivert.dll", "bin/WinDivert64.sys", "utils/test zapret.ps1"}, extra...) {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(f, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Entries like ../x make NewReader report ErrInsecurePath with GODEBUG=zipinsecurepath=0; the
+	// reader is usable either way, and checking them is extractReleaseZip's job.
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestExtractReleaseZipRejectsEscapingEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		ok    bool
+	}{
+		{"plain", "lists/list-general.txt", true},
+		{"backslashes", `lists\list-google.txt`, true},
+		{"dot segment inside", "lists/../lists/ipset-all.txt", true},
+		{"parent slash", "../evil.txt", false},
+		{"parent backslash", `..\evil.txt`, false},
+		{"nested parent slash", "bin/../../evil.txt", false},
+		{"nested parent backslash", `bin\..\..\evil.txt`, false},
+		{"absolute slash", "/evil.txt", false},
+		{"absolute backslash", `\evil.txt`, false},
+		{"drive letter", `C:\evil.txt`, false},
+		{"drive relative", "C:evil.txt", false},
+		{"unc", `\\server\share\evil.txt`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			target := filepath.Join(root, "releases", "v1")
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			err := extractReleaseZip(context.Background(), releaseZip(t, tt.entry), target)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("extracting %q: %v", tt.entry, err)
+				}
+				want := filepath.Join(target, filepath.FromSlash(strings.ReplaceAll(tt.entry, `\`, "/")))
+				if _, err := os.Stat(want); err != nil {
+					t.Errorf("%q not extracted: %v", tt.entry, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("extracting %q succeeded", tt.entry)
+			}
+			for _, dir := range []string{target, target + stagingSuffix} {
+				if _, err := os.Stat(dir); !os.IsNotExist(err) {
+					t.Errorf("%s left behind", dir)
+				}
+			}
+			for _, p := range []string{filepath.Join(root, "evil.txt"), filepath.Join(root, "releases", "evil.txt")} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("%s written outside the release", p)
+				}
+			}
+		})
+	}
+}
+
+func TestSafeZipPath(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "v1")
+	tests := []struct {
+		name string
+		want string // relative to dest, "" for an error
+	}{
+		{"general.bat", "general.bat"},
+		{"bin/winws.exe", filepath.Join("bin", "winws.exe")},
+		{`bin\winws.exe`, filepath.Join("bin", "winws.exe")},
+		{"./bin/winws.exe", filepath.Join("bin", "winws.exe")},
+		{"../v1evil/x", ""},
+		{`..\x`, ""},
+		{"/x", ""},
+		{`\x`, ""},
+		{`D:\x`, ""},
+		{"D:x", ""},
+	}
+	for _, tt := range tests {
+		got, err := safeZipPath(dest, tt.name)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("safeZipPath(%q) = %q, want an error", tt.name, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("safeZipPath(%q): %v", tt.name, err)
+		case tt.want != "" && got != filepath.Join(dest, tt.want):
+			t.Errorf("safeZipPath(%q) = %q, want %q", tt.name, got, filepath.Join(dest, tt.want))
+		}
+	}
+}
+
+func TestParseAnalyticsEncodings(t *testing.T) {
+	const alt = "general (Ростелеком).bat"
+	for _, name := range resultFixtures {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parseAnalytics(string(readFixture(t, name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parsed.Warnings) > 0 {
+				t.Errorf("warnings: %v", parsed.Warnings)
+			}
+			if len(parsed.Results) != 2 {
+				t.Fatalf("got %d results, want 2: %v", len(parsed.Results), parsed.Results)
+			}
+			r := parsed.Results["general.bat"]
+			if r.HTTP_OK != 5 || r.HTTP_ERR != 1 || r.PingOK != 3 || r.Status != "fail" {
+				t.Errorf("general.bat = %+v", r)
+			}
+			r = parsed.Results[alt]
+			if r.HTTP_OK != 6 || r.HTTP_ERR != 0 || r.PingOK != 3 || r.Status != "ok" {
+				t.Errorf("%s = %+v", alt, r)
+			}
+			if r.DomainResults["www.youtube.com"] != domainResultOK {
+				t.Errorf("%s domain results = %v", alt, r.DomainResults)
+			}
+			if parsed.Best != alt {
+				t.Errorf("best = %q, want %q", parsed.Best, alt)
+			}
+		})
+	}
+}
+
+const (
+	resultLineStd = "general.bat : HTTP OK: 5, ERR: 0, UNSUP: 0, PING OK: 3, FAIL: 0\n"
+	resultLineAlt = "general (ALT).bat : HTTP OK: 4, ERR: 1, UNSUP: 0, PING OK: 3, FAIL: 0\n"
+)
+
+// pending polls once and fails the test unless waitForResultFile would keep waiting.
+func pending(t *testing.T, p *resultPoller) {
+	t.Helper()
+	parsed, err := p.poll()
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if parsed != nil {
+		t.Fatalf("got results early: %v", parsed.Results)
+	}
+}
+
+func TestResultPollerWaitsForTheFileToSettle(t *testing.T) {
+	current := t.TempDir()
+	dir := filepath.Join(current, "utils", "test results")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "test_results_1.txt")
+	p := &resultPoller{svc: &Service{}, current: current}
+
+	pending(t, p) // no file yet
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p) // created but empty
+	pending(t, p)
+	if err := os.WriteFile(file, []byte("=== ANALYTICS ===\n"+resultLineStd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p) // first sight of the content
+	if err := appendFile(file, resultLineAlt); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p) // it grew since the last poll
+	parsed, err := p.poll()
+	if err != nil || parsed == nil {
+		t.Fatalf("poll of the settled file = %v, %v", parsed, err)
+	}
+	if len(parsed.Results) != 2 {
+		t.Errorf("parsed %d results, want the 2 of the finished file: %v", len(parsed.Results), parsed.Results)
+	}
+}
+
+func TestResultPollerSurvivesRecreatedDir(t *testing.T) {
+	current := t.TempDir()
+	dir := filepath.Join(current, "utils", "test results")
+	file := filepath.Join(dir, "test_results_1.txt")
+	p := &resultPoller{svc: &Service{}, current: current}
+
+	// The script recreates the folder RunTests cleared; until then it can't be read.
+	for i := 0; i < resultDirRetries-1; i++ {
+		pending(t, p)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("=== ANALYTICS ===\n"+resultLineStd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p)
+	// Gone again before it was parsed: the file seen before doesn't count once it is back.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("=== ANALYTICS ===\n"+resultLineStd+resultLineAlt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p)
+	parsed, err := p.poll()
+	if err != nil || parsed == nil {
+		t.Fatalf("poll of the recreated file = %v, %v", parsed, err)
+	}
+	if len(parsed.Results) != 2 {
+		t.Errorf("parsed %d results, want the 2 of the recreated file: %v", len(parsed.Results), parsed.Results)
+	}
+}
+
+func TestResultPollerGivesUpOnMissingDir(t *testing.T) {
+	current := t.TempDir()
+	dir := filepath.Join(current, "utils", "test results")
+	p := &resultPoller{svc: &Service{}, current: current}
+	for i := 0; i < resultDirRetries-1; i++ {
+		pending(t, p)
+	}
+	// A successful read starts the count over.
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pending(t, p)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < resultDirRetries-1; i++ {
+		pending(t, p)
+	}
+	parsed, err := p.poll()
+	if !os.IsNotExist(err) {
+		t.Errorf("poll = %v, %v; want a not-exist error after %d failed reads", parsed, err, resultDirRetries)
+	}
+}
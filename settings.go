@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultTestTimeoutMinutes bounds a whole test run unless Settings.TestTimeoutMinutes overrides it.
+	defaultTestTimeoutMinutes = 12
+	minTestTimeoutMinutes     = 5
+	maxTestTimeoutMinutes     = 60
+	maxUpdateCheckHours       = 7 * 24
+	// defaultResultGraceSeconds is how long to wait for the result file after the test script exits.
+	defaultResultGraceSeconds = 3
+	maxResultGraceSeconds     = 120
+	// defaultCustomDomainWeight is what a custom test domain counts for in a score unless
+	// Settings.CustomDomainWeight overrides it; a standard HTTP check counts 2.
+	defaultCustomDomainWeight = 4
+	maxCustomDomainWeight     = 20
+	maxFailoverCheckMinutes   = 24 * 60
+	maxFailoverFailures       = 20
+	maxFailoverSwitches       = 20
+	maxFailoverDwellMinutes   = 24 * 60
+	// defaultStopGraceSeconds is how long a strategy gets to exit on Ctrl+C before it is killed.
+	defaultStopGraceSeconds = 3
+	maxStopGraceSeconds     = 30
+)
+
+// Settings are the user preferences edited through UpdateSettings. Zero values mean the default.
+type Settings struct {
+	// HideProcesses launches PowerShell and strategy consoles hidden; applies to the next launch.
+	HideProcesses bool `json:"hideProcesses"`
+	// TestTimeoutMinutes bounds a whole test run; 0 means 12 minutes.
+	TestTimeoutMinutes int `json:"testTimeoutMinutes,omitempty"`
+	// ResultGraceSeconds is how long to wait for the result file after the test script exits; 0 means 3 seconds.
+	ResultGraceSeconds int `json:"resultGraceSeconds,omitempty"`
+	// UpdateCheckHours is the background update check interval; 0 means 6 hours.
+	UpdateCheckHours int `json:"updateCheckHours,omitempty"`
+	// AutoRunOnLaunch starts the best strategy (or the last used one) when the app launches,
+	// unless it was started with --no-autorun.
+	AutoRunOnLaunch bool `json:"autoRunOnLaunch"`
+	// CloseToTray hides the window instead of quitting when it is closed.
+	CloseToTray bool `json:"closeToTray"`
+	// CustomDomainWeight is how much each domain of Config.TestDomains counts for or against a
+	// strategy's score; 0 means 4, twice a standard HTTP check.
+	CustomDomainWeight int `json:"customDomainWeight,omitempty"`
+	// AutoApplyBest starts the best strategy after a test run if it passed its tests, replacing
+	// the running one.
+	AutoApplyBest bool `json:"autoApplyBest"`
+	// SilenceNotifications turns off the desktop notifications about finished test runs and
+	// downloaded updates. They are never shown while the app window has focus.
+	SilenceNotifications bool `json:"silenceNotifications"`
+	// Failover switches to the next best strategy when quick checks of the running one keep
+	// failing; see failoverWatchdog.
+	Failover bool `json:"failover"`
+	// FailoverCheckMinutes is how often the running strategy is quick-checked; 0 means 10 minutes.
+	FailoverCheckMinutes int `json:"failoverCheckMinutes,omitempty"`
+	// FailoverFailures is how many failed checks in a row trigger a switch; 0 means 3.
+	FailoverFailures int `json:"failoverFailures,omitempty"`
+	// FailoverMaxSwitches is how many switches in a row are tried before giving up until a check
+	// passes or another strategy is started; 0 means 3.
+	FailoverMaxSwitches int `json:"failoverMaxSwitches,omitempty"`
+	// FailoverDwellMinutes is how long a strategy runs at least before it is switched away from;
+	// 0 means 30 minutes.
+	FailoverDwellMinutes int `json:"failoverDwellMinutes,omitempty"`
+	// FailoverChain are the strategies to switch to, in order; empty means those that passed the
+	// tests, best first.
+	FailoverChain []string `json:"failoverChain,omitempty"`
+	// RestartOnCrash starts the running strategy again when its winws exits on its own; see
+	// awaitCrash.
+	RestartOnCrash bool `json:"restartOnCrash"`
+	// RestartAttempts is how many crashes in a row are restarted before giving up; 0 means 3.
+	RestartAttempts int `json:"restartAttempts,omitempty"`
+	// StopGraceSeconds is how long a stopped strategy gets to exit on Ctrl+C before it is killed;
+	// 0 means 3 seconds.
+	StopGraceSeconds int `json:"stopGraceSeconds,omitempty"`
+	// KeepStrategyOnExit leaves the running strategy running when the app exits or crashes. Otherwise
+	// it runs in a job object the system kills along with the app. Applies to the next launch.
+	KeepStrategyOnExit bool `json:"keepStrategyOnExit"`
+	// KeepAwake keeps the system from sleeping while a strategy runs.
+	KeepAwake bool `json:"keepAwake"`
+	// RestartOnResume restarts the running strategy after the system wakes from sleep; see
+	// watchPowerResume.
+	RestartOnResume bool `json:"restartOnResume"`
+	// SkipDuplicateTests leaves custom strategies that duplicate another one out of test runs.
+	SkipDuplicateTests bool `json:"skipDuplicateTests"`
+	// AutoStart registers the app to start minimized at logon. GetSettings reports the registry
+	// entry rather than the stored value, so an entry removed by hand shows up as disabled.
+	AutoStart bool `json:"autoStart"`
+}
+
+// validate checks each field against its allowed range.
+func (st Settings) validate() error {
+	if st.TestTimeoutMinutes != 0 && (st.TestTimeoutMinutes < minTestTimeoutMinutes || st.TestTimeoutMinutes > maxTestTimeoutMinutes) {
+		return fmt.Errorf("test timeout must be between %d and %d minutes", minTestTimeoutMinutes, maxTestTimeoutMinutes)
+	}
+	if st.ResultGraceSeconds < 0 || st.ResultGraceSeconds > maxResultGraceSeconds {
+		return fmt.Errorf("result wait must be between 1 and %d seconds", maxResultGraceSeconds)
+	}
+	if st.CustomDomainWeight < 0 || st.CustomDomainWeight > maxCustomDomainWeight {
+		return fmt.Errorf("custom domain weight must be between 1 and %d", maxCustomDomainWeight)
+	}
+	if st.FailoverCheckMinutes < 0 || st.FailoverCheckMinutes > maxFailoverCheckMinutes {
+		return fmt.Errorf("failover check interval must be between 1 and %d minutes", maxFailoverCheckMinutes)
+	}
+	if st.FailoverFailures < 0 || st.FailoverFailures > maxFailoverFailures {
+		return fmt.Errorf("failover threshold must be between 1 and %d failed checks", maxFailoverFailures)
+	}
+	if st.FailoverMaxSwitches < 0 || st.FailoverMaxSwitches > maxFailoverSwitches {
+		return fmt.Errorf("failover switches must be between 1 and %d", maxFailoverSwitches)
+	}
+	if st.FailoverDwellMinutes < 0 || st.FailoverDwellMinutes > maxFailoverDwellMinutes {
+		return fmt.Errorf("failover dwell time must be between 1 and %d minutes", maxFailoverDwellMinutes)
+	}
+	if st.RestartAttempts < 0 || st.RestartAttempts > maxRestartAttempts {
+		return fmt.Errorf("restart attempts must be between 1 and %d", maxRestartAttempts)
+	}
+	if st.StopGraceSeconds < 0 || st.StopGraceSeconds > maxStopGraceSeconds {
+		return fmt.Errorf("stop grace period must be between 1 and %d seconds", maxStopGraceSeconds)
+	}
+	if st.UpdateCheckHours < 0 || st.UpdateCheckHours > maxUpdateCheckHours {
+		return fmt.Errorf("update check interval must be between 1 and %d hours", maxUpdateCheckHours)
+	}
+	return nil
+}
+
+// GetSettings returns the current user preferences.
+func (s *Service) GetSettings() (*Settings, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	st := cfg.Settings
+	st.AutoStart = autoStartEnabled()
+	return &st, nil
+}
+
+// UpdateSettings validates and stores st. Changes apply to the next process launch, test run or
+// update check; no restart is needed.
+func (s *Service) UpdateSettings(st Settings) (*State, error) {
+	if err := st.validate(); err != nil {
+		return nil, err
+	}
+	if st.AutoStart != autoStartEnabled() {
+		if err := setAutoStart(st.AutoStart); err != nil {
+			return nil, fmt.Errorf("updating autostart: %w", err)
+		}
+	}
+	err := s.updateConfig(func(cfg *Config) error {
+		cfg.Settings = st
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// domainWeight is what each custom test domain counts for in a score.
+func (st Settings) domainWeight() int {
+	if st.CustomDomainWeight > 0 {
+		return st.CustomDomainWeight
+	}
+	return defaultCustomDomainWeight
+}
+
+// stopGrace is how long a stopped strategy gets to exit on its own.
+func (st Settings) stopGrace() time.Duration {
+	return time.Duration(orDefault(st.StopGraceSeconds, defaultStopGraceSeconds)) * time.Second
+}
+
+// testTimeout is how long a test run may take before it is killed.
+func (s *Service) testTimeout() time.Duration {
+	if cfg, err := s.configSnapshot(); err == nil && cfg.Settings.TestTimeoutMinutes > 0 {
+		return time.Duration(cfg.Settings.TestTimeoutMinutes) * time.Minute
+	}
+	return defaultTestTimeoutMinutes * time.Minute
+}
+
+// resultGrace is how long to wait for the result file once the test script has exited.
+func (s *Service) resultGrace() time.Duration {
+	if cfg, err := s.configSnapshot(); err == nil && cfg.Settings.ResultGraceSeconds > 0 {
+		return time.Duration(cfg.Settings.ResultGraceSeconds) * time.Second
+	}
+	return defaultResultGraceSeconds * time.Second
+}
+
+// closeToTray reports whether closing the window should hide it rather than quit.
+func (s *Service) closeToTray() bool {
+	cfg, err := s.configSnapshot()
+	return err == nil && cfg.Settings.CloseToTray
+}
+
+// keepStrategyOnExit reports whether the running strategy should outlive the app.
+func (s *Service) keepStrategyOnExit() bool {
+	cfg, err := s.configSnapshot()
+	return err == nil && cfg.Settings.KeepStrategyOnExit
+}
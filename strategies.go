@@ -0,0 +1,501 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxAliasLen = 64
+	maxNoteLen  = 2000
+	maxTags     = 20
+	maxTagLen   = 32
+)
+
+// tagRe matches a tag such as "voice-ok" or "isp:beeline".
+var tagRe = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}:._-]*$`)
+
+// Strategy categories, by file name.
+const (
+	categoryGeneral = "general"
+	categoryDiscord = "discord"
+	categoryUtil    = "util"
+	categoryService = "service"
+)
+
+// gameFilterRe matches the marker that names the game-filter variant of a strategy, as in
+// "general (ALT5) (game).bat" or "general_game_filter.bat", and captures the rest of the name.
+var gameFilterRe = regexp.MustCompile(`(?i)^(.+?)\s*(?:[(\[]\s*game[\s_-]*(?:filter)?\s*[)\]]|[\s_-]+game[\s_-]*(?:filter)?)$`)
+
+// categoryOrder is the order categories are listed in.
+var categoryOrder = map[string]int{categoryGeneral: 0, categoryDiscord: 1, categoryUtil: 2, categoryService: 3}
+
+// strategyCategory classifies a release .bat file by its name.
+func strategyCategory(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(lower, "general"):
+		return categoryGeneral
+	case strings.HasPrefix(lower, "service"):
+		return categoryService
+	case strings.Contains(lower, "discord"):
+		return categoryDiscord
+	}
+	return categoryUtil
+}
+
+// strategyGroup returns the group name of a strategy file, which its game-filter variant shares,
+// and whether the file is that variant.
+func strategyGroup(name string) (string, bool) {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if m := gameFilterRe.FindStringSubmatch(stem); m != nil {
+		return m[1], true
+	}
+	return stem, false
+}
+
+// StrategyUserMeta is what the user wrote about a strategy. It is keyed by the strategy file name,
+// so it carries over to new releases that ship the same file.
+type StrategyUserMeta struct {
+	Alias string   `json:"alias,omitempty"`
+	Note  string   `json:"note,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// empty reports whether the entry holds nothing and can be deleted.
+func (m StrategyUserMeta) empty() bool {
+	return m.Alias == "" && m.Note == "" && len(m.Tags) == 0
+}
+
+// SetStrategyMeta sets the display alias and note of the strategy file name. Empty values clear
+// them, which also works for strategies the current release no longer has.
+func (s *Service) SetStrategyMeta(name, alias, note string) (*State, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid strategy name %q", name)
+	}
+	alias, note = strings.TrimSpace(alias), strings.TrimSpace(note)
+	if utf8.RuneCountInString(alias) > maxAliasLen {
+		return nil, fmt.Errorf("alias is longer than %d characters", maxAliasLen)
+	}
+	if utf8.RuneCountInString(note) > maxNoteLen {
+		return nil, fmt.Errorf("note is longer than %d characters", maxNoteLen)
+	}
+	if alias != "" || note != "" {
+		if _, err := s.strategyByName(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.updateStrategyMeta(name, func(m *StrategyUserMeta) { m.Alias, m.Note = alias, note })
+}
+
+// SetStrategyTags replaces the tags of the strategy file name. Tags are lowercased and
+// deduplicated; an empty list removes them, which also works for strategies the current release
+// no longer has.
+func (s *Service) SetStrategyTags(name string, tags []string) (*State, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid strategy name %q", name)
+	}
+	clean, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(clean) > 0 {
+		if _, err := s.strategyByName(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.updateStrategyMeta(name, func(m *StrategyUserMeta) { m.Tags = clean })
+}
+
+// updateStrategyMeta applies edit to the StrategyMeta entry of name, dropping it once empty.
+func (s *Service) updateStrategyMeta(name string, edit func(*StrategyUserMeta)) (*State, error) {
+	err := s.updateConfig(func(cfg *Config) error {
+		meta := cfg.StrategyMeta[name]
+		edit(&meta)
+		if meta.empty() {
+			delete(cfg.StrategyMeta, name)
+			return nil
+		}
+		if cfg.StrategyMeta == nil {
+			cfg.StrategyMeta = make(map[string]StrategyUserMeta)
+		}
+		cfg.StrategyMeta[name] = meta
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
+
+// normalizeTags trims, lowercases, validates and deduplicates tags, keeping their order.
+func normalizeTags(tags []string) ([]string, error) {
+	var res []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || containsString(res, t) {
+			continue
+		}
+		if utf8.RuneCountInString(t) > maxTagLen || !tagRe.MatchString(t) {
+			return nil, fmt.Errorf("invalid tag %q: use letters, digits and : . _ - up to %d characters", t, maxTagLen)
+		}
+		res = append(res, t)
+	}
+	if len(res) > maxTags {
+		return nil, fmt.Errorf("a strategy can have at most %d tags", maxTags)
+	}
+	return res, nil
+}
+
+// hasTags reports whether tags contains any (or, with all set, every) tag in want.
+func hasTags(tags, want []string, all bool) bool {
+	for _, w := range want {
+		found := containsString(tags, strings.ToLower(strings.TrimSpace(w)))
+		if found && !all {
+			return true
+		}
+		if !found && all {
+			return false
+		}
+	}
+	return all
+}
+
+// strategyByName finds a strategy of the current release by file name.
+func (s *Service) strategyByName(name string) (*Strategy, error) {
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return nil, err
+	}
+	for i := range strategies {
+		if strings.EqualFold(strategies[i].Name, name) {
+			return &strategies[i], nil
+		}
+	}
+	return nil, errors.New("strategy " + name + " not found in the current release")
+}
+
+// decorateStrategies fills in results and user data from cfg, drops hidden strategies unless
+// includeHidden is set, and sorts favorites first.
+func decorateStrategies(cfg *Config, strategies []Strategy, includeHidden bool) []Strategy {
+	res := strategies[:0]
+	for _, st := range strategies {
+		if r, ok := cfg.TestResults[st.Name]; ok && st.Category == categoryGeneral {
+			st.Result = r
+			st.Score = scoreResult(r, cfg.Settings.domainWeight())
+			st.Stale = r.Version != "" && r.Version != cfg.Version
+		}
+		st.Best = cfg.BestStrategy != "" && cfg.BestStrategy == st.Name
+		if meta, ok := cfg.StrategyMeta[st.Name]; ok {
+			st.Alias, st.Note, st.Tags = meta.Alias, meta.Note, meta.Tags
+		}
+		if stats, ok := cfg.RunStats[st.Name]; ok {
+			st.Stats = &stats
+		}
+		st.Favorite = containsString(cfg.Favorites, st.Name)
+		st.Hidden = containsString(cfg.Hidden, st.Name)
+		if st.Hidden && !includeHidden {
+			continue
+		}
+		res = append(res, st)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Favorite && !res[j].Favorite })
+	return res
+}
+
+// Sort orders accepted by GetStrategies.
+const (
+	sortDefault    = ""
+	sortByName     = "name"
+	sortByScore    = "score"
+	sortByLastTest = "lastTested"
+)
+
+// scoreResult ranks a test result: working HTTP checks count double, pings once, and every
+// error, failure or block counts against it the same way. Each custom domain counts domainWeight
+// for or against it. Untested strategies score 0.
+func scoreResult(r TestResult, domainWeight int) int {
+	score := 2*r.HTTP_OK + r.PingOK - 2*(r.HTTP_ERR+r.Fail+r.Blocked) - r.PingFail
+	for _, outcome := range r.Domains {
+		if outcome == domainOK {
+			score += domainWeight
+		} else {
+			score -= domainWeight
+		}
+	}
+	return score
+}
+
+// bestStrategy returns the name with the highest score in results; ties go to the first name
+// alphabetically. It is "" when results is empty.
+func bestStrategy(results map[string]TestResult, domainWeight int) string {
+	best, bestScore := "", 0
+	for name, r := range results {
+		score := scoreResult(r, domainWeight)
+		if best == "" || score > bestScore || (score == bestScore && name < best) {
+			best, bestScore = name, score
+		}
+	}
+	return best
+}
+
+// rankedPassing returns the names in results that passed their tests, best score first; ties go
+// to the first name alphabetically.
+func rankedPassing(results map[string]TestResult, domainWeight int) []string {
+	var names []string
+	for name, r := range results {
+		if r.Status == "ok" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := scoreResult(results[names[i]], domainWeight), scoreResult(results[names[j]], domainWeight)
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// StrategyQuery selects and orders the strategies returned by GetStrategies.
+type StrategyQuery struct {
+	// SortBy is "name", "score" (best first) or "lastTested" (most recent first); "" keeps the
+	// usual order of favorites, then category and name.
+	SortBy        string `json:"sortBy,omitempty"`
+	IncludeHidden bool   `json:"includeHidden,omitempty"`
+	// Tags keeps strategies with any of the tags, or all of them when MatchAllTags is set.
+	Tags         []string `json:"tags,omitempty"`
+	MatchAllTags bool     `json:"matchAllTags,omitempty"`
+}
+
+// GetStrategies returns the strategies of the current release selected and ordered by q.
+func (s *Service) GetStrategies(q StrategyQuery) ([]Strategy, error) {
+	var less func(a, b *Strategy) bool
+	switch q.SortBy {
+	case sortDefault:
+	case sortByName:
+		less = func(a, b *Strategy) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case sortByScore:
+		// Untested strategies go last even when tested ones scored below zero.
+		less = func(a, b *Strategy) bool {
+			at, bt := a.Result.Status != "", b.Result.Status != ""
+			if at != bt {
+				return at
+			}
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			return a.Name < b.Name
+		}
+	case sortByLastTest:
+		less = func(a, b *Strategy) bool { return a.Result.LastTestedAt.After(b.Result.LastTestedAt) }
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", q.SortBy)
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return nil, err
+	}
+	res := decorateStrategies(cfg, strategies, q.IncludeHidden)
+	if len(q.Tags) > 0 {
+		kept := res[:0]
+		for _, st := range res {
+			if hasTags(st.Tags, q.Tags, q.MatchAllTags) {
+				kept = append(kept, st)
+			}
+		}
+		res = kept
+	}
+	if less != nil {
+		sort.SliceStable(res, func(i, j int) bool { return less(&res[i], &res[j]) })
+	}
+	return res, nil
+}
+
+// maxStrategyContentSize caps what GetStrategyContent reads; real strategy scripts are a few KB.
+const maxStrategyContentSize = 1 << 20
+
+// StrategyContent is the text of a strategy script as returned by GetStrategyContent.
+type StrategyContent struct {
+	Name string `json:"name"`
+	// Path is the absolute path of the script.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Encoding is what the file was stored in: "utf-8", "utf-8-bom" or "cp866".
+	Encoding string `json:"encoding"`
+	Text     string `json:"text"`
+}
+
+// GetStrategyContent returns the script of a bundled or custom strategy for display. Only files
+// inside the current release and the custom folder are read.
+func (s *Service) GetStrategyContent(name string) (*StrategyContent, error) {
+	st, err := s.strategyByName(name)
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(st.File)
+	if err != nil {
+		return nil, err
+	}
+	inside := func(dir string) bool {
+		if dir == "" {
+			return false
+		}
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != "." && !strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel)
+	}
+	if !inside(s.currentReleasePath()) && !inside(s.dirs().customDir) {
+		return nil, fmt.Errorf("%s is outside the release and custom folders", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxStrategyContentSize {
+		return nil, fmt.Errorf("%s is too large to display (%d bytes)", st.Name, info.Size())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text, enc := decodeBatText(data)
+	return &StrategyContent{
+		Name:     st.Name,
+		Path:     path,
+		Size:     info.Size(),
+		Encoding: enc,
+		Text:     strings.ReplaceAll(text, "\r\n", "\n"),
+	}, nil
+}
+
+// RunStrategyGroup starts the strategy of group, or its game-filter variant if gameFilter is set;
+// force is passed on to RunStrategy.
+func (s *Service) RunStrategyGroup(group string, gameFilter, force bool) (*State, error) {
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range strategies {
+		if strings.EqualFold(st.Group, group) && st.GameFilter == gameFilter {
+			return s.RunStrategy(st.Name, force)
+		}
+	}
+	if gameFilter {
+		return nil, fmt.Errorf("%s has no game filter variant", group)
+	}
+	return nil, fmt.Errorf("strategy group %s not found in the current release", group)
+}
+
+// markDuplicates fingerprints each strategy that starts winws and points DuplicateOf at the first
+// strategy with the same fingerprint, preferring bundled ones so a custom copy is the duplicate.
+func markDuplicates(strategies []Strategy) {
+	first := make(map[string]string)
+	for _, custom := range []bool{false, true} {
+		for i := range strategies {
+			st := &strategies[i]
+			if st.Custom != custom || st.Details == nil {
+				continue
+			}
+			st.Fingerprint = st.Details.fingerprint()
+			if orig, ok := first[st.Fingerprint]; ok {
+				st.DuplicateOf = orig
+			} else {
+				first[st.Fingerprint] = st.Name
+			}
+		}
+	}
+}
+
+// ValidateStrategy checks that the files the strategy name references exist and returns what is
+// missing.
+func (s *Service) ValidateStrategy(name string) ([]string, error) {
+	st, err := s.strategyByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.Problems == nil {
+		return []string{}, nil
+	}
+	return st.Problems, nil
+}
+
+// strategyProblems stats the files in d and describes the missing ones, relative to the release
+// folder current where possible, followed by options the release's winws version doesn't support.
+func strategyProblems(d *StrategyDetails, current, version string) []string {
+	if d == nil {
+		return nil
+	}
+	res := unsupportedWinwsFlags(d.Raw, version)
+	for _, f := range d.Files {
+		path := f
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(current, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if rel, err := filepath.Rel(current, path); err == nil && !strings.HasPrefix(rel, "..") {
+			f = rel
+		}
+		res = append(res, "missing "+f)
+	}
+	return res
+}
+
+// ToggleFavorite pins or unpins the strategy file name.
+func (s *Service) ToggleFavorite(name string) (*State, error) {
+	return s.toggleStrategyFlag(name, func(cfg *Config) *[]string { return &cfg.Favorites })
+}
+
+// ToggleHidden hides or shows the strategy file name in the strategy list.
+func (s *Service) ToggleHidden(name string) (*State, error) {
+	return s.toggleStrategyFlag(name, func(cfg *Config) *[]string { return &cfg.Hidden })
+}
+
+// toggleStrategyFlag adds name to or removes it from the list selected by field. Removing works
+// for strategies the current release no longer has.
+func (s *Service) toggleStrategyFlag(name string, field func(cfg *Config) *[]string) (*State, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid strategy name %q", name)
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(*field(cfg), name) {
+		st, err := s.strategyByName(name)
+		if err != nil {
+			return nil, err
+		}
+		name = st.Name
+	}
+	err = s.updateConfig(func(cfg *Config) error {
+		list := field(cfg)
+		for i, v := range *list {
+			if v == name {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				return nil
+			}
+		}
+		*list = append(*list, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.State()
+}
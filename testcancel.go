@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// cancelTestsWait bounds how long CancelTests waits for the run to clean up.
+const cancelTestsWait = 30 * time.Second
+
+// Outcomes of a canceled test run, as reported in State.TestCanceled.
+const (
+	testCanceledPartial = "partial"
+	testCanceledNone    = "none"
+)
+
+// CancelTests stops the test run in progress: the PowerShell process tree and any winws it started
+// are killed, results written so far are kept, and the in-progress markers are cleared.
+func (s *Service) CancelTests() (*State, error) {
+	s.testMu.Lock()
+	cancel, done := s.testCancel, s.testDone
+	s.testMu.Unlock()
+	if cancel == nil {
+		return nil, errors.New("no test run in progress")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(cancelTestsWait):
+		return nil, errors.New("the test run did not stop in time")
+	}
+	state, err := s.State()
+	if err != nil {
+		return nil, err
+	}
+	s.testMu.Lock()
+	state.TestCanceled = s.testCanceled
+	s.testMu.Unlock()
+	return state, nil
+}
+
+// trackTestRun makes cancel the way CancelTests stops the current run. The returned func must be
+// called once the run has finished cleaning up.
+func (s *Service) trackTestRun(cancel context.CancelFunc) func() {
+	done := make(chan struct{})
+	s.testMu.Lock()
+	s.testCancel, s.testDone, s.testCanceled = cancel, done, ""
+	s.testMu.Unlock()
+	return func() {
+		cancel()
+		s.testMu.Lock()
+		s.testCancel = nil
+		s.testMu.Unlock()
+		close(done)
+	}
+}
+
+// cancelTestCleanup returns whatever results the canceled test script had written to the release
+// at current. The winws instances it left running are stopped by killTestWinws beforehand.
+func (s *Service) cancelTestCleanup(current string) *parsedResults {
+	parsed, err := s.parseLatestResult(current)
+	if err != nil {
+		parsed = nil
+	}
+	s.markTestCanceled(parsed != nil)
+	return parsed
+}
+
+// markTestCanceled records for CancelTests whether the canceled run kept partial results.
+func (s *Service) markTestCanceled(partial bool) {
+	outcome := testCanceledNone
+	if partial {
+		outcome = testCanceledPartial
+	}
+	s.testMu.Lock()
+	s.testCanceled = outcome
+	s.testMu.Unlock()
+	s.logUpdate("test run canceled, results kept: %s", outcome)
+}
+
+// killTestWinws stops the winws instances started from the release at current since started,
+// which a killed test script leaves behind, and waits until they are gone. A strategy the user
+// started before the test run is older and left alone.
+func (s *Service) killTestWinws(current string, started time.Time) {
+	if current == "" || started.IsZero() {
+		return
+	}
+	pids := winwsProcessesIn(current, started)
+	if len(pids) == 0 {
+		return
+	}
+	for _, pid := range pids {
+		_ = terminateProcess(pid)
+	}
+	left := winwsProcessesIn(current, started)
+	for deadline := time.Now().Add(winwsStopWait); len(left) > 0 && time.Now().Before(deadline); left = winwsProcessesIn(current, started) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	if len(left) > 0 {
+		s.logUpdate("winws left by the test run is still running: pid %v", left)
+		return
+	}
+	s.logUpdate("stopped %d winws process(es) left by the test run", len(pids))
+}
+
+// cleanupStaleTest stops the winws instances of a test run the previous session left behind
+// when its PowerShell process is gone.
+func (s *Service) cleanupStaleTest() {
+	cfg, err := s.configSnapshot()
+	if err != nil || !cfg.TestInProgress || processAlive(cfg.TestPID, cfg.TestStartedAt) {
+		return
+	}
+	s.killTestWinws(s.currentReleasePath(), cfg.TestStartedAt)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// eventTestJob carries the TestJob when a test run starts and when it ends.
+const eventTestJob = "test:job"
+
+// Statuses of a TestJob.
+const (
+	testJobRunning   = "running"
+	testJobSucceeded = "succeeded"
+	testJobFailed    = "failed"
+	testJobCanceled  = "canceled"
+)
+
+// TestJob is a test script run started by StartTests or one of the blocking test methods.
+type TestJob struct {
+	ID      string   `json:"id"`
+	Status  string   `json:"status"`
+	Mode    string   `json:"mode"`
+	Configs []string `json:"configs,omitempty"`
+	// StartedAt and FinishedAt bound the job; FinishedAt is zero while it runs.
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Elapsed is the job's duration so far in seconds.
+	Elapsed int    `json:"elapsed"`
+	Error   string `json:"error,omitempty"`
+	// Diagnostics is the report that kept the job from starting the script.
+	Diagnostics *DiagnosticsReport `json:"diagnostics,omitempty"`
+}
+
+// StartTests checks opts and starts the test script in the background. Progress and results
+// arrive as test:progress and test:result events, the outcome as a test:job event followed by
+// the new state.
+func (s *Service) StartTests(opts TestOptions) (*TestJob, error) {
+	job, err := s.newTestJob(opts)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if state, _ := s.runTestJob(job.ID, opts); state != nil {
+			s.emit(eventState, state)
+		}
+	}()
+	return job, nil
+}
+
+// GetTestJob returns the running or most recent test job, nil if there has been none.
+func (s *Service) GetTestJob() (*TestJob, error) {
+	cfg, err := s.configSnapshot()
+	if err != nil || cfg.TestJob == nil {
+		return nil, err
+	}
+	job := *cfg.TestJob
+	if job.Status == testJobRunning {
+		job.Elapsed = int(time.Since(job.StartedAt).Seconds())
+	}
+	return &job, nil
+}
+
+// runTestsSync runs the test script as a job and waits for it; it backs the blocking test methods.
+func (s *Service) runTestsSync(opts TestOptions) (*State, error) {
+	job, err := s.newTestJob(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.runTestJob(job.ID, opts)
+}
+
+// newTestJob validates opts and records a running job for them. Only one job exists at a time.
+func (s *Service) newTestJob(opts TestOptions) (*TestJob, error) {
+	if s.testing.Load() {
+		return nil, &TestInProgressError{}
+	}
+	current := s.currentReleasePath()
+	if current == "" {
+		return nil, errors.New("no current release")
+	}
+	if _, err := os.Stat(filepath.Join(current, "utils", "test zapret.ps1")); err != nil {
+		return nil, err
+	}
+	opts, _, err := s.resolveTestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &TestJob{ID: hex.EncodeToString(id), Status: testJobRunning, Mode: opts.Mode, Configs: opts.Configs, StartedAt: time.Now()}
+
+	s.testMu.Lock()
+	if s.testJob != "" {
+		s.testMu.Unlock()
+		return nil, &TestInProgressError{}
+	}
+	s.testJob = job.ID
+	s.testMu.Unlock()
+	err = s.updateConfig(func(cfg *Config) error {
+		j := *job
+		cfg.TestJob = &j
+		return nil
+	})
+	if err != nil {
+		s.testMu.Lock()
+		s.testJob = ""
+		s.testMu.Unlock()
+		return nil, err
+	}
+	s.emit(eventTestJob, job)
+	return job, nil
+}
+
+// runTestJob runs the test script for the job id and records how it ended.
+func (s *Service) runTestJob(id string, opts TestOptions) (*State, error) {
+	state, err := s.runTests(opts)
+	s.finishTestJob(id, err)
+	return state, err
+}
+
+// finishTestJob records the outcome of the job id, err being what its run returned.
+func (s *Service) finishTestJob(id string, err error) {
+	var job *TestJob
+	_ = s.updateConfig(func(cfg *Config) error {
+		if cfg.TestJob == nil || cfg.TestJob.ID != id {
+			return nil
+		}
+		j := cfg.TestJob
+		var canceled *TestsCanceledError
+		var diag *DiagnosticsFailedError
+		if errors.As(err, &diag) {
+			j.Diagnostics = &diag.Report
+		}
+		switch {
+		case errors.As(err, &canceled):
+			j.Status = testJobCanceled
+		case err != nil:
+			j.Status, j.Error = testJobFailed, err.Error()
+		default:
+			j.Status = testJobSucceeded
+		}
+		j.FinishedAt = time.Now()
+		j.Elapsed = int(j.FinishedAt.Sub(j.StartedAt).Seconds())
+		c := *j
+		job = &c
+		return nil
+	})
+	s.testMu.Lock()
+	if s.testJob == id {
+		s.testJob = ""
+	}
+	s.testMu.Unlock()
+	if job != nil {
+		s.emit(eventTestJob, job)
+		s.notifyTestsDone(err)
+	}
+}
+
+// failOrphanedTestJob marks a job the previous session left running as failed unless
+// reattachTests picked up its run.
+func (s *Service) failOrphanedTestJob() {
+	s.testMu.Lock()
+	owned := s.testJob
+	s.testMu.Unlock()
+	_ = s.updateConfig(func(cfg *Config) error {
+		j := cfg.TestJob
+		if j == nil || j.Status != testJobRunning || j.ID == owned {
+			return nil
+		}
+		j.Status, j.Error = testJobFailed, "the app exited during the test run"
+		// The job ended at the latest when the session that ran it did.
+		j.FinishedAt = time.Now()
+		j.Elapsed = int(j.FinishedAt.Sub(j.StartedAt).Seconds())
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// defaultTestLogLines is what GetTestLogTail returns when no line count is given.
+	defaultTestLogLines = 200
+	maxTestLogLines     = 5000
+	// maxTestLogTailBytes bounds how much of the end of a log readLogTail reads.
+	maxTestLogTailBytes = 1 << 20
+)
+
+// GetTestLogTail returns the last lines of the log of the running or most recent test run,
+// decoded from whatever encoding PowerShell wrote it in. It is "" when there is no log, for
+// example after the logs folder was cleared.
+func (s *Service) GetTestLogTail(lines int) (string, error) {
+	if lines <= 0 {
+		lines = defaultTestLogLines
+	}
+	lines = min(lines, maxTestLogLines)
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return "", err
+	}
+	if cfg.TestLog == "" {
+		return "", nil
+	}
+	return readLogTail(cfg.TestLog, lines)
+}
+
+// readLogTail returns the last lines of the log at path, decoding UTF-16 by its byte order mark
+// and anything else as script output; "" when the file doesn't exist.
+func readLogTail(path string, lines int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	head := make([]byte, 2)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	var order binary.ByteOrder
+	switch {
+	case bytes.Equal(head, []byte{0xff, 0xfe}):
+		order = binary.LittleEndian
+	case bytes.Equal(head, []byte{0xfe, 0xff}):
+		order = binary.BigEndian
+	}
+	offset := max(info.Size()-maxTestLogTailBytes, 0)
+	if order != nil {
+		// Stay on a UTF-16 code unit boundary, after the byte order mark.
+		offset = max(offset&^1, 2)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	if order != nil {
+		text = strings.ReplaceAll(decodeUTF16(data, order), "\r\n", "\n")
+	} else {
+		text = decodeScriptOutput(data)
+	}
+	all := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if offset > 2 || (order == nil && offset > 0) {
+		// The first line was cut where reading started.
+		all = all[1:]
+	}
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n"), nil
+}
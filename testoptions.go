@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Test modes of the test script, as recorded in TestResult.Mode.
+const (
+	// testModeStandard checks HTTP and ping reachability of the target sites.
+	testModeStandard = "standard"
+	// testModeDPI runs the curl-based DPI checkers.
+	testModeDPI = "dpi"
+)
+
+const (
+	// testPromptIdle is how long the script must be silent after printing a menu before the menu is
+	// taken to be waiting for an answer.
+	testPromptIdle = time.Second
+	// testPromptWait is how long the script may wait for input without showing a menu we recognise
+	// before the expected answer is sent anyway.
+	testPromptWait = 15 * time.Second
+)
+
+// testMenuOptionRe matches a numbered menu entry such as "[2] DPI checkers" or "2) All configs".
+var testMenuOptionRe = regexp.MustCompile(`^(?:\[(\d{1,3})\]|(\d{1,3})[).])\s*(.+)$`)
+
+// TestOptions selects what RunTestsWith runs.
+type TestOptions struct {
+	// Mode is "standard" or "dpi"; "" means standard.
+	Mode string `json:"mode"`
+	// Configs are the general strategies to test, all of them when empty. A run of some configs
+	// only replaces their results.
+	Configs []string `json:"configs,omitempty"`
+	// AutoApplyBest starts the best strategy once the run is over, as Settings.AutoApplyBest does.
+	AutoApplyBest bool `json:"autoApplyBest,omitempty"`
+	// KeepRunning leaves a running strategy alone during the run. By default it is stopped, since
+	// it would interfere with the script's winws, and started again afterwards.
+	KeepRunning bool `json:"keepRunning,omitempty"`
+	// RestoreBest starts the best strategy of the run afterwards instead of the one that was
+	// stopped, if it passed its tests.
+	RestoreBest bool `json:"restoreBest,omitempty"`
+	// SkipDiagnostics starts the script without checking its prerequisites first; see
+	// RunDiagnostics.
+	SkipDiagnostics bool `json:"skipDiagnostics,omitempty"`
+}
+
+// TestModeMismatchError is returned when the test script ran a different mode than requested,
+// which means its menus changed in a way the answers didn't account for.
+type TestModeMismatchError struct {
+	Want, Got string
+}
+
+func (e *TestModeMismatchError) Error() string {
+	return fmt.Sprintf("the test script ran %s tests instead of %s tests", e.Got, e.Want)
+}
+
+// RunTestsWith runs the test script in the mode and for the configs opts selects.
+func (s *Service) RunTestsWith(opts TestOptions) (*State, error) {
+	return s.runTestsSync(opts)
+}
+
+// resolveTestOptions checks opts and returns them with the default mode filled in and the configs
+// replaced by the strategies they name.
+func (s *Service) resolveTestOptions(opts TestOptions) (TestOptions, []*Strategy, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = testModeStandard
+	case testModeStandard, testModeDPI:
+	default:
+		return opts, nil, fmt.Errorf("unknown test mode %q", opts.Mode)
+	}
+	var configs []string
+	var strategies []*Strategy
+	for _, name := range opts.Configs {
+		st, err := s.strategyByName(name)
+		if err != nil {
+			return opts, nil, err
+		}
+		if st.Category != categoryGeneral {
+			return opts, nil, fmt.Errorf("only general strategies can be tested, %s is %s", st.Name, st.Category)
+		}
+		if !containsString(configs, st.Name) {
+			configs = append(configs, st.Name)
+			strategies = append(strategies, st)
+		}
+	}
+	opts.Configs = configs
+	return opts, strategies, nil
+}
+
+// pauseRunningForTests stops the strategy the user has running, if any, and returns a func that
+// starts it again once the run is over, or the run's best strategy when restoreBest is set. The
+// func does nothing if a strategy was started in the meantime, as AutoApplyBest does.
+func (s *Service) pauseRunningForTests(restoreBest bool) (func(), error) {
+	cfg, err := s.configSnapshot()
+	if err != nil || cfg.Running == nil || !cfg.Running.alive() {
+		return func() {}, err
+	}
+	prev := cfg.Running.File
+	s.logUpdate("stopping %s for the test run", prev)
+	if err := s.StopRunning(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cfg, err := s.configSnapshot()
+		if err != nil || (cfg.Running != nil && cfg.Running.alive()) {
+			return
+		}
+		name := prev
+		if r, ok := cfg.TestResults[cfg.BestStrategy]; restoreBest && ok && r.Status == "ok" {
+			if _, err := s.strategyByName(cfg.BestStrategy); err == nil {
+				name = cfg.BestStrategy
+			}
+		}
+		state, err := s.RunStrategy(name, false)
+		if err != nil {
+			s.logUpdate("restarting %s after the test run: %v", name, err)
+			return
+		}
+		s.emit(eventState, state)
+	}, nil
+}
+
+// testAnswers are the menu answers for opts in the order the test script has asked so far: the
+// mode, all or selected configs, and the selected configs' numbers in the list at current.
+func testAnswers(opts TestOptions, current string) ([]string, error) {
+	mode := "1"
+	if opts.Mode == testModeDPI {
+		mode = "2"
+	}
+	if len(opts.Configs) == 0 {
+		return []string{mode, "1"}, nil
+	}
+	numbers := make([]string, 0, len(opts.Configs))
+	for _, name := range opts.Configs {
+		i, err := testConfigIndex(current, name)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, strconv.Itoa(i))
+	}
+	return []string{mode, "2", strings.Join(numbers, ",")}, nil
+}
+
+// testPrompter answers the test script's menus by what their entries say rather than by position,
+// so a reordered menu doesn't silently select the wrong mode.
+type testPrompter struct {
+	opts TestOptions
+	// expected are the answers by position, sent when a menu can't be recognised.
+	expected []string
+	answered int
+	tail     logTail
+	// menu holds the entries printed since the last answer, by number.
+	menu map[int]string
+}
+
+// answerTestPrompts follows logFile and writes the answers for opts to stdin until every expected
+// menu has been answered. Menus it can't make sense of get the expected answer; a menu of configs
+// that lacks a requested one is reported on errCh.
+func answerTestPrompts(ctx context.Context, stdin io.WriteCloser, logFile string, opts TestOptions, expected []string, errCh chan<- error) {
+	p := &testPrompter{opts: opts, expected: expected, menu: make(map[int]string)}
+	lastOutput := time.Now()
+	ticker := time.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+	for p.answered < len(p.expected) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lines, partial := p.tail.read(logFile)
+		if len(lines) > 0 || partial != "" {
+			lastOutput = time.Now()
+		}
+		for _, l := range lines {
+			if m := testMenuOptionRe.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+				p.menu[atoi(m[1]+m[2])] = strings.ToLower(strings.TrimSpace(m[3]))
+			}
+		}
+		// Read-Host leaves its prompt without a line break; otherwise silence means it is waiting.
+		waiting := strings.TrimSpace(partial) != "" || time.Since(lastOutput) >= testPromptIdle
+		var answer string
+		switch {
+		case len(p.menu) > 0 && waiting:
+			a, err := p.choose()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if answer = a; answer == "" {
+				answer = p.expected[p.answered]
+			}
+		case time.Since(lastOutput) >= testPromptWait:
+			answer = p.expected[p.answered]
+		default:
+			continue
+		}
+		if _, err := io.WriteString(stdin, answer+"\n"); err != nil {
+			errCh <- fmt.Errorf("answering the test script: %w", err)
+			return
+		}
+		p.answered++
+		p.menu = make(map[int]string)
+		lastOutput = time.Now()
+	}
+}
+
+// choose picks the answer to the menu on screen from the entries' text, or returns "" when it
+// doesn't recognise the menu.
+func (p *testPrompter) choose() (string, error) {
+	has := func(words ...string) int {
+		for n, text := range p.menu {
+			for _, w := range words {
+				if strings.Contains(text, w) {
+					return n
+				}
+			}
+		}
+		return 0
+	}
+	other := func(not int) int {
+		if len(p.menu) != 2 {
+			return 0
+		}
+		for n := range p.menu {
+			if n != not {
+				return n
+			}
+		}
+		return 0
+	}
+
+	// The menu of configs lists the .bat files themselves.
+	if has(".bat") != 0 {
+		var numbers []string
+		for _, name := range p.opts.Configs {
+			n := 0
+			for k, text := range p.menu {
+				// An exact match beats a longer name that contains this one.
+				if matchesConfig(text, name) || (n == 0 && strings.Contains(text, strings.ToLower(name))) {
+					n = k
+				}
+			}
+			if n == 0 {
+				return "", fmt.Errorf("the test script doesn't offer %s", name)
+			}
+			numbers = append(numbers, strconv.Itoa(n))
+		}
+		if len(numbers) == 0 {
+			return "", errors.New("the test script asks for configs to test but all were requested")
+		}
+		return strings.Join(numbers, ","), nil
+	}
+	if dpi := has("dpi"); dpi != 0 {
+		n := dpi
+		if p.opts.Mode == testModeStandard {
+			if n = has("standard", "стандарт"); n == 0 {
+				n = other(dpi)
+			}
+		}
+		if n != 0 {
+			return strconv.Itoa(n), nil
+		}
+	}
+	if all := has("all", "все"); all != 0 {
+		n := all
+		if len(p.opts.Configs) > 0 {
+			if n = has("select", "выбр", "выбор"); n == 0 {
+				n = other(all)
+			}
+		}
+		if n != 0 {
+			return strconv.Itoa(n), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// testProgressInterval is how often the test log is read while a run is underway.
+	testProgressInterval = 2 * time.Second
+	// eventTestProgress is the Wails event name carrying TestProgress payloads.
+	eventTestProgress = "test:progress"
+	// eventTestResult carries the TestResult of a config as soon as the test log reports it.
+	eventTestResult = "test:result"
+)
+
+// TestProgress reports how far a test run has got.
+type TestProgress struct {
+	// Current is the config being tested, or "" before the first one starts and after the last one.
+	Current        string `json:"current"`
+	CompletedCount int    `json:"completedCount"`
+	Total          int    `json:"total"`
+	// Elapsed is the time since the run started, in seconds.
+	Elapsed int `json:"elapsed"`
+	// Remaining is the estimated time left in seconds, from how long configs took so far or in
+	// earlier runs; 0 when there is no estimate.
+	Remaining int `json:"remaining,omitempty"`
+}
+
+// testProgress follows the log of a test run over the configs in names.
+type testProgress struct {
+	names []string
+	// current is the index in names of the config being tested, -1 before the first one.
+	current int
+	// results are the configs the log has reported results for, by file name.
+	results map[string]TestResult
+	tail    logTail
+	// currentSince is when the current config started; durations are those of the configs that
+	// are done, by file name.
+	currentSince time.Time
+	durations    map[string]time.Duration
+	// typical is how long a config took in earlier runs, for estimates before one is done.
+	typical time.Duration
+}
+
+// watchTestProgress tails logFile until ctx is done, emitting eventTestProgress every
+// testProgressInterval and eventTestResult for each config result the log reports. targets are
+// the configs a partial run tests, none for a full run. How long each config took is handed to
+// finishTests through setTestDurations.
+func (s *Service) watchTestProgress(ctx context.Context, logFile, current, mode string, targets []string, started time.Time) {
+	names := targets
+	if len(names) == 0 {
+		var err error
+		if names, err = testConfigNames(current); err != nil {
+			return
+		}
+	}
+	p := &testProgress{names: names, current: -1, results: make(map[string]TestResult), durations: make(map[string]time.Duration)}
+	p.typical = s.typicalTestDuration(mode)
+	s.setTestDurations(nil)
+	version := filepath.Base(current)
+	ticker := time.NewTicker(testProgressInterval)
+	defer ticker.Stop()
+	for {
+		done := len(p.durations)
+		for _, r := range p.read(logFile) {
+			r.Version = version
+			r.LastTestedAt = time.Now()
+			if d, ok := p.durations[r.Name]; ok {
+				r.DurationMs = d.Milliseconds()
+			}
+			s.emit(eventTestResult, r)
+		}
+		if len(p.durations) != done {
+			s.setTestDurations(p.durations)
+		}
+		s.emit(eventTestProgress, p.progress(started))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// read consumes what was appended to the log since the last call and returns the new results.
+func (p *testProgress) read(logFile string) []TestResult {
+	lines, _ := p.tail.read(logFile)
+	var res []TestResult
+	for _, l := range lines {
+		if r, ok := p.line(strings.TrimSpace(l)); ok {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// logTail reads a log file that is still being written.
+type logTail struct {
+	offset int64
+	// partial is the last line of the log while it is still being written.
+	partial []byte
+}
+
+// read returns the lines appended to the log at path since the last call, and the unterminated
+// line at its end, which is returned again with the rest of it once it is complete.
+func (t *logTail) read(path string) ([]string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ""
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, ""
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, ""
+	}
+	t.offset += int64(len(data))
+	t.partial = append(t.partial, data...)
+	var lines []string
+	if end := bytes.LastIndexByte(t.partial, '\n'); end >= 0 {
+		for _, raw := range bytes.Split(t.partial[:end], []byte("\n")) {
+			lines = append(lines, decodeLogLine(raw))
+		}
+		t.partial = append([]byte(nil), t.partial[end+1:]...)
+	}
+	return lines, decodeLogLine(t.partial)
+}
+
+// decodeLogLine converts a line of PowerShell output, which is in the console code page (cp866 on
+// Russian systems), to a Go string.
+func decodeLogLine(raw []byte) string {
+	text, _ := decodeBatText(raw)
+	return strings.TrimSuffix(text, "\r")
+}
+
+// line interprets one log line: a result line records the result of its config, any other line
+// naming the file of a config later than the current one moves the run on to it. The script tests
+// configs in name order, so earlier names mentioned in passing are ignored; when one file name
+// contains another, as "my general.bat" contains "general.bat", the longest match wins. A config
+// is timed from when the run moves on to it until the next one starts or its result shows up.
+func (p *testProgress) line(line string) (TestResult, bool) {
+	if r, _, ok := parseResultLine(line); ok {
+		for _, name := range p.names {
+			if _, seen := p.results[name]; !seen && matchesConfig(r.Name, name) {
+				if p.current >= 0 && p.names[p.current] == name {
+					p.finishCurrent()
+				}
+				r.Name = name
+				p.results[name] = r
+				return r, true
+			}
+		}
+		return TestResult{}, false
+	}
+	lower := strings.ToLower(line)
+	best, bestLen := -1, 0
+	for i := p.current + 1; i < len(p.names); i++ {
+		if n := strings.ToLower(p.names[i]); len(n) > bestLen && strings.Contains(lower, n) {
+			best, bestLen = i, len(n)
+		}
+	}
+	if best >= 0 {
+		p.finishCurrent()
+		p.current, p.currentSince = best, time.Now()
+	}
+	return TestResult{}, false
+}
+
+// finishCurrent records how long the current config took, unless that is known already.
+func (p *testProgress) finishCurrent() {
+	if p.current < 0 {
+		return
+	}
+	if name := p.names[p.current]; p.durations[name] == 0 {
+		p.durations[name] = max(time.Since(p.currentSince), time.Millisecond)
+	}
+}
+
+// progress summarises the log read so far.
+func (p *testProgress) progress(started time.Time) TestProgress {
+	// Configs before the current one are done even if their results are only printed at the end.
+	done := min(max(p.current, len(p.results)), len(p.names))
+	res := TestProgress{CompletedCount: done, Total: len(p.names), Elapsed: int(time.Since(started).Seconds())}
+	var inCurrent time.Duration
+	if p.current >= 0 && done < len(p.names) {
+		name := p.names[p.current]
+		if _, finished := p.results[name]; !finished {
+			res.Current = name
+			inCurrent = time.Since(p.currentSince)
+		}
+	}
+	var measured []time.Duration
+	for _, d := range p.durations {
+		measured = append(measured, d)
+	}
+	res.Remaining = estimateRemaining(measured, p.typical, len(p.names)-done, inCurrent)
+	return res
+}
+
+// estimateRemaining returns the seconds left for left configs, the first of which has run for
+// inCurrent, from the average of measured or, before any config is done, from typical.
+func estimateRemaining(measured []time.Duration, typical time.Duration, left int, inCurrent time.Duration) int {
+	avg := typical
+	if len(measured) > 0 {
+		var sum time.Duration
+		for _, d := range measured {
+			sum += d
+		}
+		avg = sum / time.Duration(len(measured))
+	}
+	if avg <= 0 || left <= 0 {
+		return 0
+	}
+	rem := time.Duration(left)*avg - min(inCurrent, avg)
+	return int(rem.Seconds())
+}
+
+// setTestDurations hands how long each config of the running test took, by file name, to
+// finishTests.
+func (s *Service) setTestDurations(durations map[string]time.Duration) {
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+	s.testDurations = make(map[string]time.Duration, len(durations))
+	for name, d := range durations {
+		s.testDurations[name] = d
+	}
+}
+
+// applyTestDurations fills in DurationMs of results from the durations the progress watcher
+// measured, and forgets them.
+func (s *Service) applyTestDurations(results map[string]TestResult) {
+	s.testMu.Lock()
+	durations := s.testDurations
+	s.testDurations = nil
+	s.testMu.Unlock()
+	for printed, r := range results {
+		for file, d := range durations {
+			if r.DurationMs == 0 && matchesConfig(printed, file) {
+				r.DurationMs = d.Milliseconds()
+				results[printed] = r
+			}
+		}
+	}
+}
+
+// matchesConfig reports whether the config name the test script printed refers to file, with or
+// without its .bat extension.
+func matchesConfig(printed, file string) bool {
+	return strings.EqualFold(printed, file) || strings.EqualFold(printed, strings.TrimSuffix(file, filepath.Ext(file)))
+}
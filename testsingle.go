@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keptResultsDir holds the results of earlier runs while RunTestFor runs, so the test results
+// watcher only sees the new file.
+const keptResultsDir = "test results.kept"
+
+// testConfigIndex is the number the test script's config selection shows for the strategy name:
+// its position among the release's general*.bat files in name order.
+func testConfigIndex(current, name string) (int, error) {
+	names, err := testConfigNames(current)
+	if err != nil {
+		return 0, err
+	}
+	for i, n := range names {
+		if strings.EqualFold(n, name) {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not in the release folder", name)
+}
+
+// testConfigNames lists the general*.bat files of the release at current in the order the test
+// script runs them.
+func testConfigNames(current string) ([]string, error) {
+	entries, err := os.ReadDir(current)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		n := e.Name()
+		if !e.IsDir() && strings.HasSuffix(strings.ToLower(n), ".bat") && strategyCategory(n) == categoryGeneral {
+			names = append(names, n)
+		}
+	}
+	// PowerShell's Sort-Object compares names case-insensitively.
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names, nil
+}
+
+// setAsideResults moves the results folder of the release at current out of the way and leaves an
+// empty one for the next run.
+func setAsideResults(current string) error {
+	utils := filepath.Join(current, "utils")
+	results, kept := filepath.Join(utils, "test results"), filepath.Join(utils, keptResultsDir)
+	// A kept folder left by a crash is older than whatever is in results now.
+	if _, err := os.Stat(kept); err == nil {
+		if err := os.RemoveAll(results); err != nil {
+			return err
+		}
+	} else if err := os.Rename(results, kept); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.MkdirAll(results, 0o755)
+}
+
+// restoreResults drops the results of a single-strategy run and puts back what setAsideResults moved.
+func restoreResults(current string) {
+	utils := filepath.Join(current, "utils")
+	results, kept := filepath.Join(utils, "test results"), filepath.Join(utils, keptResultsDir)
+	if _, err := os.Stat(kept); err != nil {
+		return
+	}
+	_ = os.RemoveAll(results)
+	_ = os.Rename(kept, results)
+}
+
+// parsedResult finds the result of the strategy name in parsed. The test script may report names
+// without the .bat extension.
+func parsedResult(parsed *parsedResults, name string) (TestResult, bool) {
+	if parsed == nil {
+		return TestResult{}, false
+	}
+	for n, r := range parsed.Results {
+		if matchesConfig(n, name) {
+			r.Name = name
+			return r, true
+		}
+	}
+	return TestResult{}, false
+}
+
+// needsRehydration reports whether State should load results from the test results folder: only
+// when the config has none, since a RunTestFor result is newer than the file of the last full run.
+func (s *Service) needsRehydration() bool {
+	if s.testing.Load() {
+		return false
+	}
+	cfg, err := s.configSnapshot()
+	return err == nil && len(cfg.TestResults) == 0 && !cfg.TestInProgress
+}
+
+// RetestFailed tests again the general strategies whose last result failed or that have none yet.
+// Their fresh results replace the old ones, passing results are kept and BestStrategy is
+// recomputed over all of them. The returned state lists the refreshed strategies in Retested; the
+// time of each is its result's LastTestedAt.
+func (s *Service) RetestFailed() (*State, error) {
+	strategies, err := s.listStrategies()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, st := range strategies {
+		if r, ok := cfg.TestResults[st.Name]; st.Category == categoryGeneral && (!ok || r.Status != "ok") {
+			names = append(names, st.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no failed strategies to retest")
+	}
+	started := time.Now()
+	state, err := s.runTestsSync(TestOptions{Configs: names})
+	if state != nil {
+		for _, name := range names {
+			if r, ok := state.Config.TestResults[name]; ok && !r.LastTestedAt.Before(started) {
+				state.Retested = append(state.Retested, name)
+			}
+		}
+	}
+	return state, err
+}
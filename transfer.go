@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportFormat is bumped whenever exportPayload changes incompatibly.
+const exportFormat = 1
+
+const (
+	// importReplace overwrites local settings, results and lists with the imported ones.
+	importReplace = "replace"
+	// importMerge keeps local settings, adds imported results that are missing or newer, and
+	// appends imported list entries the local lists lack.
+	importMerge = "merge"
+)
+
+// exportPayload is the file written by ExportData and read by ImportData.
+type exportPayload struct {
+	Format     int       `json:"format"`
+	AppVersion string    `json:"appVersion"`
+	ExportedAt time.Time `json:"exportedAt"`
+	// Config carries settings, test results, Meta and strategy aliases and notes.
+	Config *Config `json:"config"`
+	// Lists holds the contents of the current release's lists/ files by file name.
+	Lists map[string]string `json:"lists,omitempty"`
+}
+
+// ReleaseNotInstalledError is returned by ImportData when the imported data belongs to a release
+// that isn't unpacked locally; DownloadRelease(Tag) followed by another import resolves it.
+type ReleaseNotInstalledError struct {
+	Tag string
+}
+
+func (e *ReleaseNotInstalledError) Error() string {
+	return fmt.Sprintf("release %s is not installed, download it before importing", e.Tag)
+}
+
+// ExportData writes settings, test results and the current release's lists to a single JSON file.
+func (s *Service) ExportData(path string) error {
+	cfg, err := s.configSnapshot()
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("export path must be absolute: %q", path)
+	}
+	// Process and download state only makes sense on this machine.
+	cfg.Running = nil
+	cfg.TestInProgress = false
+	cfg.TestPID, cfg.TestStartedAt, cfg.TestLog, cfg.TestTargets = 0, time.Time{}, "", nil
+	cfg.TestJob, cfg.QuickCheck, cfg.LastRunChanges = nil, nil, nil
+	cfg.PendingVersion = ""
+
+	payload := exportPayload{
+		Format:     exportFormat,
+		AppVersion: appVersion,
+		ExportedAt: time.Now(),
+		Config:     cfg,
+		Lists:      make(map[string]string),
+	}
+	if current := s.currentReleasePath(); current != "" {
+		entries, err := os.ReadDir(filepath.Join(current, "lists"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(current, "lists", e.Name()))
+			if err != nil {
+				return err
+			}
+			payload.Lists[e.Name()] = string(data)
+		}
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
+
+// ImportData reads a file written by ExportData and applies it using policy ("replace" or "merge").
+// Imports referencing a release that isn't installed are refused with *ReleaseNotInstalledError.
+func (s *Service) ImportData(path, policy string) (*State, error) {
+	if policy == "" {
+		policy = importMerge
+	}
+	if policy != importReplace && policy != importMerge {
+		return nil, fmt.Errorf("unknown import policy %q", policy)
+	}
+	if s.testing.Load() {
+		return nil, &TestInProgressError{}
+	}
+	payload, err := readExport(path)
+	if err != nil {
+		return nil, err
+	}
+	in := payload.Config
+	if in.Version != "" {
+		if err := validateRelease(filepath.Join(s.dirs().releasesDir, in.Version)); err != nil {
+			return nil, &ReleaseNotInstalledError{Tag: in.Version}
+		}
+	}
+	if in.ProxyURL != "" {
+		if _, err := parseProxyURL(in.ProxyURL); err != nil {
+			return nil, fmt.Errorf("imported proxy: %w", err)
+		}
+	}
+
+	var current string
+	err = s.updateConfig(func(cfg *Config) error {
+		if policy == importReplace {
+			running, pending, testLog, testJob := cfg.Running, cfg.PendingVersion, cfg.TestLog, cfg.TestJob
+			*cfg = *in
+			cfg.Running, cfg.PendingVersion, cfg.TestLog, cfg.TestJob = running, pending, testLog, testJob
+			cfg.TestInProgress, cfg.TestPID, cfg.TestStartedAt, cfg.TestTargets = false, 0, time.Time{}, nil
+		} else {
+			mergeConfig(cfg, in)
+		}
+		if cfg.Version != "" {
+			current = filepath.Join(s.dirs().releasesDir, cfg.Version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if policy == importReplace {
+		if err := s.applyProxy(in.ProxyURL); err != nil {
+			return nil, err
+		}
+		s.invalidateLatest()
+	}
+	if current != "" {
+		if err := importLists(filepath.Join(current, "lists"), payload.Lists, policy); err != nil {
+			return nil, err
+		}
+	}
+	return s.State()
+}
+
+// readExport loads and validates an export file.
+func readExport(path string) (*exportPayload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var payload exportPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("not a zapret-ui export: %w", err)
+	}
+	if payload.Format != exportFormat {
+		return nil, fmt.Errorf("unsupported export format %d", payload.Format)
+	}
+	if payload.Config == nil {
+		return nil, errors.New("export contains no config")
+	}
+	if payload.Config.TestResults == nil {
+		payload.Config.TestResults = make(map[string]TestResult)
+	}
+	if payload.Config.Meta == nil {
+		payload.Config.Meta = make(map[string]interface{})
+	}
+	for name := range payload.Lists {
+		if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid list file name %q", name)
+		}
+	}
+	return &payload, nil
+}
+
+// mergeConfig folds imported results, notes and lists of values into cfg without touching its settings.
+func mergeConfig(cfg, in *Config) {
+	for name, res := range in.TestResults {
+		if cur, ok := cfg.TestResults[name]; !ok || res.LastTestedAt.After(cur.LastTestedAt) {
+			cfg.TestResults[name] = res
+		}
+	}
+	if in.LastTestAt.After(cfg.LastTestAt) {
+		cfg.LastTestAt = in.LastTestAt
+	}
+	cfg.BestStrategy = bestStrategy(cfg.TestResults, cfg.Settings.domainWeight())
+	for k, v := range in.Meta {
+		if _, ok := cfg.Meta[k]; !ok {
+			cfg.Meta[k] = v
+		}
+	}
+	for k, v := range in.StrategyMeta {
+		if _, ok := cfg.StrategyMeta[k]; !ok {
+			if cfg.StrategyMeta == nil {
+				cfg.StrategyMeta = make(map[string]StrategyUserMeta)
+			}
+			cfg.StrategyMeta[k] = v
+		}
+	}
+	if cfg.LastStrategy == "" {
+		cfg.LastStrategy = in.LastStrategy
+	}
+	for _, v := range in.SkippedVersions {
+		if !containsString(cfg.SkippedVersions, v) {
+			cfg.SkippedVersions = append(cfg.SkippedVersions, v)
+		}
+	}
+	for _, v := range in.Favorites {
+		if !containsString(cfg.Favorites, v) {
+			cfg.Favorites = append(cfg.Favorites, v)
+		}
+	}
+	for _, v := range in.Hidden {
+		if !containsString(cfg.Hidden, v) {
+			cfg.Hidden = append(cfg.Hidden, v)
+		}
+	}
+	for _, m := range in.Mirrors {
+		if !containsString(cfg.Mirrors, m) {
+			cfg.Mirrors = append(cfg.Mirrors, m)
+		}
+	}
+	for _, d := range in.TestDomains {
+		if !containsString(cfg.TestDomains, d) {
+			cfg.TestDomains = append(cfg.TestDomains, d)
+		}
+	}
+	for _, m := range in.APIMirrors {
+		if !containsString(cfg.APIMirrors, m) {
+			cfg.APIMirrors = append(cfg.APIMirrors, m)
+		}
+	}
+}
+
+// importLists writes imported list files into dir: replace overwrites them, merge appends missing lines.
+func importLists(dir string, lists map[string]string, policy string) error {
+	if len(lists) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, content := range lists {
+		dst := filepath.Join(dir, name)
+		if _, err := os.Stat(dst); policy == importReplace || os.IsNotExist(err) {
+			if err := os.WriteFile(dst, []byte(content), 0o644); err != nil {
+				return err
+			}
+			continue
+		}
+		tmp, err := os.CreateTemp("", "zapret-import-*")
+		if err != nil {
+			return err
+		}
+		_, werr := tmp.WriteString(content)
+		tmp.Close()
+		if werr == nil {
+			_, werr = appendMissingLines(tmp.Name(), dst)
+		}
+		os.Remove(tmp.Name())
+		if werr != nil {
+			return werr
+		}
+	}
+	return nil
+}
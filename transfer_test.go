@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testedEarlier = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	testedLater   = testedEarlier.Add(time.Hour)
+)
+
+// writeList writes the list file name of the release at current.
+func writeList(t *testing.T, current, name, content string) {
+	t.Helper()
+	dir := filepath.Join(current, "lists")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func listLines(t *testing.T, current, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(current, "lists", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	sort.Strings(lines)
+	return lines
+}
+
+// exportFrom sets up a service with results, favorites, settings and lists, and exports it.
+func exportFrom(t *testing.T) string {
+	t.Helper()
+	src, current := newTestService(t, "general.bat", "general (ALT).bat")
+	err := src.updateConfig(func(cfg *Config) error {
+		cfg.TestResults = map[string]TestResult{
+			"general.bat":       {Name: "general.bat", HTTP_OK: 5, Status: "ok", LastTestedAt: testedEarlier},
+			"general (ALT).bat": {Name: "general (ALT).bat", HTTP_OK: 6, Status: "ok", LastTestedAt: testedLater},
+		}
+		cfg.BestStrategy = "general (ALT).bat"
+		cfg.Favorites = []string{"general (ALT).bat"}
+		cfg.TestDomains = []string{"example.com"}
+		cfg.Settings.KeepAwake = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeList(t, current, "list-general.txt", "a.example\nb.example\n")
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := src.ExportData(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// importInto sets up a service with its own results, favorites, settings and lists.
+func importInto(t *testing.T) (*Service, string) {
+	t.Helper()
+	dst, current := newTestService(t, "general.bat", "general (ALT).bat")
+	err := dst.updateConfig(func(cfg *Config) error {
+		cfg.TestResults = map[string]TestResult{
+			"general.bat":       {Name: "general.bat", HTTP_OK: 2, HTTP_ERR: 3, Status: "fail", LastTestedAt: testedLater},
+			"general (ALT).bat": {Name: "general (ALT).bat", HTTP_OK: 1, HTTP_ERR: 4, Status: "fail", LastTestedAt: testedEarlier},
+		}
+		cfg.BestStrategy = "general.bat"
+		cfg.Favorites = []string{"general.bat"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeList(t, current, "list-general.txt", "b.example\r\nc.example\r\n")
+	return dst, current
+}
+
+func TestImportDataReplace(t *testing.T) {
+	path := exportFrom(t)
+	dst, current := importInto(t)
+	state, err := dst.ImportData(path, importReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := state.Config
+	if r := cfg.TestResults["general.bat"]; r.HTTP_OK != 5 || !r.LastTestedAt.Equal(testedEarlier) {
+		t.Errorf("general.bat = %+v, want the imported result even though it is older", r)
+	}
+	if r := cfg.TestResults["general (ALT).bat"]; r.HTTP_OK != 6 {
+		t.Errorf("general (ALT).bat = %+v, want the imported result", r)
+	}
+	if cfg.BestStrategy != "general (ALT).bat" {
+		t.Errorf("best strategy = %q", cfg.BestStrategy)
+	}
+	if want := []string{"general (ALT).bat"}; !reflect.DeepEqual(cfg.Favorites, want) {
+		t.Errorf("favorites = %v, want %v", cfg.Favorites, want)
+	}
+	if !cfg.Settings.KeepAwake {
+		t.Error("imported settings not applied")
+	}
+	if got, want := listLines(t, current, "list-general.txt"), []string{"a.example", "b.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list = %v, want %v", got, want)
+	}
+}
+
+func TestImportDataMerge(t *testing.T) {
+	path := exportFrom(t)
+	dst, current := importInto(t)
+	state, err := dst.ImportData(path, importMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := state.Config
+	if r := cfg.TestResults["general.bat"]; r.HTTP_OK != 2 || !r.LastTestedAt.Equal(testedLater) {
+		t.Errorf("general.bat = %+v, want the local result, which is newer", r)
+	}
+	if r := cfg.TestResults["general (ALT).bat"]; r.HTTP_OK != 6 || !r.LastTestedAt.Equal(testedLater) {
+		t.Errorf("general (ALT).bat = %+v, want the imported result, which is newer", r)
+	}
+	if cfg.BestStrategy != "general (ALT).bat" {
+		t.Errorf("best strategy = %q, want it recomputed over the merged results", cfg.BestStrategy)
+	}
+	if want := []string{"general.bat", "general (ALT).bat"}; !reflect.DeepEqual(cfg.Favorites, want) {
+		t.Errorf("favorites = %v, want %v", cfg.Favorites, want)
+	}
+	if want := []string{"example.com"}; !reflect.DeepEqual(cfg.TestDomains, want) {
+		t.Errorf("test domains = %v, want %v", cfg.TestDomains, want)
+	}
+	if cfg.Settings.KeepAwake {
+		t.Error("merge changed local settings")
+	}
+	if got, want := listLines(t, current, "list-general.txt"), []string{"a.example", "b.example", "c.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list = %v, want %v", got, want)
+	}
+	// Importing the same file again changes nothing.
+	if _, err := dst.ImportData(path, importMerge); err != nil {
+		t.Fatal(err)
+	}
+	if got := listLines(t, current, "list-general.txt"); len(got) != 3 {
+		t.Errorf("list after a second merge = %v", got)
+	}
+}
+
+func TestImportDataRefusesMissingRelease(t *testing.T) {
+	path := exportFrom(t)
+	dst, _ := newTestService(t, "general.bat")
+	if err := os.RemoveAll(filepath.Join(dst.dirs().releasesDir, "v1")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dst.ImportData(path, importMerge)
+	var missing *ReleaseNotInstalledError
+	if !errors.As(err, &missing) || missing.Tag != "v1" {
+		t.Fatalf("got %v, want ReleaseNotInstalledError for v1", err)
+	}
+	if _, err := dst.ImportData(path, "overwrite"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
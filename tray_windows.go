@@ -13,6 +13,9 @@ import (
 
 var trayOnce sync.Once
 
+// trayUpdate is the hidden menu entry revealed when a new release is found.
+var trayUpdate *systray.MenuItem
+
 //go:embed build/windows/icon.ico
 var trayIcon []byte
 
@@ -29,6 +32,8 @@ func startTray(ctx context.Context) {
 			systray.SetTitle("Zapret UI")
 			systray.SetTooltip("zapret-ui")
 
+			trayUpdate = systray.AddMenuItem("Update available", "Open the app to update")
+			trayUpdate.Hide()
 			mOpen := systray.AddMenuItem("Open", "Show the main window")
 			mHide := systray.AddMenuItem("Hide", "Hide the main window")
 			systray.AddSeparator()
@@ -37,6 +42,9 @@ func startTray(ctx context.Context) {
 			go func() {
 				for {
 					select {
+					case <-trayUpdate.ClickedCh:
+						runtime.WindowShow(ctx)
+						runtime.WindowUnminimise(ctx)
 					case <-mOpen.ClickedCh:
 						runtime.WindowShow(ctx)
 						runtime.WindowUnminimise(ctx)
@@ -44,7 +52,7 @@ func startTray(ctx context.Context) {
 					case <-mHide.ClickedCh:
 						runtime.WindowHide(ctx)
 					case <-mQuit.ClickedCh:
-						runtime.Quit(ctx)
+						quitApp(ctx)
 						systray.Quit()
 						return
 					}
@@ -53,3 +61,25 @@ func startTray(ctx context.Context) {
 		}, func() {})
 	})
 }
+
+// trayNotifyUpdate surfaces a new release in the tray tooltip and menu.
+func trayNotifyUpdate(tag string) {
+	if trayUpdate == nil {
+		return
+	}
+	systray.SetTooltip("zapret-ui: update " + tag + " available")
+	trayUpdate.SetTitle("Update available: " + tag)
+	trayUpdate.Show()
+}
+
+// trayNotifyFailover shows the last failover switch in the tray tooltip.
+func trayNotifyFailover(ev FailoverEvent) {
+	if trayUpdate == nil {
+		return
+	}
+	if ev.To == "" {
+		systray.SetTooltip("zapret-ui: " + ev.From + " stopped working, no strategy left to switch to")
+		return
+	}
+	systray.SetTooltip("zapret-ui: " + ev.From + " stopped working, switched to " + ev.To)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// RunningUsage is how long the running strategy has been up and what its winws.exe uses, sampled
+// for each State.
+type RunningUsage struct {
+	UptimeSeconds int64 `json:"uptimeSeconds"`
+	// WorkingSet is the memory of winws.exe in bytes.
+	WorkingSet uint64 `json:"workingSet,omitempty"`
+	// CPUPercent is the share of all CPUs winws.exe used since the previous sample, or since it
+	// started for the first one.
+	CPUPercent float64 `json:"cpuPercent"`
+	// Restarts counts the times the strategy was restarted; see RunningInfo.Restarts.
+	Restarts int `json:"restarts,omitempty"`
+	// Limited is set when winws.exe couldn't be queried; only the uptime and restarts are known.
+	Limited bool `json:"limited,omitempty"`
+}
+
+// usageMinInterval is the shortest span CPUPercent is measured over.
+const usageMinInterval = time.Second
+
+// cpuSample is the CPU time a process had used at a point in time.
+type cpuSample struct {
+	pid  int
+	at   time.Time
+	used time.Duration
+}
+
+// usageSampler keeps the previous CPU sample, so CPUPercent reflects recent load.
+type usageSampler struct {
+	mu   sync.Mutex
+	last cpuSample
+}
+
+// runningUsage samples run. Counters that can't be read leave the uptime alone.
+func (s *Service) runningUsage(run *RunningInfo) *RunningUsage {
+	now := time.Now()
+	u := &RunningUsage{Restarts: run.Restarts}
+	if start := run.sessionStart(); !start.IsZero() {
+		u.UptimeSeconds = int64(now.Sub(start) / time.Second)
+	}
+	pid := run.WinwsPID
+	if pid <= 0 {
+		u.Limited = true
+		return u
+	}
+	c, err := processCounters(pid)
+	if err != nil {
+		u.Limited = true
+		return u
+	}
+	u.WorkingSet = c.workingSet
+
+	s.usage.mu.Lock()
+	prev := s.usage.last
+	// States asked for in quick succession keep measuring from the older sample.
+	if prev.pid != pid || now.Sub(prev.at) >= usageMinInterval {
+		s.usage.last = cpuSample{pid: pid, at: now, used: c.cpu}
+	}
+	s.usage.mu.Unlock()
+	if prev.pid != pid || !prev.at.Before(now) || prev.used > c.cpu {
+		prev = cpuSample{pid: pid, at: c.started, used: 0}
+	}
+	if elapsed := now.Sub(prev.at); elapsed > 0 {
+		u.CPUPercent = float64(c.cpu-prev.used) / float64(elapsed) / float64(runtime.NumCPU()) * 100
+	}
+	return u
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// releaseVersion is a parsed release tag such as "v1.9.0", "1.8.4b" or "1.9.0-rc1".
+type releaseVersion struct {
+	nums []int
+	// suffix is whatever follows the numeric part. A suffix starting with "-" marks a
+	// pre-release (sorts before the plain version); any other suffix, like the "b" in
+	// "1.8.4b", marks a later rebuild (sorts after it).
+	suffix string
+}
+
+// parseVersion parses a tag into numeric segments, tolerating a leading "v".
+// It returns false for tags that don't start with a number, e.g. "latest".
+func parseVersion(tag string) (releaseVersion, bool) {
+	t := strings.TrimSpace(tag)
+	t = strings.TrimPrefix(strings.TrimPrefix(t, "v"), "V")
+	var v releaseVersion
+	for {
+		i := 0
+		for i < len(t) && t[i] >= '0' && t[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return releaseVersion{}, false
+		}
+		n, err := strconv.Atoi(t[:i])
+		if err != nil {
+			return releaseVersion{}, false
+		}
+		v.nums = append(v.nums, n)
+		t = t[i:]
+		if len(t) > 1 && t[0] == '.' && t[1] >= '0' && t[1] <= '9' {
+			t = t[1:]
+			continue
+		}
+		break
+	}
+	v.suffix = strings.ToLower(t)
+	return v, true
+}
+
+// compareVersions returns -1, 0 or 1. Missing numeric segments count as zero, so "1.9" == "1.9.0".
+func compareVersions(a, b releaseVersion) int {
+	for i := 0; i < len(a.nums) || i < len(b.nums); i++ {
+		var x, y int
+		if i < len(a.nums) {
+			x = a.nums[i]
+		}
+		if i < len(b.nums) {
+			y = b.nums[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareSuffix(a.suffix, b.suffix)
+}
+
+func compareSuffix(a, b string) int {
+	if a == b {
+		return 0
+	}
+	rank := func(s string) int {
+		switch {
+		case strings.HasPrefix(s, "-"):
+			return -1
+		case s == "":
+			return 0
+		default:
+			return 1
+		}
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	if a < b {
+		return -1
+	}
+	return 1
+}
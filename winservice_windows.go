@@ -0,0 +1,256 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	// zapretService is the name the release's service.bat installs zapret under; the app uses the
+	// same one, so either installation is seen by both.
+	zapretService = "zapret"
+	// zapretServiceValue is the value service.bat stores the strategy in under the service's key.
+	zapretServiceValue = "zapret-discord-youtube"
+	// serviceStopWait bounds the wait for the service to stop in RemoveService.
+	serviceStopWait = 10 * time.Second
+	// serviceDeleteWait bounds the wait for a replaced service to go away in InstallAsService.
+	serviceDeleteWait = 10 * time.Second
+)
+
+// winServiceInfo is what the app knows about the installed zapret service.
+type winServiceInfo struct {
+	Installed bool
+	Running   bool
+	// Strategy is the strategy the service runs, "" if it can't be told.
+	Strategy string
+}
+
+// ServiceConflictError is returned when a strategy is started while the zapret service runs
+// winws already.
+type ServiceConflictError struct {
+	Service string
+}
+
+func (e *ServiceConflictError) Error() string {
+	if e.Service != "" {
+		return fmt.Sprintf("the zapret Windows service is running %s; remove the service or stop it before starting a strategy", e.Service)
+	}
+	return "the zapret Windows service is running; remove the service or stop it before starting a strategy"
+}
+
+// ZapretServiceError is a failed service control manager call about the zapret service.
+type ZapretServiceError struct {
+	Op   string
+	Code uint32
+	Err  error
+}
+
+func (e *ZapretServiceError) Error() string {
+	if errors.Is(e.Err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) {
+		return fmt.Sprintf("%s the zapret service: the previous one is still being deleted; close the Services console or anything else that has it open and try again (error %d)", e.Op, e.Code)
+	}
+	return fmt.Sprintf("%s the zapret service: %v (error %d / 0x%08X)", e.Op, e.Err, e.Code, e.Code)
+}
+
+func (e *ZapretServiceError) Unwrap() error { return e.Err }
+
+// newZapretServiceError wraps err from op on the zapret service with its Windows error code.
+func newZapretServiceError(op string, err error) *ZapretServiceError {
+	e := &ZapretServiceError{Op: op, Err: err}
+	var errno windows.Errno
+	if errors.As(err, &errno) {
+		e.Code = uint32(errno)
+	}
+	return e
+}
+
+// queryWinService looks up the zapret service; it needs no administrator rights. strategies are
+// matched against its winws arguments when it doesn't name its strategy itself.
+func queryWinService(strategies []Strategy) winServiceInfo {
+	var info winServiceInfo
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return info
+	}
+	defer windows.CloseServiceHandle(scm)
+	h, err := windows.OpenService(scm, windows.StringToUTF16Ptr(zapretService), windows.SERVICE_QUERY_STATUS|windows.SERVICE_QUERY_CONFIG)
+	if err != nil {
+		return info
+	}
+	defer windows.CloseServiceHandle(h)
+	info.Installed = true
+	var st windows.SERVICE_STATUS
+	if windows.QueryServiceStatus(h, &st) == nil {
+		info.Running = st.CurrentState != windows.SERVICE_STOPPED
+	}
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, `System\CurrentControlSet\Services\`+zapretService, registry.QUERY_VALUE); err == nil {
+		info.Strategy, _, _ = k.GetStringValue(zapretServiceValue)
+		k.Close()
+	}
+	if info.Strategy != "" {
+		// service.bat stores the name without .bat.
+		for _, s := range strategies {
+			if strings.EqualFold(s.Name, info.Strategy) || strings.EqualFold(strings.TrimSuffix(s.Name, filepath.Ext(s.Name)), info.Strategy) {
+				info.Strategy = s.Name
+				break
+			}
+		}
+		return info
+	}
+	if args := winwsArgs(serviceBinaryPath(h)); args != "" {
+		fp := (&StrategyDetails{Raw: args}).fingerprint()
+		for _, s := range strategies {
+			if s.Fingerprint == fp && s.DuplicateOf == "" {
+				info.Strategy = s.Name
+				break
+			}
+		}
+	}
+	return info
+}
+
+// serviceBinaryPath returns the command line the service h runs.
+func serviceBinaryPath(h windows.Handle) string {
+	var needed uint32
+	_ = windows.QueryServiceConfig(h, nil, 0, &needed)
+	if needed == 0 {
+		return ""
+	}
+	buf := make([]uint64, (needed+7)/8)
+	cfg := (*windows.QUERY_SERVICE_CONFIG)(unsafe.Pointer(&buf[0]))
+	if err := windows.QueryServiceConfig(h, cfg, uint32(len(buf)*8), &needed); err != nil {
+		return ""
+	}
+	return windows.UTF16PtrToString(cfg.BinaryPathName)
+}
+
+// InstallAsService installs the strategy as the zapret Windows service, which starts winws at boot
+// without the app, and starts it. A service installed before is replaced, and the running strategy
+// is stopped. The service runs from the current release folder, so it has to be installed again
+// after an update removes that release.
+func (s *Service) InstallAsService(strategy string) (*State, error) {
+	if !isElevated() {
+		return nil, &AdminRequiredError{Strategy: "Installing the zapret service"}
+	}
+	st, err := s.strategyByName(strategy)
+	if err != nil {
+		return nil, err
+	}
+	current := s.currentReleasePath()
+	if current == "" {
+		return nil, errors.New("no current release")
+	}
+	d := st.Details
+	if d == nil {
+		return nil, fmt.Errorf("%s doesn't start winws.exe", st.Name)
+	}
+	if m := batVarRe.FindString(d.Raw); m != "" {
+		return nil, fmt.Errorf("%s uses %s, which is only set when the .bat runs; install it with the release's service.bat", st.Name, m)
+	}
+	exe := filepath.Join(current, "bin", "winws.exe")
+	if len(d.Files) > 0 && strings.EqualFold(filepath.Base(d.Files[0]), "winws.exe") {
+		exe = d.Files[0]
+	}
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if _, err := s.stopRunning(false); err != nil {
+		return nil, err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, newZapretServiceError("connecting to the service manager for", err)
+	}
+	defer m.Disconnect()
+	if err := removeWinService(m); err != nil {
+		return nil, err
+	}
+	service, err := createWinService(m, exe, splitBatArgs(d.Raw))
+	if err != nil {
+		return nil, err
+	}
+	defer service.Close()
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, `System\CurrentControlSet\Services\`+zapretService, registry.SET_VALUE); err == nil {
+		_ = k.SetStringValue(zapretServiceValue, strings.TrimSuffix(st.Name, filepath.Ext(st.Name)))
+		k.Close()
+	}
+	if err := service.Start(); err != nil {
+		return nil, newZapretServiceError("starting", err)
+	}
+	s.logUpdate("installed %s as the zapret service", st.Name)
+	return s.State()
+}
+
+// createWinService creates the zapret service running exe with args. A service that was just
+// deleted stays marked for deletion until every handle to it is closed, and a new one can't be
+// created under its name until then, so creating is retried for serviceDeleteWait.
+func createWinService(m *mgr.Mgr, exe string, args []string) (*mgr.Service, error) {
+	deadline := time.Now().Add(serviceDeleteWait)
+	for {
+		service, err := m.CreateService(zapretService, exe, mgr.Config{
+			DisplayName: "zapret",
+			Description: "Zapret DPI bypass software",
+			StartType:   mgr.StartAutomatic,
+		}, args...)
+		if err == nil {
+			return service, nil
+		}
+		if !errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) || time.Now().After(deadline) {
+			return nil, newZapretServiceError("creating", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// RemoveService stops and deletes the zapret Windows service, whether the app or the release's
+// service.bat installed it.
+func (s *Service) RemoveService() (*State, error) {
+	if !isElevated() {
+		return nil, &AdminRequiredError{Strategy: "Removing the zapret service"}
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, newZapretServiceError("connecting to the service manager for", err)
+	}
+	defer m.Disconnect()
+	if err := removeWinService(m); err != nil {
+		return nil, err
+	}
+	s.logUpdate("removed the zapret service")
+	return s.State()
+}
+
+// removeWinService stops and deletes the zapret service, if there is one.
+func removeWinService(m *mgr.Mgr) error {
+	service, err := m.OpenService(zapretService)
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return nil
+	}
+	if err != nil {
+		return newZapretServiceError("opening", err)
+	}
+	defer service.Close()
+	if _, err := service.Control(svc.Stop); err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return newZapretServiceError("stopping", err)
+	}
+	for deadline := time.Now().Add(serviceStopWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if st, err := service.Query(); err != nil || st.State == svc.Stopped {
+			break
+		}
+	}
+	if err := service.Delete(); err != nil && !errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) {
+		return newZapretServiceError("deleting", err)
+	}
+	return nil
+}
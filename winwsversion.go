@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// winwsFlagSupport is the zapret version range a winws option exists in; an empty bound is open.
+type winwsFlagSupport struct {
+	added   string
+	removed string
+}
+
+// winwsFlagTable lists the winws options that were added or removed over the zapret versions the
+// bundled releases have shipped. Options not listed are assumed to be understood by every version.
+var winwsFlagTable = map[string]winwsFlagSupport{
+	"--dpi-desync-split-http-req":       {removed: "67"},
+	"--dpi-desync-split-tls":            {removed: "67"},
+	"--dpi-desync-split-pos":            {added: "67"},
+	"--dpi-desync-split-seqovl-pattern": {added: "67"},
+	"--filter-l7":                       {added: "65"},
+	"--hostlist-domains":                {added: "69"},
+	"--dpi-desync-fake-tls-mod":         {added: "69"},
+	"--dpi-desync-fake-tcp-mod":         {added: "69"},
+	"--dpi-desync-fake-discord":         {added: "70"},
+	"--dpi-desync-fake-stun":            {added: "70"},
+	"--ip-id":                           {added: "70"},
+}
+
+// winwsVersion returns the version of the winws.exe in the release at dir, or "" if it carries none.
+func winwsVersion(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return fileVersion(filepath.Join(dir, "bin", "winws.exe"))
+}
+
+// unsupportedWinwsFlags describes the options in args that winws version doesn't understand. It
+// returns nil when the version is unknown.
+func unsupportedWinwsFlags(args, version string) []string {
+	v, ok := parseVersion(version)
+	if !ok {
+		return nil
+	}
+	var res []string
+	for _, tok := range splitBatArgs(args) {
+		flag, _, _ := strings.Cut(tok, "=")
+		sup, ok := winwsFlagTable[flag]
+		if !ok {
+			continue
+		}
+		var problem string
+		if a, ok := parseVersion(sup.added); ok && compareVersions(v, a) < 0 {
+			problem = fmt.Sprintf("%s needs winws %s or newer, the release has %s", flag, sup.added, version)
+		} else if r, ok := parseVersion(sup.removed); ok && compareVersions(v, r) >= 0 {
+			problem = fmt.Sprintf("%s was removed in winws %s, the release has %s", flag, sup.removed, version)
+		}
+		if problem != "" && !containsString(res, problem) {
+			res = append(res, problem)
+		}
+	}
+	return res
+}